@@ -0,0 +1,263 @@
+package app
+
+import (
+	"fmt"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	aftTypeIPv4 = "ipv4"
+	aftTypeIPv6 = "ipv6"
+	aftTypeMPLS = "mpls"
+	aftTypeNHG  = "nhg"
+	aftTypeNH   = "nh"
+	aftTypeMAC  = "mac"
+	aftTypePF   = "pf"
+)
+
+// aftEntryType returns the AFT type of the entry.
+func aftEntryType(e *spb.AFTEntry) string {
+	switch e.GetEntry().(type) {
+	case *spb.AFTEntry_Ipv4:
+		return aftTypeIPv4
+	case *spb.AFTEntry_Ipv6:
+		return aftTypeIPv6
+	case *spb.AFTEntry_Mpls:
+		return aftTypeMPLS
+	case *spb.AFTEntry_NextHopGroup:
+		return aftTypeNHG
+	case *spb.AFTEntry_NextHop:
+		return aftTypeNH
+	case *spb.AFTEntry_MacEntry:
+		return aftTypeMAC
+	case *spb.AFTEntry_PolicyForwardingEntry:
+		return aftTypePF
+	}
+	return "unknown"
+}
+
+// aftEntryAFTType returns the AFT type of the entry, as used in a GetRequest.
+func aftEntryAFTType(e *spb.AFTEntry) spb.AFTType {
+	switch e.GetEntry().(type) {
+	case *spb.AFTEntry_Ipv4:
+		return spb.AFTType_IPV4
+	case *spb.AFTEntry_Ipv6:
+		return spb.AFTType_IPV6
+	case *spb.AFTEntry_Mpls:
+		return spb.AFTType_MPLS
+	case *spb.AFTEntry_NextHopGroup:
+		return spb.AFTType_NEXTHOP_GROUP
+	case *spb.AFTEntry_NextHop:
+		return spb.AFTType_NEXTHOP
+	case *spb.AFTEntry_MacEntry:
+		return spb.AFTType_MAC
+	case *spb.AFTEntry_PolicyForwardingEntry:
+		return spb.AFTType_POLICY_FORWARDING
+	}
+	return spb.AFTType_INVALID
+}
+
+// aftEntryID returns the entry key within its AFT:
+// a prefix, a label, a NHG ID, a NH index, a MAC address or a PF index.
+func aftEntryID(e *spb.AFTEntry) string {
+	switch e := e.GetEntry().(type) {
+	case *spb.AFTEntry_Ipv4:
+		return e.Ipv4.GetPrefix()
+	case *spb.AFTEntry_Ipv6:
+		return e.Ipv6.GetPrefix()
+	case *spb.AFTEntry_Mpls:
+		return fmt.Sprint(e.Mpls.GetLabelUint64())
+	case *spb.AFTEntry_NextHopGroup:
+		return fmt.Sprint(e.NextHopGroup.GetId())
+	case *spb.AFTEntry_NextHop:
+		return fmt.Sprint(e.NextHop.GetIndex())
+	case *spb.AFTEntry_MacEntry:
+		return e.MacEntry.GetMacAddress()
+	case *spb.AFTEntry_PolicyForwardingEntry:
+		return fmt.Sprint(e.PolicyForwardingEntry.GetIndex())
+	}
+	return ""
+}
+
+// aftEntryKey returns a string uniquely identifying the entry
+// across network instances and AFTs.
+func aftEntryKey(e *spb.AFTEntry) string {
+	return fmt.Sprintf("[%s] %s %s", e.GetNetworkInstance(), aftEntryType(e), aftEntryID(e))
+}
+
+// aftRefKey returns the key of the NHG or NH entry with the given id,
+// as returned by aftEntryKey.
+func aftRefKey(ni, typ string, id uint64) string {
+	return fmt.Sprintf("[%s] %s %d", ni, typ, id)
+}
+
+// protoContains returns true if all the fields set in want
+// are set to the same values in got.
+// Repeated message fields match regardless of their elements order.
+func protoContains(want, got protoreflect.Message) bool {
+	ok := true
+	want.Range(func(fd protoreflect.FieldDescriptor, wv protoreflect.Value) bool {
+		if !got.Has(fd) {
+			ok = false
+			return false
+		}
+		gv := got.Get(fd)
+		switch {
+		case fd.IsList():
+			ok = listContains(fd, wv.List(), gv.List())
+		case fd.IsMap():
+			ok = wv.Equal(gv)
+		case fd.Message() != nil:
+			ok = protoContains(wv.Message(), gv.Message())
+		default:
+			ok = wv.Equal(gv)
+		}
+		return ok
+	})
+	return ok
+}
+
+func listContains(fd protoreflect.FieldDescriptor, want, got protoreflect.List) bool {
+	if want.Len() != got.Len() {
+		return false
+	}
+	if fd.Message() == nil {
+		for i := 0; i < want.Len(); i++ {
+			if !want.Get(i).Equal(got.Get(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	matched := make([]bool, got.Len())
+OUTER:
+	for i := 0; i < want.Len(); i++ {
+		for j := 0; j < got.Len(); j++ {
+			if matched[j] {
+				continue
+			}
+			if protoContains(want.Get(i).Message(), got.Get(j).Message()) {
+				matched[j] = true
+				continue OUTER
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// aftEntryPrefix returns the prefix of an IPv4 or IPv6 entry.
+func aftEntryPrefix(e *spb.AFTEntry) (string, bool) {
+	switch e := e.GetEntry().(type) {
+	case *spb.AFTEntry_Ipv4:
+		return e.Ipv4.GetPrefix(), true
+	case *spb.AFTEntry_Ipv6:
+		return e.Ipv6.GetPrefix(), true
+	}
+	return "", false
+}
+
+// aftEntryNHG returns the next hop group referenced by an IPv4, IPv6 or MPLS entry.
+func aftEntryNHG(e *spb.AFTEntry) (uint64, bool) {
+	switch e := e.GetEntry().(type) {
+	case *spb.AFTEntry_Ipv4:
+		nhg := e.Ipv4.GetIpv4Entry().GetNextHopGroup()
+		return nhg.GetValue(), nhg != nil
+	case *spb.AFTEntry_Ipv6:
+		nhg := e.Ipv6.GetIpv6Entry().GetNextHopGroup()
+		return nhg.GetValue(), nhg != nil
+	case *spb.AFTEntry_Mpls:
+		nhg := e.Mpls.GetLabelEntry().GetNextHopGroup()
+		return nhg.GetValue(), nhg != nil
+	}
+	return 0, false
+}
+
+// aftEntryMetadata returns the metadata of an IPv4, IPv6 or MPLS entry.
+func aftEntryMetadata(e *spb.AFTEntry) []byte {
+	switch e := e.GetEntry().(type) {
+	case *spb.AFTEntry_Ipv4:
+		return e.Ipv4.GetIpv4Entry().GetEntryMetadata().GetValue()
+	case *spb.AFTEntry_Ipv6:
+		return e.Ipv6.GetIpv6Entry().GetEntryMetadata().GetValue()
+	case *spb.AFTEntry_Mpls:
+		return e.Mpls.GetLabelEntry().GetEntryMetadata().GetValue()
+	}
+	return nil
+}
+
+// nhgNextHops returns the indexes of the next hops of a next hop group entry.
+func nhgNextHops(e *spb.AFTEntry) []uint64 {
+	nhs := e.GetNextHopGroup().GetNextHopGroup().GetNextHop()
+	idx := make([]uint64, 0, len(nhs))
+	for _, nh := range nhs {
+		idx = append(idx, nh.GetIndex())
+	}
+	return idx
+}
+
+// aftEntryNHGNetworkInstance returns the network instance of the next hop group
+// referenced by an IPv4, IPv6 or MPLS entry, if set.
+func aftEntryNHGNetworkInstance(e *spb.AFTEntry) string {
+	switch e := e.GetEntry().(type) {
+	case *spb.AFTEntry_Ipv4:
+		return e.Ipv4.GetIpv4Entry().GetNextHopGroupNetworkInstance().GetValue()
+	case *spb.AFTEntry_Ipv6:
+		return e.Ipv6.GetIpv6Entry().GetNextHopGroupNetworkInstance().GetValue()
+	case *spb.AFTEntry_Mpls:
+		return e.Mpls.GetLabelEntry().GetNextHopGroupNetworkInstance().GetValue()
+	}
+	return ""
+}
+
+// aftEntryOperation returns an AFT operation of type op on the entry e.
+func aftEntryOperation(id uint64, op spb.AFTOperation_Operation, e *spb.AFTEntry) (*spb.AFTOperation, error) {
+	aftOp := &spb.AFTOperation{
+		Id:              id,
+		NetworkInstance: e.GetNetworkInstance(),
+		Op:              op,
+	}
+	switch e := e.GetEntry().(type) {
+	case *spb.AFTEntry_Ipv4:
+		aftOp.Entry = &spb.AFTOperation_Ipv4{Ipv4: e.Ipv4}
+	case *spb.AFTEntry_Ipv6:
+		aftOp.Entry = &spb.AFTOperation_Ipv6{Ipv6: e.Ipv6}
+	case *spb.AFTEntry_Mpls:
+		aftOp.Entry = &spb.AFTOperation_Mpls{Mpls: e.Mpls}
+	case *spb.AFTEntry_NextHopGroup:
+		aftOp.Entry = &spb.AFTOperation_NextHopGroup{NextHopGroup: e.NextHopGroup}
+	case *spb.AFTEntry_NextHop:
+		aftOp.Entry = &spb.AFTOperation_NextHop{NextHop: e.NextHop}
+	case *spb.AFTEntry_MacEntry:
+		aftOp.Entry = &spb.AFTOperation_MacEntry{MacEntry: e.MacEntry}
+	case *spb.AFTEntry_PolicyForwardingEntry:
+		aftOp.Entry = &spb.AFTOperation_PolicyForwardingEntry{PolicyForwardingEntry: e.PolicyForwardingEntry}
+	default:
+		return nil, fmt.Errorf("unsupported entry type %T", e)
+	}
+	return aftOp, nil
+}
+
+// aftOperationEntry returns the entry of the AFT operation op.
+func aftOperationEntry(op *spb.AFTOperation) *spb.AFTEntry {
+	e := &spb.AFTEntry{NetworkInstance: op.GetNetworkInstance()}
+	switch oe := op.GetEntry().(type) {
+	case *spb.AFTOperation_Ipv4:
+		e.Entry = &spb.AFTEntry_Ipv4{Ipv4: oe.Ipv4}
+	case *spb.AFTOperation_Ipv6:
+		e.Entry = &spb.AFTEntry_Ipv6{Ipv6: oe.Ipv6}
+	case *spb.AFTOperation_Mpls:
+		e.Entry = &spb.AFTEntry_Mpls{Mpls: oe.Mpls}
+	case *spb.AFTOperation_NextHopGroup:
+		e.Entry = &spb.AFTEntry_NextHopGroup{NextHopGroup: oe.NextHopGroup}
+	case *spb.AFTOperation_NextHop:
+		e.Entry = &spb.AFTEntry_NextHop{NextHop: oe.NextHop}
+	case *spb.AFTOperation_MacEntry:
+		e.Entry = &spb.AFTEntry_MacEntry{MacEntry: oe.MacEntry}
+	case *spb.AFTOperation_PolicyForwardingEntry:
+		e.Entry = &spb.AFTEntry_PolicyForwardingEntry{PolicyForwardingEntry: oe.PolicyForwardingEntry}
+	}
+	return e
+}
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// aftSnapshot holds AFT entries keyed by aftEntryKey.
+type aftSnapshot map[string]*spb.AFTEntry
+
+func newAFTSnapshot(entries ...*spb.AFTEntry) aftSnapshot {
+	s := make(aftSnapshot, len(entries))
+	for _, e := range entries {
+		s.add(e)
+	}
+	return s
+}
+
+func (s aftSnapshot) add(e *spb.AFTEntry) {
+	s[aftEntryKey(e)] = e
+}
+
+// aftDelta is the difference between two snapshots,
+// each list is sorted by entry key.
+type aftDelta struct {
+	added   []*spb.AFTEntry
+	removed []*spb.AFTEntry
+	changed []*aftChange
+}
+
+type aftChange struct {
+	key    string
+	old    *spb.AFTEntry
+	new    *spb.AFTEntry
+	fields []*fieldChange
+}
+
+// fieldChange is a field set to different values in two entries,
+// an unset field has an empty value.
+type fieldChange struct {
+	path string
+	old  string
+	new  string
+}
+
+func (d *aftDelta) empty() bool {
+	return len(d.added) == 0 && len(d.removed) == 0 && len(d.changed) == 0
+}
+
+// diffSnapshots returns the entries added, removed and changed from a to b.
+func diffSnapshots(a, b aftSnapshot) *aftDelta {
+	d := new(aftDelta)
+	for _, k := range sortedKeys(b) {
+		ae, ok := a[k]
+		if !ok {
+			d.added = append(d.added, b[k])
+			continue
+		}
+		if aftEntryEqual(ae, b[k]) {
+			continue
+		}
+		d.changed = append(d.changed, &aftChange{
+			key:    k,
+			old:    ae,
+			new:    b[k],
+			fields: diffFields(ae, b[k]),
+		})
+	}
+	for _, k := range sortedKeys(a) {
+		if _, ok := b[k]; !ok {
+			d.removed = append(d.removed, a[k])
+		}
+	}
+	return d
+}
+
+// aftEntryEqual returns true if a and b have the same fields set to the same values,
+// repeated message fields, such as a NHG next hops, are compared regardless of their order.
+func aftEntryEqual(a, b *spb.AFTEntry) bool {
+	return protoContains(a.ProtoReflect(), b.ProtoReflect()) &&
+		protoContains(b.ProtoReflect(), a.ProtoReflect())
+}
+
+func sortedKeys(s aftSnapshot) []string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffFields compares two messages field by field.
+func diffFields(a, b proto.Message) []*fieldChange {
+	return diffFieldMaps(
+		flattenFields(a.ProtoReflect(), "", nil),
+		flattenFields(b.ProtoReflect(), "", nil),
+	)
+}
+
+// diffFieldMaps compares two sets of fields returned by flattenFields.
+func diffFieldMaps(of, nf map[string]string) []*fieldChange {
+	paths := make([]string, 0, len(of)+len(nf))
+	for p := range of {
+		paths = append(paths, p)
+	}
+	for p := range nf {
+		if _, ok := of[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	changes := make([]*fieldChange, 0)
+	for _, p := range paths {
+		if of[p] == nf[p] {
+			continue
+		}
+		changes = append(changes, &fieldChange{path: p, old: of[p], new: nf[p]})
+	}
+	return changes
+}
+
+// flattenFields returns the set scalar fields of m keyed by their path,
+// e.g: ipv4.ipv4_entry.next_hop_group.value
+func flattenFields(m protoreflect.Message, prefix string, fields map[string]string) map[string]string {
+	if fields == nil {
+		fields = make(map[string]string)
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		path := string(fd.Name())
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		switch {
+		case fd.IsList():
+			l := v.List()
+			for i := 0; i < l.Len(); i++ {
+				flattenValue(fd, l.Get(i), fmt.Sprintf("%s[%d]", path, i), fields)
+			}
+		case fd.IsMap():
+			v.Map().Range(func(mk protoreflect.MapKey, mv protoreflect.Value) bool {
+				flattenValue(fd.MapValue(), mv, fmt.Sprintf("%s[%v]", path, mk.Interface()), fields)
+				return true
+			})
+		default:
+			flattenValue(fd, v, path, fields)
+		}
+		return true
+	})
+	return fields
+}
+
+func flattenValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, path string, fields map[string]string) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		flattenFields(v.Message(), path, fields)
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			fields[path] = string(ev.Name())
+			return
+		}
+		fields[path] = fmt.Sprint(v.Enum())
+	case protoreflect.BytesKind:
+		fields[path] = fmt.Sprintf("%q", v.Bytes())
+	default:
+		fields[path] = fmt.Sprint(v.Interface())
+	}
+}
+
+// fieldsString returns the field changes, one per line, indented with indent.
+func (c *aftChange) fieldsString(indent string) string {
+	sb := new(strings.Builder)
+	for _, f := range c.fields {
+		fmt.Fprintf(sb, "%s%s: %s -> %s\n", indent, f.path, orUnset(f.old), orUnset(f.new))
+	}
+	return sb.String()
+}
+
+func orUnset(s string) string {
+	if s == "" {
+		return "<unset>"
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	a := newAFTSnapshot(
+		ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1),
+		ipv4Entry(t, "DEFAULT", "10.0.1.0/24", 1),
+		nhgEntry(t, "DEFAULT", 1, 1, 2),
+		nhEntry(t, "DEFAULT", 1, "192.0.2.1"),
+		nhEntry(t, "DEFAULT", 2, "192.0.2.2"),
+		testEntry(t, `network_instance: "DEFAULT" ipv4: {prefix: "10.0.3.0/24" ipv4_entry: {next_hop_group: {value: 1} entry_metadata: {value: "md"}}}`),
+	)
+	b := newAFTSnapshot(
+		// changed NHG
+		ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 2),
+		// same next hops, in another order
+		nhgEntry(t, "DEFAULT", 1, 2, 1),
+		nhEntry(t, "DEFAULT", 1, "192.0.2.1"),
+		// changed address
+		nhEntry(t, "DEFAULT", 2, "192.0.2.20"),
+		// removed metadata
+		ipv4Entry(t, "DEFAULT", "10.0.3.0/24", 1),
+		// added
+		ipv4Entry(t, "vrf1", "10.0.0.0/24", 1),
+		nhgEntry(t, "DEFAULT", 2, 1),
+	)
+	d := diffSnapshots(a, b)
+	if got, want := entryKeys(d.added), []string{"[DEFAULT] nhg 2", "[vrf1] ipv4 10.0.0.0/24"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("added = %v, want %v", got, want)
+	}
+	if got, want := entryKeys(d.removed), []string{"[DEFAULT] ipv4 10.0.1.0/24"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removed = %v, want %v", got, want)
+	}
+	wantChanged := []struct {
+		key    string
+		fields string
+	}{
+		{
+			key:    "[DEFAULT] ipv4 10.0.0.0/24",
+			fields: "  ipv4.ipv4_entry.next_hop_group.value: 1 -> 2\n",
+		},
+		{
+			key:    "[DEFAULT] ipv4 10.0.3.0/24",
+			fields: "  ipv4.ipv4_entry.entry_metadata.value: \"md\" -> <unset>\n",
+		},
+		{
+			key:    "[DEFAULT] nh 2",
+			fields: "  next_hop.next_hop.ip_address.value: 192.0.2.2 -> 192.0.2.20\n",
+		},
+	}
+	if len(d.changed) != len(wantChanged) {
+		t.Fatalf("got %d changed entries, want %d", len(d.changed), len(wantChanged))
+	}
+	for i, c := range d.changed {
+		if c.key != wantChanged[i].key {
+			t.Errorf("changed %d: key = %q, want %q", i, c.key, wantChanged[i].key)
+		}
+		if got := c.fieldsString("  "); got != wantChanged[i].fields {
+			t.Errorf("changed %d: fields = %q, want %q", i, got, wantChanged[i].fields)
+		}
+	}
+	if d.empty() {
+		t.Errorf("empty() = true, want false")
+	}
+	if !diffSnapshots(a, a).empty() {
+		t.Errorf("diff of a snapshot with itself is not empty")
+	}
+}
+
+func TestAftEntryEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *spb.AFTEntry
+		want bool
+	}{
+		{
+			name: "same",
+			a:    nhgEntry(t, "DEFAULT", 1, 1, 2),
+			b:    nhgEntry(t, "DEFAULT", 1, 1, 2),
+			want: true,
+		},
+		{
+			name: "next_hops_order",
+			a:    nhgEntry(t, "DEFAULT", 1, 1, 2),
+			b:    nhgEntry(t, "DEFAULT", 1, 2, 1),
+			want: true,
+		},
+		{
+			name: "extra_next_hop",
+			a:    nhgEntry(t, "DEFAULT", 1, 1, 2),
+			b:    nhgEntry(t, "DEFAULT", 1, 1, 2, 3),
+		},
+		{
+			name: "extra_field",
+			a:    ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1),
+			b:    testEntry(t, `network_instance: "DEFAULT" ipv4: {prefix: "10.0.0.0/24" ipv4_entry: {next_hop_group: {value: 1} entry_metadata: {value: "md"}}}`),
+		},
+		{
+			name: "fib_status",
+			a:    ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1),
+			b:    testEntry(t, `network_instance: "DEFAULT" fib_status: PROGRAMMED ipv4: {prefix: "10.0.0.0/24" ipv4_entry: {next_hop_group: {value: 1}}}`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := aftEntryEqual(tt.a, tt.b); got != tt.want {
+				t.Errorf("aftEntryEqual() = %v, want %v", got, tt.want)
+			}
+			if got := aftEntryEqual(tt.b, tt.a); got != tt.want {
+				t.Errorf("aftEntryEqual() reversed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+// testEntry parses an AFT entry in prototext format.
+func testEntry(t testing.TB, text string) *spb.AFTEntry {
+	t.Helper()
+	e := new(spb.AFTEntry)
+	err := prototext.Unmarshal([]byte(text), e)
+	if err != nil {
+		t.Fatalf("failed to parse entry %q: %v", text, err)
+	}
+	return e
+}
+
+func ipv4Entry(t testing.TB, ni, prefix string, nhg uint64) *spb.AFTEntry {
+	t.Helper()
+	return testEntry(t, fmt.Sprintf(`network_instance: %q ipv4: {prefix: %q ipv4_entry: {next_hop_group: {value: %d}}}`, ni, prefix, nhg))
+}
+
+func ipv6Entry(t testing.TB, ni, prefix string, nhg uint64) *spb.AFTEntry {
+	t.Helper()
+	return testEntry(t, fmt.Sprintf(`network_instance: %q ipv6: {prefix: %q ipv6_entry: {next_hop_group: {value: %d}}}`, ni, prefix, nhg))
+}
+
+func nhgEntry(t testing.TB, ni string, id uint64, nhs ...uint64) *spb.AFTEntry {
+	t.Helper()
+	text := fmt.Sprintf(`network_instance: %q next_hop_group: {id: %d next_hop_group: {`, ni, id)
+	for _, nh := range nhs {
+		text += fmt.Sprintf(` next_hop: {index: %d next_hop: {weight: {value: 1}}}`, nh)
+	}
+	return testEntry(t, text+"}}")
+}
+
+func nhEntry(t testing.TB, ni string, index uint64, ip string) *spb.AFTEntry {
+	t.Helper()
+	return testEntry(t, fmt.Sprintf(`network_instance: %q next_hop: {index: %d next_hop: {ip_address: {value: %q}}}`, ni, index, ip))
+}
+
+// entryKeys returns the aftEntryKey of the entries, in order.
+func entryKeys(entries []*spb.AFTEntry) []string {
+	keys := make([]string, 0, len(entries))
+	for _, e := range entries {
+		keys = append(keys, aftEntryKey(e))
+	}
+	return keys
+}
+
+func TestAftEntryKey(t *testing.T) {
+	tests := []struct {
+		entry *spb.AFTEntry
+		want  string
+	}{
+		{entry: ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1), want: "[DEFAULT] ipv4 10.0.0.0/24"},
+		{entry: ipv6Entry(t, "vrf1", "2001:db8::/64", 1), want: "[vrf1] ipv6 2001:db8::/64"},
+		{entry: nhgEntry(t, "DEFAULT", 10, 1, 2), want: "[DEFAULT] nhg 10"},
+		{entry: nhEntry(t, "DEFAULT", 1, "192.0.2.1"), want: "[DEFAULT] nh 1"},
+		{entry: testEntry(t, `network_instance: "DEFAULT" mpls: {label_uint64: 100}`), want: "[DEFAULT] mpls 100"},
+	}
+	for _, tt := range tests {
+		if got := aftEntryKey(tt.entry); got != tt.want {
+			t.Errorf("aftEntryKey() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestAftEntryNHG(t *testing.T) {
+	e := testEntry(t, `network_instance: "vrf1" ipv4: {prefix: "10.0.0.0/24" ipv4_entry: {next_hop_group: {value: 7} next_hop_group_network_instance: {value: "DEFAULT"}}}`)
+	nhg, ok := aftEntryNHG(e)
+	if !ok || nhg != 7 {
+		t.Errorf("aftEntryNHG() = %d, %v, want 7, true", nhg, ok)
+	}
+	if ni := aftEntryNHGNetworkInstance(e); ni != "DEFAULT" {
+		t.Errorf("aftEntryNHGNetworkInstance() = %q, want %q", ni, "DEFAULT")
+	}
+	if _, ok := aftEntryNHG(nhEntry(t, "DEFAULT", 1, "192.0.2.1")); ok {
+		t.Errorf("aftEntryNHG() of a NH entry returned a NHG")
+	}
+	if got := nhgNextHops(nhgEntry(t, "DEFAULT", 1, 3, 1, 2)); fmt.Sprint(got) != "[3 1 2]" {
+		t.Errorf("nhgNextHops() = %v, want [3 1 2]", got)
+	}
+}
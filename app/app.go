@@ -40,6 +40,8 @@ type App struct {
 	gnmi.UnimplementedGNMIServer
 	grpcServer  *grpc.Server
 	unaryRPCsem *semaphore.Weighted
+	// --max-concurrency limit
+	targetsSem *semaphore.Weighted
 	//
 	Logger *log.Entry
 	//
@@ -81,19 +83,50 @@ func (a *App) InitGlobalFlags() {
 	a.RootCmd.PersistentFlags().StringSliceVarP(&a.Config.GlobalFlags.Address, "address", "a", []string{}, "comma separated gRIBI targets addresses")
 	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.Username, "username", "u", "", "username")
 	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.Password, "password", "p", "", "password")
+	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.PasswordFile, "password-file", "", "", "path to a file containing the password")
+	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.PasswordEnv, "password-env", "", "", "name of an environment variable containing the password")
+	a.RootCmd.PersistentFlags().BoolVarP(&a.Config.GlobalFlags.AskPassword, "ask-password", "", false, "prompt for the password")
+	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.Token, "token", "", "", "bearer token sent as authorization metadata")
+	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.TokenFile, "token-file", "", "", "path to a file containing the bearer token")
 	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.Port, "port", "", defaultGrpcPort, "gRPC port")
 	a.RootCmd.PersistentFlags().BoolVarP(&a.Config.GlobalFlags.Insecure, "insecure", "", false, "insecure connection")
 	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.TLSCa, "tls-ca", "", "", "tls certificate authority")
 	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.TLSCert, "tls-cert", "", "", "tls certificate")
 	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.TLSKey, "tls-key", "", "", "tls key")
+	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.TLSServerName, "tls-server-name", "", "", "server name used to verify the target certificate, defaults to the target host")
 	a.RootCmd.PersistentFlags().DurationVarP(&a.Config.GlobalFlags.Timeout, "timeout", "", 10*time.Second, "grpc timeout, valid formats: 10s, 1m30s, 1h")
+	a.RootCmd.PersistentFlags().DurationVarP(&a.Config.GlobalFlags.GetTimeout, "get-timeout", "", 0, "bounds each Get RPC attempt, 0 means no timeout")
+	a.RootCmd.PersistentFlags().DurationVarP(&a.Config.GlobalFlags.FlushTimeout, "flush-timeout", "", 0, "bounds each Flush RPC attempt, 0 means no timeout")
+	a.RootCmd.PersistentFlags().DurationVarP(&a.Config.GlobalFlags.AckTimeout, "ack-timeout", "", 0, "bounds the wait for the acknowledgment of each Modify operation, 0 means no timeout")
+	a.RootCmd.PersistentFlags().IntVarP(&a.Config.GlobalFlags.RetryMaxAttempts, "retry-max-attempts", "", 1, "number of Flush and Get RPC attempts, 1 disables the retries")
+	a.RootCmd.PersistentFlags().DurationVarP(&a.Config.GlobalFlags.RetryBackoff, "retry-backoff", "", time.Second, "wait before the first retry, doubled after each retry")
+	a.RootCmd.PersistentFlags().DurationVarP(&a.Config.GlobalFlags.RetryMaxBackoff, "retry-max-backoff", "", 30*time.Second, "upper bound of the wait between two attempts")
+	a.RootCmd.PersistentFlags().StringSliceVarP(&a.Config.GlobalFlags.RetryCodes, "retry-codes", "", []string{"UNAVAILABLE"}, "comma separated gRPC status codes of the failed attempts that are retried")
 	a.RootCmd.PersistentFlags().BoolVarP(&a.Config.GlobalFlags.Debug, "debug", "d", false, "debug mode")
 	a.RootCmd.PersistentFlags().BoolVarP(&a.Config.GlobalFlags.SkipVerify, "skip-verify", "", false, "skip verify tls connection")
 	a.RootCmd.PersistentFlags().BoolVarP(&a.Config.GlobalFlags.ProxyFromEnv, "proxy-from-env", "", false, "use proxy from environment")
 	a.RootCmd.PersistentFlags().IntVarP(&a.Config.GlobalFlags.MaxRcvMsgSize, "max-rcv-msg-size", "", 1024*1024*4, "max receive message size in bytes")
-	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.Format, "format", "", "text", "output format, one of: text, json")
+	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.Format, "format", "", "text", "output format, the accepted values depend on the command, see each command documentation")
 	//
 	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.ElectionID, "election-id", "", "1:0", "gRIBI client electionID, format is high:low where both high and low are uint64")
+	//
+	a.RootCmd.PersistentFlags().StringSliceVarP(&a.Config.GlobalFlags.TargetNames, "target", "", []string{}, "comma separated names or addresses of the targets to run the command against")
+	a.RootCmd.PersistentFlags().StringSliceVarP(&a.Config.GlobalFlags.TargetGroups, "group", "", []string{}, "comma separated groups of the targets to run the command against")
+	a.RootCmd.PersistentFlags().StringSliceVarP(&a.Config.GlobalFlags.TargetTags, "tag", "", []string{}, "comma separated tags of the targets to run the command against, format is key=value or key")
+	a.RootCmd.PersistentFlags().StringSliceVarP(&a.Config.GlobalFlags.ExcludeTargets, "exclude-target", "", []string{}, "comma separated names or addresses of the targets to exclude")
+	a.RootCmd.PersistentFlags().StringSliceVarP(&a.Config.GlobalFlags.ExcludeGroups, "exclude-group", "", []string{}, "comma separated groups of the targets to exclude")
+	a.RootCmd.PersistentFlags().StringSliceVarP(&a.Config.GlobalFlags.ExcludeTags, "exclude-tag", "", []string{}, "comma separated tags of the targets to exclude, format is key=value or key")
+	//
+	a.RootCmd.PersistentFlags().IntVarP(&a.Config.GlobalFlags.MaxConcurrency, "max-concurrency", "", 0, "maximum number of targets the command runs against at once, 0 means no limit")
+	a.RootCmd.PersistentFlags().IntVarP(&a.Config.GlobalFlags.Canary, "canary", "", 0, "number of targets the modify, flush and workflow commands run against before the others")
+	a.RootCmd.PersistentFlags().IntVarP(&a.Config.GlobalFlags.Batch, "batch", "", 0, "number of targets per rollout stage after the canary targets, 0 means all the remaining targets")
+	a.RootCmd.PersistentFlags().DurationVarP(&a.Config.GlobalFlags.BatchWait, "batch-wait", "", 0, "wait between two rollout stages")
+	a.RootCmd.PersistentFlags().IntVarP(&a.Config.GlobalFlags.MaxFailures, "max-failures", "", 0, "number of failed targets tolerated before the rollout halts")
+	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.VerifyFile, "verify-file", "", "", "workflow file run against the targets of each rollout stage to verify them")
+	//
+	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.DataFile, "data-file", "", "", "yaml, json or csv file read by the lookup template function")
+	a.RootCmd.PersistentFlags().StringVarP(&a.Config.GlobalFlags.VarsDir, "vars-dir", "", "", "directory of the groups and targets template vars files, defaults to the vars directory next to the template file")
+	a.RootCmd.PersistentFlags().StringArrayVarP(&a.Config.GlobalFlags.Vars, "var", "", []string{}, "template variable override, format is key=value, a dotted key sets a nested variable")
 }
 
 func (a *App) PreRun(cmd *cobra.Command, args []string) error {
@@ -104,6 +137,27 @@ func (a *App) PreRun(cmd *cobra.Command, args []string) error {
 		grpclog.SetLogger(a.Logger) //lint:ignore SA1019 .
 	}
 	// a.Config.SetPersistantFlagsFromFile(a.RootCmd)
+	err := a.Config.ValidateRPCFlags()
+	if err != nil {
+		return err
+	}
+	err = a.Config.ValidateRolloutFlags()
+	if err != nil {
+		return err
+	}
+	if a.Config.MaxConcurrency > 0 {
+		a.targetsSem = semaphore.NewWeighted(int64(a.Config.MaxConcurrency))
+	}
+	err = a.Config.ResolveCredentials()
+	if err != nil {
+		return err
+	}
+	if a.Config.AskPassword && a.Config.Password == "" {
+		a.Config.Password, err = promptPassword("password: ")
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -136,12 +190,20 @@ func (a *App) createBaseDialOpts() []grpc.DialOption {
 	return opts
 }
 
-func appendCredentials(ctx context.Context, tc *config.TargetConfig) context.Context {
+// appendMetadata adds the target's metadata, username, password and token
+// to the outgoing metadata, the token is sent as a bearer authorization.
+func appendMetadata(ctx context.Context, tc *config.TargetConfig) context.Context {
+	for k, v := range tc.Metadata {
+		ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+	}
 	if tc.Username != nil {
 		ctx = metadata.AppendToOutgoingContext(ctx, "username", *tc.Username)
 	}
 	if tc.Password != nil {
 		ctx = metadata.AppendToOutgoingContext(ctx, "password", *tc.Password)
 	}
+	if tc.Token != nil && *tc.Token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+*tc.Token)
+	}
 	return ctx
 }
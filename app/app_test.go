@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/karimra/gribic/config"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestAppendMetadata(t *testing.T) {
+	empty := ""
+	user, pass, token := "admin", "secret", "tok"
+	tests := []struct {
+		name string
+		tc   *config.TargetConfig
+		want metadata.MD
+	}{
+		{
+			name: "unset",
+			tc:   &config.TargetConfig{},
+			want: metadata.MD{},
+		},
+		{
+			name: "empty_credentials",
+			tc:   &config.TargetConfig{Username: &empty, Password: &empty, Token: &empty},
+			want: metadata.MD{"username": {""}, "password": {""}},
+		},
+		{
+			name: "credentials_and_token",
+			tc: &config.TargetConfig{
+				Username: &user,
+				Password: &pass,
+				Token:    &token,
+				Metadata: map[string]string{"x-tenant": "blue"},
+			},
+			want: metadata.MD{
+				"username":      {"admin"},
+				"password":      {"secret"},
+				"authorization": {"Bearer tok"},
+				"x-tenant":      {"blue"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := appendMetadata(context.Background(), tt.tc)
+			md, _ := metadata.FromOutgoingContext(ctx)
+			if md == nil {
+				md = metadata.MD{}
+			}
+			if !reflect.DeepEqual(md, tt.want) {
+				t.Errorf("metadata = %v, want %v", md, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/karimra/gribic/api"
+	"github.com/karimra/gribic/config"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// consistency command exit codes
+const (
+	consistencyExitDeviation = 1
+	consistencyExitError     = 2
+)
+
+// referenceMajority is the reference name used when no reference target is set.
+const referenceMajority = "majority"
+
+func (a *App) InitConsistencyFlags(cmd *cobra.Command) {
+	cmd.ResetFlags()
+	//
+	cmd.Flags().StringVarP(&a.Config.ConsistencyReference, "reference", "", "", "reference target name, the targets are compared with the majority if not set")
+	cmd.Flags().StringVarP(&a.Config.ConsistencyNetworkInstance, "ns", "", "", "network instance name, an empty network-instance name means compare all instances.")
+	cmd.Flags().StringVarP(&a.Config.ConsistencyAFT, "aft", "", "ALL", "AFT type to compare, one of: ALL, IPv4, IPv6, NH, NHG, MPLS, MAC or PF")
+	cmd.Flags().StringSliceVarP(&a.Config.ConsistencyIgnore, "ignore", "", []string{}, "entry field paths to ignore on all targets, e.g: next_hop.next_hop.ip_address")
+	cmd.Flags().StringVarP(&a.Config.ConsistencyNormalizeFile, "normalize", "", "", "normalization file, rendered as a template per target, with the fields to ignore and the values to replace")
+	cmd.Flags().BoolVarP(&a.Config.ConsistencyFIBStatus, "fib-status", "", false, "compare the entries FIB status")
+	//
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		a.Config.FileConfig.BindPFlag(fmt.Sprintf("%s-%s", cmd.Name(), flag.Name), flag)
+	})
+}
+
+// consistencyReport lists the entries that differ between the targets,
+// a target deviates if its entry differs from the reference one.
+type consistencyReport struct {
+	Reference  string            `json:"reference"`
+	Targets    []string          `json:"targets"`
+	Entries    int               `json:"entries"`
+	Deviations []*entryDeviation `json:"deviations"`
+	Failed     map[string]string `json:"failed,omitempty"`
+	// number of deviating entries per target
+	deviating map[string]int
+}
+
+type entryDeviation struct {
+	Key string `json:"key"`
+	// set if there is no majority for this entry,
+	// the reference is the entry of the first target in the largest group.
+	NoMajority bool               `json:"no-majority,omitempty"`
+	Reference  string             `json:"reference"`
+	Targets    []*targetDeviation `json:"targets"`
+}
+
+type targetDeviation struct {
+	Target string `json:"target"`
+	// the entry is in the reference and not in the target
+	Missing bool `json:"missing,omitempty"`
+	// the entry is in the target and not in the reference
+	Unexpected bool `json:"unexpected,omitempty"`
+	// the fields values in the reference (a) and in the target (b)
+	Fields []*diffJSONField `json:"fields,omitempty"`
+}
+
+// ConsistencyRunE runs a Get RPC on all the targets concurrently
+// and reports the entries that deviate from the majority or from the reference target.
+// It returns an *ExitError with code 1 if any target deviates and code 2 on failure.
+func (a *App) ConsistencyRunE(cmd *cobra.Command, args []string) error {
+	switch a.Config.Format {
+	case diffFormatText, diffFormatJSON:
+	default:
+		return &ExitError{Code: consistencyExitError, Err: fmt.Errorf("unknown --format %q, the consistency command accepts: text or json", a.Config.Format)}
+	}
+	aftReq, err := api.NewGetRequest(api.AFTType(a.Config.ConsistencyAFT))
+	if err != nil {
+		return &ExitError{Code: consistencyExitError, Err: err}
+	}
+	tcs, err := a.Config.GetTargets()
+	if err != nil {
+		return &ExitError{Code: consistencyExitError, Err: err}
+	}
+	if len(tcs) < 2 {
+		return &ExitError{Code: consistencyExitError, Err: errors.New("at least 2 targets are required")}
+	}
+	if a.Config.ConsistencyReference != "" {
+		if _, ok := tcs[a.Config.ConsistencyReference]; !ok {
+			return &ExitError{Code: consistencyExitError, Err: fmt.Errorf("unknown reference target %q", a.Config.ConsistencyReference)}
+		}
+	}
+	norms := make(map[string]*config.Normalization, len(tcs))
+	for name := range tcs {
+		norms[name], err = a.Config.GetNormalization(name)
+		if err != nil {
+			return &ExitError{Code: consistencyExitError, Err: fmt.Errorf("%q: failed to read normalization: %v", name, err)}
+		}
+	}
+
+	m := new(sync.Mutex)
+	snapshots := make(map[string]aftSnapshot, len(tcs))
+	failed := make(map[string]string)
+	wg := new(sync.WaitGroup)
+	wg.Add(len(tcs))
+	for name, tc := range tcs {
+		go func(name string, tc *config.TargetConfig) {
+			defer wg.Done()
+			release, err := a.acquireTarget(a.ctx)
+			if err != nil {
+				m.Lock()
+				failed[name] = err.Error()
+				m.Unlock()
+				return
+			}
+			s, err := a.getSnapshot(a.ctx, tc)
+			release()
+			m.Lock()
+			defer m.Unlock()
+			if err != nil {
+				a.Logger.Errorf("%q Get RPC failed: %v", name, err)
+				failed[name] = err.Error()
+				return
+			}
+			snapshots[name] = s
+		}(name, tc)
+	}
+	wg.Wait()
+	if _, ok := failed[a.Config.ConsistencyReference]; ok {
+		return &ExitError{Code: consistencyExitError, Err: fmt.Errorf("reference target %q Get RPC failed: %s", a.Config.ConsistencyReference, failed[a.Config.ConsistencyReference])}
+	}
+	if len(snapshots) < 2 {
+		return &ExitError{Code: consistencyExitError, Err: fmt.Errorf("%d targets failed", len(failed))}
+	}
+
+	fields := make(map[string]map[string]map[string]string, len(snapshots))
+	for name, s := range snapshots {
+		a.filterConsistencySnapshot(s, aftReq.GetAft())
+		fields[name] = normalizeSnapshot(s, norms[name])
+	}
+	r := checkConsistency(fields, a.Config.ConsistencyReference)
+	r.Failed = failed
+
+	switch a.Config.Format {
+	case diffFormatJSON:
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return &ExitError{Code: consistencyExitError, Err: err}
+		}
+		fmt.Println(string(b))
+	default:
+		fmt.Print(r.String())
+	}
+	if len(failed) > 0 {
+		return &ExitError{Code: consistencyExitError, Err: fmt.Errorf("%d targets failed", len(failed))}
+	}
+	if len(r.Deviations) > 0 {
+		return &ExitError{Code: consistencyExitDeviation}
+	}
+	return nil
+}
+
+// filterConsistencySnapshot removes the entries not selected by the --ns and --aft flags,
+// and clears the entries FIB status unless --fib-status is set.
+func (a *App) filterConsistencySnapshot(s aftSnapshot, aft spb.AFTType) {
+	for k, e := range s {
+		if a.Config.ConsistencyNetworkInstance != "" && e.GetNetworkInstance() != a.Config.ConsistencyNetworkInstance {
+			delete(s, k)
+			continue
+		}
+		if aft != spb.AFTType_ALL && aft != aftEntryAFTType(e) {
+			delete(s, k)
+			continue
+		}
+		if !a.Config.ConsistencyFIBStatus {
+			e.FibStatus = spb.AFTEntry_UNAVAILABLE
+		}
+	}
+}
+
+// normalizeSnapshot returns the fields of each entry of the snapshot,
+// without the ignored fields and with the replaced values.
+// The next hops of a NHG are sorted by index, so that their order does not matter.
+func normalizeSnapshot(s aftSnapshot, n *config.Normalization) map[string]map[string]string {
+	r := make(map[string]map[string]string, len(s))
+	for k, e := range s {
+		if nhg := e.GetNextHopGroup().GetNextHopGroup(); nhg != nil {
+			sort.SliceStable(nhg.NextHop, func(i, j int) bool {
+				return nhg.NextHop[i].GetIndex() < nhg.NextHop[j].GetIndex()
+			})
+		}
+		fields := flattenFields(e.ProtoReflect(), "", nil)
+	OUTER:
+		for p, v := range fields {
+			for _, ip := range n.Ignore {
+				if p == ip || strings.HasPrefix(p, ip+".") || strings.HasPrefix(p, ip+"[") {
+					delete(fields, p)
+					continue OUTER
+				}
+			}
+			if rv, ok := n.Replace[v]; ok {
+				fields[p] = rv
+			}
+		}
+		r[k] = fields
+	}
+	return r
+}
+
+// checkConsistency compares the entries fields of each target,
+// with the reference target entries or with the majority if ref is empty.
+func checkConsistency(fields map[string]map[string]map[string]string, ref string) *consistencyReport {
+	r := &consistencyReport{
+		Reference:  ref,
+		Targets:    make([]string, 0, len(fields)),
+		Deviations: make([]*entryDeviation, 0),
+		deviating:  make(map[string]int),
+	}
+	if ref == "" {
+		r.Reference = referenceMajority
+	}
+	keys := make(map[string]struct{})
+	for name, tf := range fields {
+		r.Targets = append(r.Targets, name)
+		for k := range tf {
+			keys[k] = struct{}{}
+		}
+	}
+	sort.Strings(r.Targets)
+	sortedKeys := make([]string, 0, len(keys))
+	for k := range keys {
+		sortedKeys = append(sortedKeys, k)
+	}
+	sort.Strings(sortedKeys)
+	r.Entries = len(sortedKeys)
+
+	for _, k := range sortedKeys {
+		// group the targets with identical entries
+		groups := make(map[string][]string)
+		for _, name := range r.Targets {
+			sig := fieldsSignature(fields[name][k])
+			groups[sig] = append(groups[sig], name)
+		}
+		if len(groups) == 1 {
+			continue
+		}
+		d := &entryDeviation{Key: k, Reference: ref}
+		if ref == "" {
+			d.Reference, d.NoMajority = majority(groups)
+		}
+		rf, inRef := fields[d.Reference][k]
+		for _, name := range r.Targets {
+			tf, inTarget := fields[name][k]
+			td := &targetDeviation{Target: name}
+			switch {
+			case inRef && !inTarget:
+				td.Missing = true
+			case !inRef && inTarget:
+				td.Unexpected = true
+			case !inRef && !inTarget:
+				continue
+			default:
+				for _, c := range diffFieldMaps(rf, tf) {
+					td.Fields = append(td.Fields, &diffJSONField{Path: c.path, A: c.old, B: c.new})
+				}
+				if len(td.Fields) == 0 {
+					continue
+				}
+			}
+			d.Targets = append(d.Targets, td)
+			r.deviating[name]++
+		}
+		r.Deviations = append(r.Deviations, d)
+	}
+	return r
+}
+
+// fieldsSignature returns a string identifying a set of fields,
+// nil fields (an absent entry) have an empty signature.
+func fieldsSignature(fields map[string]string) string {
+	if fields == nil {
+		return ""
+	}
+	paths := make([]string, 0, len(fields))
+	for p := range fields {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	sb := new(strings.Builder)
+	sb.WriteString("entry")
+	for _, p := range paths {
+		fmt.Fprintf(sb, "\n%s=%s", p, fields[p])
+	}
+	return sb.String()
+}
+
+// majority returns the first target of the largest group,
+// and whether other groups have the same size.
+func majority(groups map[string][]string) (string, bool) {
+	var best []string
+	var tie bool
+	for _, g := range groups {
+		switch {
+		case len(g) > len(best):
+			best = g
+			tie = false
+		case len(g) == len(best):
+			tie = true
+			if g[0] < best[0] {
+				best = g
+			}
+		}
+	}
+	return best[0], tie
+}
+
+func (r *consistencyReport) String() string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "compared %d entries on %d targets with the %s\n", r.Entries, len(r.Targets), r.referenceString())
+	for _, d := range r.Deviations {
+		if d.NoMajority {
+			fmt.Fprintf(sb, "%s: no majority, compared with %q\n", d.Key, d.Reference)
+		} else {
+			fmt.Fprintf(sb, "%s:\n", d.Key)
+		}
+		for _, td := range d.Targets {
+			switch {
+			case td.Missing:
+				fmt.Fprintf(sb, "    %q: missing\n", td.Target)
+			case td.Unexpected:
+				fmt.Fprintf(sb, "    %q: unexpected\n", td.Target)
+			default:
+				fmt.Fprintf(sb, "    %q:\n", td.Target)
+				for _, f := range td.Fields {
+					fmt.Fprintf(sb, "        %s: %s -> %s\n", f.Path, orUnset(f.A), orUnset(f.B))
+				}
+			}
+		}
+	}
+	sb.WriteString("summary:\n")
+	for _, name := range r.Targets {
+		switch n := r.deviating[name]; {
+		case n > 0:
+			fmt.Fprintf(sb, "    %q: %d deviating entries\n", name, n)
+		default:
+			fmt.Fprintf(sb, "    %q: consistent\n", name)
+		}
+	}
+	failed := make([]string, 0, len(r.Failed))
+	for name := range r.Failed {
+		failed = append(failed, name)
+	}
+	sort.Strings(failed)
+	for _, name := range failed {
+		fmt.Fprintf(sb, "    %q: failed: %s\n", name, r.Failed[name])
+	}
+	return sb.String()
+}
+
+func (r *consistencyReport) referenceString() string {
+	if r.Reference == referenceMajority {
+		return "majority"
+	}
+	return fmt.Sprintf("reference target %q", r.Reference)
+}
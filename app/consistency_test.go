@@ -0,0 +1,206 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/karimra/gribic/config"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	nh := func(ip string) map[string]string {
+		return map[string]string{
+			"network_instance":                   "DEFAULT",
+			"next_hop.index":                     "1",
+			"next_hop.next_hop.ip_address.value": ip,
+		}
+	}
+	type deviation struct {
+		key        string
+		reference  string
+		noMajority bool
+		// target -> "missing", "unexpected" or the changed fields paths
+		targets map[string]string
+	}
+	tests := []struct {
+		name   string
+		fields map[string]map[string]map[string]string
+		ref    string
+		want   []deviation
+	}{
+		{
+			name: "consistent",
+			fields: map[string]map[string]map[string]string{
+				"r1": {"nh1": nh("192.0.2.1")},
+				"r2": {"nh1": nh("192.0.2.1")},
+			},
+		},
+		{
+			name: "majority",
+			fields: map[string]map[string]map[string]string{
+				"r1": {"nh1": nh("192.0.2.1")},
+				"r2": {"nh1": nh("192.0.2.1")},
+				"r3": {"nh1": nh("192.0.2.3")},
+			},
+			want: []deviation{
+				{key: "nh1", reference: "r1", targets: map[string]string{"r3": "next_hop.next_hop.ip_address.value"}},
+			},
+		},
+		{
+			name: "majority_not_first",
+			fields: map[string]map[string]map[string]string{
+				"r1": {"nh1": nh("192.0.2.1")},
+				"r2": {"nh1": nh("192.0.2.3")},
+				"r3": {"nh1": nh("192.0.2.3")},
+			},
+			want: []deviation{
+				{key: "nh1", reference: "r2", targets: map[string]string{"r1": "next_hop.next_hop.ip_address.value"}},
+			},
+		},
+		{
+			name: "tie_two_targets",
+			fields: map[string]map[string]map[string]string{
+				"r2": {"nh1": nh("192.0.2.2")},
+				"r1": {"nh1": nh("192.0.2.1")},
+			},
+			want: []deviation{
+				{key: "nh1", reference: "r1", noMajority: true, targets: map[string]string{"r2": "next_hop.next_hop.ip_address.value"}},
+			},
+		},
+		{
+			name: "tie_groups",
+			fields: map[string]map[string]map[string]string{
+				"r1": {"nh1": nh("192.0.2.1")},
+				"r2": {"nh1": nh("192.0.2.2")},
+				"r3": {"nh1": nh("192.0.2.1")},
+				"r4": {"nh1": nh("192.0.2.2")},
+			},
+			want: []deviation{
+				{key: "nh1", reference: "r1", noMajority: true, targets: map[string]string{
+					"r2": "next_hop.next_hop.ip_address.value",
+					"r4": "next_hop.next_hop.ip_address.value",
+				}},
+			},
+		},
+		{
+			name: "reference_in_a_tie",
+			fields: map[string]map[string]map[string]string{
+				"r1": {"nh1": nh("192.0.2.1")},
+				"r2": {"nh1": nh("192.0.2.2")},
+			},
+			ref: "r2",
+			want: []deviation{
+				{key: "nh1", reference: "r2", targets: map[string]string{"r1": "next_hop.next_hop.ip_address.value"}},
+			},
+		},
+		{
+			name: "reference_in_the_minority",
+			fields: map[string]map[string]map[string]string{
+				"r1": {"nh1": nh("192.0.2.1")},
+				"r2": {"nh1": nh("192.0.2.1")},
+				"r3": {"nh1": nh("192.0.2.3")},
+			},
+			ref: "r3",
+			want: []deviation{
+				{key: "nh1", reference: "r3", targets: map[string]string{
+					"r1": "next_hop.next_hop.ip_address.value",
+					"r2": "next_hop.next_hop.ip_address.value",
+				}},
+			},
+		},
+		{
+			name: "missing_and_unexpected",
+			fields: map[string]map[string]map[string]string{
+				"r1": {"nh1": nh("192.0.2.1")},
+				"r2": {"nh1": nh("192.0.2.1"), "nh2": nh("192.0.2.2")},
+				"r3": {},
+			},
+			want: []deviation{
+				{key: "nh1", reference: "r1", targets: map[string]string{"r3": "missing"}},
+				{key: "nh2", reference: "r1", targets: map[string]string{"r2": "unexpected"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := checkConsistency(tt.fields, tt.ref)
+			wantRef := tt.ref
+			if wantRef == "" {
+				wantRef = referenceMajority
+			}
+			if r.Reference != wantRef {
+				t.Errorf("report reference = %q, want %q", r.Reference, wantRef)
+			}
+			if len(r.Deviations) != len(tt.want) {
+				t.Fatalf("got %d deviations, want %d", len(r.Deviations), len(tt.want))
+			}
+			for i, d := range r.Deviations {
+				w := tt.want[i]
+				if d.Key != w.key || d.Reference != w.reference || d.NoMajority != w.noMajority {
+					t.Errorf("deviation %d = %s/%s/%v, want %s/%s/%v", i, d.Key, d.Reference, d.NoMajority, w.key, w.reference, w.noMajority)
+				}
+				got := make(map[string]string)
+				for _, td := range d.Targets {
+					switch {
+					case td.Missing:
+						got[td.Target] = "missing"
+					case td.Unexpected:
+						got[td.Target] = "unexpected"
+					default:
+						for _, f := range td.Fields {
+							got[td.Target] = f.Path
+						}
+					}
+				}
+				if !reflect.DeepEqual(got, w.targets) {
+					t.Errorf("deviation %d targets = %v, want %v", i, got, w.targets)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeSnapshot(t *testing.T) {
+	s1 := newAFTSnapshot(
+		nhgEntry(t, "DEFAULT", 1, 2, 1),
+		nhEntry(t, "DEFAULT", 1, "192.0.2.1"),
+		testEntry(t, `network_instance: "DEFAULT" next_hop: {index: 2 next_hop: {ip_address: {value: "192.0.2.2"} mac_address: {value: "00:00:5e:00:53:01"}}}`),
+	)
+	s2 := newAFTSnapshot(
+		nhgEntry(t, "DEFAULT", 1, 1, 2),
+		nhEntry(t, "DEFAULT", 1, "198.51.100.1"),
+		testEntry(t, `network_instance: "DEFAULT" next_hop: {index: 2 next_hop: {ip_address: {value: "192.0.2.2"} mac_address: {value: "00:00:5e:00:53:02"}}}`),
+	)
+	n1 := &config.Normalization{
+		Ignore:  []string{"next_hop.next_hop.mac_address"},
+		Replace: map[string]string{"192.0.2.1": "uplink"},
+	}
+	n2 := &config.Normalization{
+		Ignore:  []string{"next_hop.next_hop.mac_address"},
+		Replace: map[string]string{"198.51.100.1": "uplink"},
+	}
+	fields := map[string]map[string]map[string]string{
+		"r1": normalizeSnapshot(s1, n1),
+		"r2": normalizeSnapshot(s2, n2),
+	}
+	if r := checkConsistency(fields, ""); len(r.Deviations) != 0 {
+		t.Errorf("normalized snapshots deviate: %s", r)
+	}
+	// without normalization, the NH 1 and 2 deviate but not the NHG
+	fields = map[string]map[string]map[string]string{
+		"r1": normalizeSnapshot(s1, &config.Normalization{}),
+		"r2": normalizeSnapshot(s2, &config.Normalization{}),
+	}
+	r := checkConsistency(fields, "r1")
+	got := make([]string, 0)
+	for _, d := range r.Deviations {
+		got = append(got, d.Key)
+	}
+	want := []string{"[DEFAULT] nh 1", "[DEFAULT] nh 2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("deviations = %v, want %v", got, want)
+	}
+	if r.deviating["r2"] != 2 || r.deviating["r1"] != 0 {
+		t.Errorf("deviating entries = %v, want r2: 2", r.deviating)
+	}
+}
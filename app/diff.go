@@ -0,0 +1,337 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/karimra/gribic/api"
+	"github.com/karimra/gribic/config"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+// diff command exit codes
+const (
+	diffExitDifferent = 1
+	diffExitError     = 2
+)
+
+// diff output formats
+const (
+	diffFormatText = "text"
+	diffFormatJSON = "json"
+)
+
+func (a *App) InitDiffFlags(cmd *cobra.Command) {
+	cmd.ResetFlags()
+	//
+	cmd.Flags().StringVarP(&a.Config.DiffNetworkInstance, "ns", "", "", "network instance name, an empty network-instance name means compare all instances.")
+	cmd.Flags().StringVarP(&a.Config.DiffAFT, "aft", "", "ALL", "AFT type to compare, one of: ALL, IPv4, IPv6, NH, NHG, MPLS, MAC or PF")
+	cmd.Flags().BoolVarP(&a.Config.DiffFIBStatus, "fib-status", "", false, "compare the entries FIB status")
+	//
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		a.Config.FileConfig.BindPFlag(fmt.Sprintf("%s-%s", cmd.Name(), flag.Name), flag)
+	})
+}
+
+// DiffRunE compares the AFT entries of two sources, each one being a Get snapshot file,
+// a modify input file or a target.
+// It returns an *ExitError with code 1 if the sources differ and code 2 on failure.
+func (a *App) DiffRunE(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return &ExitError{Code: diffExitError, Err: errors.New("diff requires 2 arguments")}
+	}
+	switch a.Config.Format {
+	case diffFormatText, diffFormatJSON:
+	default:
+		return &ExitError{Code: diffExitError, Err: fmt.Errorf("unknown --format %q, the diff command accepts: text or json", a.Config.Format)}
+	}
+	// validate the AFT type
+	aftReq, err := api.NewGetRequest(api.AFTType(a.Config.DiffAFT))
+	if err != nil {
+		return &ExitError{Code: diffExitError, Err: err}
+	}
+	// load both sources concurrently,
+	// so that 2 targets are compared at the same point in time.
+	snapshots := make([]aftSnapshot, len(args))
+	errs := make([]error, len(args))
+	wg := new(sync.WaitGroup)
+	wg.Add(len(args))
+	for i, src := range args {
+		go func(i int, src string) {
+			defer wg.Done()
+			snapshots[i], errs[i] = a.loadDiffSource(a.ctx, src)
+		}(i, src)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return &ExitError{Code: diffExitError, Err: fmt.Errorf("%q: %v", args[i], err)}
+		}
+	}
+	for _, s := range snapshots {
+		a.filterDiffSnapshot(s, aftReq.GetAft())
+	}
+	d := diffSnapshots(snapshots[0], snapshots[1])
+	switch a.Config.Format {
+	case diffFormatJSON:
+		b, err := formatDiffJSON(args[0], args[1], d)
+		if err != nil {
+			return &ExitError{Code: diffExitError, Err: err}
+		}
+		fmt.Println(string(b))
+	default:
+		fmt.Print(formatDiffText(args[0], args[1], d))
+	}
+	if !d.empty() {
+		return &ExitError{Code: diffExitDifferent}
+	}
+	return nil
+}
+
+// filterDiffSnapshot removes the entries not selected by the --ns and --aft flags,
+// and clears the entries FIB status unless --fib-status is set.
+func (a *App) filterDiffSnapshot(s aftSnapshot, aft spb.AFTType) {
+	for k, e := range s {
+		if a.Config.DiffNetworkInstance != "" && e.GetNetworkInstance() != a.Config.DiffNetworkInstance {
+			delete(s, k)
+			continue
+		}
+		if aft != spb.AFTType_ALL && aft != aftEntryAFTType(e) {
+			delete(s, k)
+			continue
+		}
+		if !a.Config.DiffFIBStatus {
+			e.FibStatus = spb.AFTEntry_UNAVAILABLE
+		}
+	}
+}
+
+// loadDiffSource returns the entries of src,
+// src is a file if it exists, a target name or address otherwise.
+func (a *App) loadDiffSource(ctx context.Context, src string) (aftSnapshot, error) {
+	fi, err := os.Stat(src)
+	if err == nil && !fi.IsDir() {
+		return a.readSnapshotFile(src)
+	}
+	tc, err := a.Config.GetTarget(src)
+	if err != nil {
+		return nil, err
+	}
+	return a.getSnapshot(ctx, tc)
+}
+
+// getSnapshot gets all the AFT entries of a target.
+func (a *App) getSnapshot(ctx context.Context, tc *config.TargetConfig) (aftSnapshot, error) {
+	t := NewTarget(tc)
+	ctx = appendMetadata(ctx, t.Config)
+	err := a.CreateGrpcClient(ctx, t, a.createBaseDialOpts()...)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+	req, err := api.NewGetRequest(api.NSAll(), api.AFTTypeAll())
+	if err != nil {
+		return nil, err
+	}
+	t.gRIBIClient = spb.NewGRIBIClient(t.conn)
+	s := make(aftSnapshot)
+	_, err = a.getEach(ctx, t, req, nil, true, func(e *spb.AFTEntry) error {
+		s.add(e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// readSnapshotFile reads the entries of a file written by the get command
+// in the ndjson, protojson or proto formats, or of a modify input file.
+func (a *App) readSnapshotFile(name string) (aftSnapshot, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) == 0 {
+		return make(aftSnapshot), nil
+	}
+	if trimmed[0] == '{' {
+		s, err := readJSONEntries(trimmed)
+		if err == nil {
+			return s, nil
+		}
+		a.Logger.Debugf("%s: not a JSON entries file: %v", name, err)
+		// a JSON modify input file
+	}
+	mi, err := a.Config.ReadModifyInput(name, "")
+	if err == nil {
+		return modifyInputSnapshot(mi)
+	}
+	a.Logger.Debugf("%s: not a modify input file: %v", name, err)
+	s, err := readProtoEntries(b)
+	if err != nil {
+		return nil, fmt.Errorf("unknown snapshot file format: %v", err)
+	}
+	return s, nil
+}
+
+// readJSONEntries reads one entry per line, either protojson encoded
+// or an ndjson object with the target name and the entry.
+// An ndjson file with the entries of multiple targets is rejected.
+func readJSONEntries(b []byte) (aftSnapshot, error) {
+	s := make(aftSnapshot)
+	targets := make(map[string]struct{})
+	for i, line := range bytes.Split(b, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		obj := make(map[string]json.RawMessage)
+		err := json.Unmarshal(line, &obj)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if entry, ok := obj["entry"]; ok {
+			var target string
+			if t, ok := obj["target"]; ok {
+				if err = json.Unmarshal(t, &target); err != nil {
+					return nil, fmt.Errorf("line %d: %v", i+1, err)
+				}
+			}
+			targets[target] = struct{}{}
+			line = entry
+		}
+		e := new(spb.AFTEntry)
+		err = protojson.Unmarshal(line, e)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		s.add(e)
+	}
+	if len(targets) > 1 {
+		return nil, fmt.Errorf("the file contains the entries of %d targets", len(targets))
+	}
+	return s, nil
+}
+
+// readProtoEntries reads length-delimited binary encoded entries.
+func readProtoEntries(b []byte) (aftSnapshot, error) {
+	s := make(aftSnapshot)
+	r := bufio.NewReader(bytes.NewReader(b))
+	for {
+		e := new(spb.AFTEntry)
+		err := protodelim.UnmarshalFrom(r, e)
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.add(e)
+	}
+}
+
+// modifyInputSnapshot returns the entries resulting from applying
+// the modify input operations to an empty RIB.
+func modifyInputSnapshot(mi *config.ModifyInput) (aftSnapshot, error) {
+	s := make(aftSnapshot)
+	for _, op := range mi.Operations {
+		e, err := op.CreateAFTEntry()
+		if err != nil {
+			return nil, fmt.Errorf("operation index %d: %v", op.ID, err)
+		}
+		if strings.ToLower(op.Operation) == "delete" {
+			delete(s, aftEntryKey(e))
+			continue
+		}
+		s.add(e)
+	}
+	return s, nil
+}
+
+// formatDiffText formats a delta, one line per entry
+// present only in a (-), only in b (+), or changed (~) followed by its changed fields.
+func formatDiffText(a, b string, d *aftDelta) string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "--- %s\n+++ %s\n", a, b)
+	for _, e := range d.removed {
+		fmt.Fprintf(sb, "- %s\n", aftEntryKey(e))
+	}
+	for _, e := range d.added {
+		fmt.Fprintf(sb, "+ %s: %s\n", aftEntryKey(e), prototext.MarshalOptions{}.Format(e))
+	}
+	for _, c := range d.changed {
+		fmt.Fprintf(sb, "~ %s\n", c.key)
+		sb.WriteString(c.fieldsString("    "))
+	}
+	fmt.Fprintf(sb, "%d only in %s, %d only in %s, %d changed\n", len(d.removed), a, len(d.added), b, len(d.changed))
+	return sb.String()
+}
+
+type diffJSON struct {
+	A       string            `json:"a"`
+	B       string            `json:"b"`
+	OnlyInA []json.RawMessage `json:"only-in-a"`
+	OnlyInB []json.RawMessage `json:"only-in-b"`
+	Changed []*diffJSONChange `json:"changed"`
+}
+
+type diffJSONChange struct {
+	Key    string           `json:"key"`
+	Fields []*diffJSONField `json:"fields"`
+}
+
+type diffJSONField struct {
+	Path string `json:"path"`
+	A    string `json:"a,omitempty"`
+	B    string `json:"b,omitempty"`
+}
+
+func formatDiffJSON(a, b string, d *aftDelta) ([]byte, error) {
+	r := &diffJSON{
+		A:       a,
+		B:       b,
+		OnlyInA: make([]json.RawMessage, 0, len(d.removed)),
+		OnlyInB: make([]json.RawMessage, 0, len(d.added)),
+		Changed: make([]*diffJSONChange, 0, len(d.changed)),
+	}
+	for _, e := range d.removed {
+		eb, err := protojson.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		r.OnlyInA = append(r.OnlyInA, eb)
+	}
+	for _, e := range d.added {
+		eb, err := protojson.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		r.OnlyInB = append(r.OnlyInB, eb)
+	}
+	for _, c := range d.changed {
+		jc := &diffJSONChange{
+			Key:    c.key,
+			Fields: make([]*diffJSONField, 0, len(c.fields)),
+		}
+		for _, f := range c.fields {
+			jc.Fields = append(jc.Fields, &diffJSONField{Path: f.path, A: f.old, B: f.new})
+		}
+		r.Changed = append(r.Changed, jc)
+	}
+	return json.MarshalIndent(r, "", "  ")
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+func TestApp_DiffRunE_exitCodes(t *testing.T) {
+	dir := t.TempDir()
+	snapshots := map[string][]*spb.AFTEntry{
+		"a.json": {
+			ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1),
+			nhgEntry(t, "DEFAULT", 1, 1, 2),
+		},
+		// same entries, in another order
+		"b.json": {
+			nhgEntry(t, "DEFAULT", 1, 2, 1),
+			ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1),
+		},
+		"c.json": {
+			ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 2),
+			nhgEntry(t, "DEFAULT", 1, 1, 2),
+		},
+		// differs from a.json in the vrf1 network instance only
+		"d.json": {
+			ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1),
+			nhgEntry(t, "DEFAULT", 1, 1, 2),
+			ipv4Entry(t, "vrf1", "10.0.0.0/24", 1),
+		},
+	}
+	for name, entries := range snapshots {
+		o, err := newGetOutput(getFormatProtoJSON, filepath.Join(dir, name), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if err = o.write("r1", e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = o.close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bad.pb"), []byte{0xff, 0xff, 0xff}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		args     []string
+		format   string
+		aft      string
+		ns       string
+		wantCode int
+	}{
+		{name: "same", args: []string{"a.json", "b.json"}},
+		{name: "different", args: []string{"a.json", "c.json"}, wantCode: diffExitDifferent},
+		{name: "different_json", args: []string{"a.json", "c.json"}, format: diffFormatJSON, wantCode: diffExitDifferent},
+		{name: "different_other_aft", args: []string{"a.json", "c.json"}, aft: "NHG"},
+		{name: "different_other_ns", args: []string{"a.json", "d.json"}, ns: "DEFAULT"},
+		{name: "different_ns", args: []string{"a.json", "d.json"}, ns: "vrf1", wantCode: diffExitDifferent},
+		{name: "one_arg", args: []string{"a.json"}, wantCode: diffExitError},
+		{name: "unknown_format", args: []string{"a.json", "b.json"}, format: "yaml", wantCode: diffExitError},
+		{name: "unknown_aft", args: []string{"a.json", "b.json"}, aft: "foo", wantCode: diffExitError},
+		{name: "bad_file", args: []string{"a.json", "bad.pb"}, wantCode: diffExitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New()
+			a.Config.Format = diffFormatText
+			if tt.format != "" {
+				a.Config.Format = tt.format
+			}
+			a.Config.DiffAFT = "ALL"
+			if tt.aft != "" {
+				a.Config.DiffAFT = tt.aft
+			}
+			a.Config.DiffNetworkInstance = tt.ns
+			args := make([]string, 0, len(tt.args))
+			for _, arg := range tt.args {
+				args = append(args, filepath.Join(dir, arg))
+			}
+			err := a.DiffRunE(nil, args)
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("DiffRunE() error = %v, want nil", err)
+				}
+				return
+			}
+			eErr := new(ExitError)
+			if !errors.As(err, &eErr) {
+				t.Fatalf("DiffRunE() error = %v, want an *ExitError", err)
+			}
+			if eErr.Code != tt.wantCode {
+				t.Errorf("DiffRunE() exit code = %d, want %d: %v", eErr.Code, tt.wantCode, eErr.Err)
+			}
+			if tt.wantCode == diffExitDifferent && eErr.Err != nil {
+				t.Errorf("DiffRunE() different sources error = %v, want nil", eErr.Err)
+			}
+		})
+	}
+}
+
+func TestExitError(t *testing.T) {
+	err := error(&ExitError{Code: 1})
+	if err.Error() != "exit code 1" {
+		t.Errorf("Error() = %q, want %q", err.Error(), "exit code 1")
+	}
+	cause := errors.New("failed")
+	err = &ExitError{Code: 2, Err: cause}
+	if err.Error() != "failed" || !errors.Is(err, cause) {
+		t.Errorf("ExitError does not report or wrap its cause: %v", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/karimra/gribic/api"
 	"github.com/karimra/gribic/config"
@@ -15,8 +17,13 @@ import (
 
 type flushResponse struct {
 	TargetError
+	// target name
+	name string
 	// req *spb.FlushResponse
 	rsp *spb.FlushResponse
+	// selective flush
+	plan    *flushPlan
+	deleted int
 }
 
 func (a *App) InitFlushFlags(cmd *cobra.Command) {
@@ -26,6 +33,19 @@ func (a *App) InitFlushFlags(cmd *cobra.Command) {
 	cmd.Flags().BoolVarP(&a.Config.FlushNetworkInstanceAll, "ns-all", "", false, "run Get against all network instance(s)")
 
 	cmd.Flags().BoolVarP(&a.Config.FlushElectionIDOverride, "override", "", false, "override election ID")
+
+	cmd.Flags().StringVarP(&a.Config.FlushAFT, "aft", "", "", "flush only the entries of an AFT type, one of: ALL, IPv4, IPv6, NH, NHG, MPLS, MAC or PF")
+	cmd.Flags().StringVarP(&a.Config.FlushPrefix, "prefix", "", "", "flush only the routes within a prefix")
+	cmd.Flags().Uint64VarP(&a.Config.FlushNHG, "nhg", "", 0, "flush only a next hop group and the routes pointing to it")
+	cmd.Flags().Uint64VarP(&a.Config.FlushNH, "nh", "", 0, "flush only a next hop and the next hop groups containing it")
+	cmd.Flags().BoolVarP(&a.Config.FlushCascade, "cascade", "", false, "with a selective flush, also flush the next hop groups and next hops only referenced by the flushed entries")
+	// selective flush session parameters
+	cmd.Flags().BoolVarP(&a.Config.ModifySessionRedundancySinglePrimary, "single-primary", "", false, "with a selective flush, set session client redundancy to SINGLE_PRIMARY")
+	cmd.Flags().BoolVarP(&a.Config.ModifySessionPersistancePreserve, "preserve", "", false, "with a selective flush, set session persistence to PRESERVE")
+	cmd.Flags().BoolVarP(&a.Config.ModifySessionRibFibAck, "fib", "", false, "with a selective flush, set session ack type to RIB_FIB")
+
+	cmd.Flags().BoolVarP(&a.Config.FlushYes, "yes", "y", false, "do not ask for a confirmation before flushing")
+	cmd.Flags().BoolVarP(&a.Config.FlushForce, "force", "", false, "allow flushing all network instances of targets tagged protected")
 	//
 	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
 		a.Config.FileConfig.BindPFlag(fmt.Sprintf("%s-%s", cmd.Name(), flag.Name), flag)
@@ -44,6 +64,25 @@ func (a *App) FlushPreRunE(cmd *cobra.Command, args []string) error {
 	if !a.Config.FlushNetworkInstanceAll && a.Config.FlushNetworkInstance == "" {
 		return errors.New("set a specific network-instance name to flush using --ns or flush all network-instances with --ns-all")
 	}
+	if a.selectiveFlush() {
+		if a.Config.FlushElectionIDOverride {
+			return errors.New("flag --override cannot be used with --aft, --prefix, --nhg or --nh")
+		}
+		if a.Config.FlushAFT != "" {
+			// validate the AFT type
+			_, err := api.NewGetRequest(api.AFTType(a.Config.FlushAFT))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if a.Config.FlushCascade {
+		return errors.New("flag --cascade requires one of --aft, --prefix, --nhg or --nh")
+	}
+	if a.Config.ModifySessionRedundancySinglePrimary || a.Config.ModifySessionPersistancePreserve || a.Config.ModifySessionRibFibAck {
+		return errors.New("flags --single-primary, --preserve and --fib require one of --aft, --prefix, --nhg or --nh")
+	}
 	return nil
 }
 
@@ -53,60 +92,158 @@ func (a *App) FlushRunE(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	a.Logger.Debugf("targets: %v", targets)
-	numTargets := len(targets)
+	err = a.checkProtectedTargets(targets)
+	if err != nil {
+		return err
+	}
+	// get the entries that would be removed from each target
+	previews, errs := a.flushPreviews(targets)
+	if len(previews) == 0 {
+		return a.handleErrs(errs)
+	}
+	fmt.Print(formatFlushPreviews(previews))
+	var total int
+	for _, p := range previews {
+		total += p.total()
+	}
+	if total == 0 {
+		a.Logger.Info("nothing to flush")
+		return a.handleErrs(errs)
+	}
+	if !a.Config.FlushYes {
+		ok, err := confirm(fmt.Sprintf("flush %d entries from %d target(s)?", total, len(previews)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("flush aborted")
+		}
+	}
+	previewed := make(map[string]*target, len(previews))
+	byName := make(map[string]*flushPreview, len(previews))
+	for _, p := range previews {
+		previewed[p.t.Config.Name] = p.t
+		byName[p.t.Config.Name] = p
+	}
+	errs = append(errs, a.rollout(previewed, func(targets map[string]*target) map[string]error {
+		stage := make([]*flushPreview, 0, len(targets))
+		for n := range targets {
+			stage = append(stage, byName[n])
+		}
+		return a.flushStage(stage)
+	})...)
+	return a.handleErrs(errs)
+}
+
+// flushStage runs the flush against the previewed targets of a rollout stage,
+// each target is connected to for the duration of its flush only.
+// It returns the errors of the failed targets indexed by target name.
+func (a *App) flushStage(previews []*flushPreview) map[string]error {
+	numTargets := len(previews)
 	responseChan := make(chan *flushResponse, numTargets)
 
 	a.wg.Add(numTargets)
-	for _, t := range targets {
-		go func(t *target) {
+	for _, p := range previews {
+		go func(p *flushPreview) {
 			defer a.wg.Done()
+			release, err := a.acquireTarget(a.ctx)
+			if err != nil {
+				responseChan <- &flushResponse{
+					TargetError: TargetError{
+						TargetName: p.t.Config.Address,
+						Err:        err,
+					},
+					name: p.t.Config.Name,
+				}
+				return
+			}
+			defer release()
 			// create context
 			ctx, cancel := context.WithCancel(a.ctx)
 			defer cancel()
-			// append credentials to context
-			ctx = appendCredentials(ctx, t.Config)
+			// append metadata to context
+			ctx = appendMetadata(ctx, p.t.Config)
 			// create a grpc conn
-			err = a.CreateGrpcClient(ctx, t, a.createBaseDialOpts()...)
+			err = a.CreateGrpcClient(ctx, p.t, a.createBaseDialOpts()...)
 			if err != nil {
 				responseChan <- &flushResponse{
 					TargetError: TargetError{
-						TargetName: t.Config.Address,
+						TargetName: p.t.Config.Address,
+						Err:        err,
+					},
+					name: p.t.Config.Name,
+				}
+				return
+			}
+			defer p.t.Close()
+			if p.plan != nil {
+				n, err := a.gribiFlushSelect(ctx, p.t, p.plan)
+				responseChan <- &flushResponse{
+					TargetError: TargetError{
+						TargetName: p.t.Config.Address,
 						Err:        err,
 					},
+					name:    p.t.Config.Name,
+					plan:    p.plan,
+					deleted: n,
 				}
 				return
 			}
-			defer t.Close()
-			rsp, err := a.gribiFlush(ctx, t)
+			rsp, err := a.gribiFlush(ctx, p.t)
 			responseChan <- &flushResponse{
 				TargetError: TargetError{
-					TargetName: t.Config.Address,
+					TargetName: p.t.Config.Address,
 					Err:        err,
 				},
-				rsp: rsp,
+				name: p.t.Config.Name,
+				rsp:  rsp,
 			}
-		}(t)
+		}(p)
 	}
 	//
 	a.wg.Wait()
 	close(responseChan)
 
-	errs := make([]error, 0) //, numTargets)
+	errs := make(map[string]error)
 	result := make([]*flushResponse, 0, numTargets)
 	for rsp := range responseChan {
 		if rsp.Err != nil {
 			wErr := fmt.Errorf("%q Flush RPC failed: %v", rsp.TargetName, rsp.Err)
 			a.Logger.Error(wErr)
-			errs = append(errs, wErr)
+			errs[rsp.name] = wErr
 			continue
 		}
 		result = append(result, rsp)
 	}
 	a.Logger.Printf("got %d results", len(result))
 	for _, r := range result {
+		if r.plan != nil {
+			a.Logger.Infof("%q: deleted %d entries, skipped %d: %s", r.TargetName, r.deleted, len(r.plan.skipped), r.plan.countsString())
+			continue
+		}
 		a.Logger.Infof("%q: %s", r.TargetName, prototext.Format(r.rsp))
 	}
-	return a.handleErrs(errs)
+	return errs
+}
+
+// checkProtectedTargets refuses to flush all the network instances
+// of targets tagged protected, unless --force is set.
+func (a *App) checkProtectedTargets(targets map[string]*target) error {
+	if !a.Config.FlushNetworkInstanceAll || a.Config.FlushForce {
+		return nil
+	}
+	protected := make([]string, 0)
+	for n, t := range targets {
+		if t.Config.Protected() {
+			protected = append(protected, n)
+		}
+	}
+	if len(protected) == 0 {
+		return nil
+	}
+	sort.Strings(protected)
+	return fmt.Errorf("refusing to flush all network instances of protected target(s) %s, use --force to override",
+		strings.Join(protected, ", "))
 }
 
 func (a *App) gribiFlush(ctx context.Context, t *target) (*spb.FlushResponse, error) {
@@ -117,11 +254,15 @@ func (a *App) gribiFlush(ctx context.Context, t *target) (*spb.FlushResponse, er
 	default:
 		opts = append(opts, api.NetworkInstance(a.Config.FlushNetworkInstance))
 	}
+	electionID, err := a.targetElectionID(t.Config)
+	if err != nil {
+		return nil, err
+	}
 	switch {
 	case a.Config.FlushElectionIDOverride:
 		opts = append(opts, api.Override())
-	case a.electionID != nil:
-		opts = append(opts, api.ElectionID(a.electionID))
+	case electionID != nil:
+		opts = append(opts, api.ElectionID(electionID))
 	}
 	req, err := api.NewFlushRequest(opts...)
 	if err != nil {
@@ -132,6 +273,14 @@ func (a *App) gribiFlush(ctx context.Context, t *target) (*spb.FlushResponse, er
 	return a.flush(ctx, t, req)
 }
 
+// flush runs a Flush RPC bounded by the target flush-timeout
+// and retried according to its retry policy.
 func (a *App) flush(ctx context.Context, t *target, req *spb.FlushRequest) (*spb.FlushResponse, error) {
-	return t.gRIBIClient.Flush(ctx, req)
+	var rsp *spb.FlushResponse
+	err := a.withRetry(ctx, t, "Flush", t.Config.FlushTimeout, func(ctx context.Context) error {
+		var err error
+		rsp, err = t.gRIBIClient.Flush(ctx, req)
+		return err
+	})
+	return rsp, err
 }
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/karimra/gribic/api"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+// flushPreview holds the entries a flush would remove from a target.
+type flushPreview struct {
+	t *target
+	// number of entries per network instance and AFT type
+	counts map[string]map[string]int
+	// set for a selective flush
+	plan *flushPlan
+}
+
+type flushPreviewResponse struct {
+	TargetError
+	preview *flushPreview
+}
+
+func (p *flushPreview) total() int {
+	var n int
+	for _, c := range p.counts {
+		for _, v := range c {
+			n += v
+		}
+	}
+	return n
+}
+
+// flushPreviews connects to the targets and gets the entries the flush would remove,
+// it returns the previews of the targets that succeeded, sorted by target name.
+func (a *App) flushPreviews(targets map[string]*target) ([]*flushPreview, []error) {
+	numTargets := len(targets)
+	responseChan := make(chan *flushPreviewResponse, numTargets)
+	a.wg.Add(numTargets)
+	for _, t := range targets {
+		go func(t *target) {
+			defer a.wg.Done()
+			release, err := a.acquireTarget(a.ctx)
+			if err != nil {
+				responseChan <- &flushPreviewResponse{
+					TargetError: TargetError{
+						TargetName: t.Config.Address,
+						Err:        err,
+					},
+				}
+				return
+			}
+			defer release()
+			// create context
+			ctx, cancel := context.WithCancel(a.ctx)
+			defer cancel()
+			// append metadata to context
+			ctx = appendMetadata(ctx, t.Config)
+			// create a grpc conn
+			err = a.CreateGrpcClient(ctx, t, a.createBaseDialOpts()...)
+			if err != nil {
+				responseChan <- &flushPreviewResponse{
+					TargetError: TargetError{
+						TargetName: t.Config.Address,
+						Err:        err,
+					},
+				}
+				return
+			}
+			// the connection is closed once previewed, the flush stages reconnect to the target
+			defer t.Close()
+			p, err := a.flushPreview(ctx, t)
+			responseChan <- &flushPreviewResponse{
+				TargetError: TargetError{
+					TargetName: t.Config.Address,
+					Err:        err,
+				},
+				preview: p,
+			}
+		}(t)
+	}
+	a.wg.Wait()
+	close(responseChan)
+
+	errs := make([]error, 0)
+	previews := make([]*flushPreview, 0, numTargets)
+	for rsp := range responseChan {
+		if rsp.Err != nil {
+			wErr := fmt.Errorf("%q Get RPC failed: %v", rsp.TargetName, rsp.Err)
+			a.Logger.Error(wErr)
+			errs = append(errs, wErr)
+			continue
+		}
+		previews = append(previews, rsp.preview)
+	}
+	sort.Slice(previews, func(i, j int) bool {
+		return previews[i].t.Config.Name < previews[j].t.Config.Name
+	})
+	return previews, errs
+}
+
+func (a *App) flushPreview(ctx context.Context, t *target) (*flushPreview, error) {
+	if a.selectiveFlush() {
+		plan, err := a.createFlushPlan(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		for k, reason := range plan.skipped {
+			a.Logger.Warnf("target %s: skipping %s: %s", t.Config.Name, k, reason)
+		}
+		return &flushPreview{t: t, counts: plan.counts(), plan: plan}, nil
+	}
+	opts := []api.GRIBIOption{api.AFTTypeAll()}
+	switch {
+	case a.Config.FlushNetworkInstanceAll:
+		opts = append(opts, api.NSAll())
+	default:
+		opts = append(opts, api.NetworkInstance(a.Config.FlushNetworkInstance))
+	}
+	req, err := api.NewGetRequest(opts...)
+	if err != nil {
+		return nil, err
+	}
+	t.gRIBIClient = spb.NewGRIBIClient(t.conn)
+	p := &flushPreview{t: t, counts: make(map[string]map[string]int)}
+	_, err = a.getEach(ctx, t, req, nil, false, func(e *spb.AFTEntry) error {
+		ni := e.GetNetworkInstance()
+		if p.counts[ni] == nil {
+			p.counts[ni] = make(map[string]int)
+		}
+		p.counts[ni][aftEntryType(e)]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// formatFlushPreviews returns a table with the number of entries
+// the flush would remove per target, network instance and AFT type.
+func formatFlushPreviews(previews []*flushPreview) string {
+	sb := new(strings.Builder)
+	tw := tabwriter.NewWriter(sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "TARGET\tNETWORK-INSTANCE\t%s\tTOTAL\n", strings.ToUpper(strings.Join(aftTypes, "\t")))
+	for _, p := range previews {
+		nis := make([]string, 0, len(p.counts))
+		for ni := range p.counts {
+			nis = append(nis, ni)
+		}
+		sort.Strings(nis)
+		if len(nis) == 0 {
+			fmt.Fprintf(tw, "%s\t-%s\t0\n", p.t.Config.Name, strings.Repeat("\t0", len(aftTypes)))
+			continue
+		}
+		for _, ni := range nis {
+			fmt.Fprintf(tw, "%s\t%s", p.t.Config.Name, ni)
+			var total int
+			for _, typ := range aftTypes {
+				fmt.Fprintf(tw, "\t%d", p.counts[ni][typ])
+				total += p.counts[ni][typ]
+			}
+			fmt.Fprintf(tw, "\t%d\n", total)
+		}
+	}
+	tw.Flush()
+	return sb.String()
+}
@@ -0,0 +1,385 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/karimra/gribic/api"
+	"github.com/karimra/gribic/config"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+// flushPlan is the dependency ordered set of entries to delete from a target.
+type flushPlan struct {
+	// entries to delete, per level:
+	// the entries of a level are only referenced by entries of the previous levels.
+	levels [][]*spb.AFTEntry
+	// selected entries that cannot be deleted, with the reason
+	skipped map[string]string
+}
+
+// selectiveFlush returns true if the flush command filters the entries to delete,
+// in which case they are deleted over a Modify session instead of a Flush RPC.
+func (a *App) selectiveFlush() bool {
+	return a.Config.FlushAFT != "" || a.Config.FlushPrefix != "" ||
+		a.Config.FlushNHG != 0 || a.Config.FlushNH != 0
+}
+
+// gribiFlushSelect deletes the entries of the flush plan,
+// it returns the number of deleted entries.
+func (a *App) gribiFlushSelect(ctx context.Context, t *target, plan *flushPlan) (int, error) {
+	if plan.count() == 0 {
+		return 0, nil
+	}
+	return a.runFlushPlan(ctx, t, plan)
+}
+
+// createFlushPlan gets all the AFT entries of the target
+// and computes the flush plan of the entries selected by the flush flags.
+func (a *App) createFlushPlan(ctx context.Context, t *target) (*flushPlan, error) {
+	f, err := newEntryFilter(a.Config.FlushPrefix, "", a.Config.FlushNHG, a.Config.FlushNH, "", "")
+	if err != nil {
+		return nil, err
+	}
+	aft := a.Config.FlushAFT
+	if aft == "" {
+		aft = "ALL"
+	}
+	aftReq, err := api.NewGetRequest(api.AFTType(aft))
+	if err != nil {
+		return nil, err
+	}
+	// all the entries are needed to find the references to the selected ones
+	idx, err := a.getAFTIndex(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]*spb.AFTEntry, 0)
+	for _, e := range idx.entries() {
+		if !a.Config.FlushNetworkInstanceAll && e.GetNetworkInstance() != a.Config.FlushNetworkInstance {
+			continue
+		}
+		if aftReq.GetAft() != spb.AFTType_ALL && aftReq.GetAft() != aftEntryAFTType(e) {
+			continue
+		}
+		selected = append(selected, e)
+	}
+	selected = f.filter(selected)
+	return newFlushPlan(idx.entries(), selected, a.Config.FlushCascade), nil
+}
+
+// newFlushPlan returns the plan deleting the selected entries,
+// and if cascade is true, the NHGs and NHs only referenced by deleted entries.
+// A selected entry referenced by an entry that is not deleted is skipped.
+func newFlushPlan(all, selected []*spb.AFTEntry, cascade bool) *flushPlan {
+	plan := &flushPlan{skipped: make(map[string]string)}
+	entries := make(map[string]*spb.AFTEntry, len(all))
+	for _, e := range all {
+		entries[aftEntryKey(e)] = e
+	}
+	// referenced entry key -> referencing entry keys, and the reverse.
+	refs := make(map[string]map[string]struct{})
+	deps := make(map[string][]string)
+	for k, e := range entries {
+		for _, d := range aftEntryDependencies(e) {
+			if _, ok := entries[d]; !ok {
+				continue
+			}
+			if refs[d] == nil {
+				refs[d] = make(map[string]struct{})
+			}
+			refs[d][k] = struct{}{}
+			deps[k] = append(deps[k], d)
+		}
+	}
+	isSelected := make(map[string]bool, len(selected))
+	del := make(map[string]bool, len(selected))
+	for _, e := range selected {
+		isSelected[aftEntryKey(e)] = true
+		del[aftEntryKey(e)] = true
+	}
+	// returns the first referencing entry of k that is not deleted, if any.
+	onlyReferencedByDeleted := func(k string) (string, bool) {
+		var first string
+		for r := range refs[k] {
+			if !del[r] && (first == "" || r < first) {
+				first = r
+			}
+		}
+		return first, first == ""
+	}
+	for {
+		if cascade {
+			queue := make([]string, 0, len(del))
+			for k := range del {
+				queue = append(queue, k)
+			}
+			for len(queue) > 0 {
+				k := queue[0]
+				queue = queue[1:]
+				for _, d := range deps[k] {
+					if del[d] {
+						continue
+					}
+					if _, ok := onlyReferencedByDeleted(d); ok {
+						del[d] = true
+						queue = append(queue, d)
+					}
+				}
+			}
+		}
+		// remove the entries still referenced, until there are none,
+		// the cascaded entries they reference are removed in the next iteration.
+		var blocked bool
+		for k := range del {
+			if r, ok := onlyReferencedByDeleted(k); !ok {
+				delete(del, k)
+				if isSelected[k] {
+					plan.skipped[k] = fmt.Sprintf("referenced by %s", r)
+				}
+				blocked = true
+			}
+		}
+		if !blocked {
+			break
+		}
+		if cascade {
+			// reset the cascaded entries, they are recomputed from the remaining ones.
+			for k := range del {
+				delete(del, k)
+			}
+			for k := range isSelected {
+				if _, ok := plan.skipped[k]; !ok {
+					del[k] = true
+				}
+			}
+		}
+	}
+	// order the entries by levels, an entry is deleted after all the entries referencing it.
+	indegree := make(map[string]int, len(del))
+	for k := range del {
+		for r := range refs[k] {
+			if del[r] {
+				indegree[k]++
+			}
+		}
+	}
+	for len(del) > 0 {
+		level := make([]*spb.AFTEntry, 0)
+		for k := range del {
+			if indegree[k] == 0 {
+				level = append(level, entries[k])
+			}
+		}
+		if len(level) == 0 {
+			// reference loop, e.g: NHGs using each other as backup
+			for k := range del {
+				plan.skipped[k] = "reference loop"
+			}
+			break
+		}
+		level = sortedTreeRoots(level)
+		for _, e := range level {
+			k := aftEntryKey(e)
+			delete(del, k)
+			for _, d := range deps[k] {
+				if del[d] {
+					indegree[d]--
+				}
+			}
+		}
+		plan.levels = append(plan.levels, level)
+	}
+	return plan
+}
+
+// aftEntryDependencies returns the keys of the entries referenced by e:
+// the NHG of a route, the NHs and the backup NHG of a NHG.
+func aftEntryDependencies(e *spb.AFTEntry) []string {
+	ni := e.GetNetworkInstance()
+	switch e.GetEntry().(type) {
+	case *spb.AFTEntry_NextHopGroup:
+		nhg := e.GetNextHopGroup().GetNextHopGroup()
+		deps := make([]string, 0, len(nhg.GetNextHop())+1)
+		for _, idx := range nhgNextHops(e) {
+			deps = append(deps, aftRefKey(ni, aftTypeNH, idx))
+		}
+		if b := nhg.GetBackupNextHopGroup(); b != nil {
+			deps = append(deps, aftRefKey(ni, aftTypeNHG, b.GetValue()))
+		}
+		return deps
+	case *spb.AFTEntry_NextHop:
+		return nil
+	}
+	id, ok := aftEntryNHG(e)
+	if !ok {
+		return nil
+	}
+	if nhgNI := aftEntryNHGNetworkInstance(e); nhgNI != "" {
+		ni = nhgNI
+	}
+	return []string{aftRefKey(ni, aftTypeNHG, id)}
+}
+
+func (p *flushPlan) count() int {
+	var n int
+	for _, l := range p.levels {
+		n += len(l)
+	}
+	return n
+}
+
+// counts returns the number of entries to delete per network instance and AFT type.
+func (p *flushPlan) counts() map[string]map[string]int {
+	c := make(map[string]map[string]int)
+	for _, l := range p.levels {
+		for _, e := range l {
+			ni := e.GetNetworkInstance()
+			if c[ni] == nil {
+				c[ni] = make(map[string]int)
+			}
+			c[ni][aftEntryType(e)]++
+		}
+	}
+	return c
+}
+
+// countsString returns the number of entries to delete per network instance and AFT type
+// formatted as "[ni] type=n ...".
+func (p *flushPlan) countsString() string {
+	c := p.counts()
+	nis := make([]string, 0, len(c))
+	for ni := range c {
+		nis = append(nis, ni)
+	}
+	sort.Strings(nis)
+	sb := new(strings.Builder)
+	for i, ni := range nis {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(sb, "[%s]", ni)
+		for _, typ := range aftTypes {
+			if n, ok := c[ni][typ]; ok {
+				fmt.Fprintf(sb, " %s=%d", typ, n)
+			}
+		}
+	}
+	if sb.Len() == 0 {
+		return "no entries"
+	}
+	return sb.String()
+}
+
+// runFlushPlan opens a Modify session and deletes the plan entries, one request per level,
+// each level is sent once the previous one is acknowledged.
+// It returns the number of deleted entries.
+func (a *App) runFlushPlan(ctx context.Context, t *target, plan *flushPlan) (int, error) {
+	t.gRIBIClient = spb.NewGRIBIClient(t.conn)
+	err := t.createModifyClient(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer t.modifyCfn()
+	// the session parameters are the ones of the target modify sessions
+	params, err := a.createModifyRequestParams(t.Config, new(config.ModifyInput))
+	if err != nil {
+		return 0, err
+	}
+	_, err = a.sendModifyRequest(ctx, t, params[0], false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to set session parameters: %w", err)
+	}
+	fibAck := params[0].GetParams().GetAckType() == spb.SessionParameters_RIB_AND_FIB_ACK
+	// the operations carry the election ID of a single primary session only
+	var electionID *spb.Uint128
+	if len(params) > 1 {
+		electionID = params[1].GetElectionId()
+		rsps, err := a.sendModifyRequest(ctx, t, params[1], false)
+		if err != nil {
+			return 0, fmt.Errorf("failed to send election ID: %w", err)
+		}
+		for _, rsp := range rsps {
+			if eid := rsp.GetElectionId(); eid != nil && (eid.GetHigh() > electionID.GetHigh() ||
+				(eid.GetHigh() == electionID.GetHigh() && eid.GetLow() > electionID.GetLow())) {
+				return 0, fmt.Errorf("not primary, the target's election ID %v is higher than the client's", eid)
+			}
+		}
+	}
+	var deleted int
+	var id uint64
+	for i, level := range plan.levels {
+		req := &spb.ModifyRequest{Operation: make([]*spb.AFTOperation, 0, len(level))}
+		keys := make(map[uint64]string, len(level))
+		for _, e := range level {
+			id++
+			op, err := aftEntryOperation(id, spb.AFTOperation_DELETE, e)
+			if err != nil {
+				return deleted, err
+			}
+			op.ElectionId = electionID
+			req.Operation = append(req.Operation, op)
+			keys[id] = aftEntryKey(e)
+		}
+		a.Logger.Debugf("target %s: flush level %d request:\n%v", t.Config.Name, i, req)
+		rsps, err := a.sendModifyRequest(ctx, t, req, fibAck)
+		if err != nil {
+			return deleted, err
+		}
+		failed := make([]string, 0)
+		for _, rsp := range rsps {
+			for _, res := range rsp.GetResult() {
+				switch res.GetStatus() {
+				case spb.AFTResult_FAILED, spb.AFTResult_FIB_FAILED:
+					failed = append(failed, fmt.Sprintf("%s: %s", keys[res.GetId()], res.GetStatus()))
+				case spb.AFTResult_RIB_PROGRAMMED:
+					// with a RIB and FIB ack, the FIB result follows
+					if !fibAck {
+						deleted++
+					}
+				case spb.AFTResult_FIB_PROGRAMMED:
+					deleted++
+				}
+			}
+		}
+		if len(failed) > 0 {
+			sort.Strings(failed)
+			return deleted, fmt.Errorf("%d delete operation(s) failed: %s", len(failed), strings.Join(failed, ", "))
+		}
+	}
+	return deleted, nil
+}
+
+// sendModifyRequest sends req on the target modify stream and returns the responses
+// received until all its operations are acknowledged, in the FIB if fibAck is true.
+func (a *App) sendModifyRequest(ctx context.Context, t *target, req *spb.ModifyRequest, fibAck bool) ([]*spb.ModifyResponse, error) {
+	reqCh := make(chan *spb.ModifyRequest, 1)
+	reqCh <- req
+	close(reqCh)
+	timeout, stop := ackTimer(t)
+	defer stop()
+	rspCh, errCh := a.modifyChan(ctx, t, reqCh, fibAck)
+	rsps := make([]*spb.ModifyResponse, 0, 1)
+	for {
+		select {
+		case <-ctx.Done():
+			return rsps, ctx.Err()
+		case <-timeout:
+			return rsps, ackTimeoutError(t)
+		case rsp, ok := <-rspCh:
+			if !ok {
+				select {
+				case err := <-errCh:
+					return rsps, err
+				default:
+					return rsps, nil
+				}
+			}
+			rsps = append(rsps, rsp)
+		case err := <-errCh:
+			return rsps, err
+		}
+	}
+}
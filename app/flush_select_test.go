@@ -0,0 +1,420 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/karimra/gribic/config"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// flushTestEntries returns the entries of the flush plan tests:
+//
+//	[DEFAULT] ipv4 10.0.0.0/24 -> nhg 1 -> nh 1, nh 2, backup nhg 2 -> nh 3
+//	[DEFAULT] ipv4 10.0.1.0/24 -> nhg 3 -> nh 2
+//	[DEFAULT] ipv4 10.0.2.0/24 -> nhg 4 -> nh 4, backup nhg 5 -> nh 4, backup nhg 4
+func flushTestEntries(t *testing.T) []*spb.AFTEntry {
+	return []*spb.AFTEntry{
+		ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1),
+		testEntry(t, `network_instance: "DEFAULT" next_hop_group: {id: 1 next_hop_group: {
+			next_hop: {index: 1 next_hop: {weight: {value: 1}}}
+			next_hop: {index: 2 next_hop: {weight: {value: 1}}}
+			backup_next_hop_group: {value: 2}}}`),
+		nhgEntry(t, "DEFAULT", 2, 3),
+		nhEntry(t, "DEFAULT", 1, "192.0.2.1"),
+		nhEntry(t, "DEFAULT", 2, "192.0.2.2"),
+		nhEntry(t, "DEFAULT", 3, "192.0.2.3"),
+		ipv4Entry(t, "DEFAULT", "10.0.1.0/24", 3),
+		nhgEntry(t, "DEFAULT", 3, 2),
+		ipv4Entry(t, "DEFAULT", "10.0.2.0/24", 4),
+		testEntry(t, `network_instance: "DEFAULT" next_hop_group: {id: 4 next_hop_group: {
+			next_hop: {index: 4 next_hop: {weight: {value: 1}}}
+			backup_next_hop_group: {value: 5}}}`),
+		testEntry(t, `network_instance: "DEFAULT" next_hop_group: {id: 5 next_hop_group: {
+			next_hop: {index: 4 next_hop: {weight: {value: 1}}}
+			backup_next_hop_group: {value: 4}}}`),
+		nhEntry(t, "DEFAULT", 4, "192.0.2.4"),
+	}
+}
+
+// vrfRoute is a route of the vrf1 network instance using the NHG 1 of the DEFAULT network instance.
+func vrfRoute(t *testing.T) *spb.AFTEntry {
+	return testEntry(t, `network_instance: "vrf1" ipv4: {prefix: "10.0.0.0/24" ipv4_entry: {
+		next_hop_group: {value: 1} next_hop_group_network_instance: {value: "DEFAULT"}}}`)
+}
+
+func TestNewFlushPlan(t *testing.T) {
+	tests := []struct {
+		name        string
+		extra       []*spb.AFTEntry
+		selected    []string
+		cascade     bool
+		wantLevels  [][]string
+		wantSkipped map[string]string
+	}{
+		{
+			name:       "route",
+			selected:   []string{"[DEFAULT] ipv4 10.0.0.0/24"},
+			wantLevels: [][]string{{"[DEFAULT] ipv4 10.0.0.0/24"}},
+		},
+		{
+			name:     "route_cascade",
+			selected: []string{"[DEFAULT] ipv4 10.0.0.0/24"},
+			cascade:  true,
+			// nh 2 is still used by nhg 3
+			wantLevels: [][]string{
+				{"[DEFAULT] ipv4 10.0.0.0/24"},
+				{"[DEFAULT] nhg 1"},
+				{"[DEFAULT] nh 1", "[DEFAULT] nhg 2"},
+				{"[DEFAULT] nh 3"},
+			},
+		},
+		{
+			name:     "routes_cascade",
+			selected: []string{"[DEFAULT] ipv4 10.0.0.0/24", "[DEFAULT] ipv4 10.0.1.0/24"},
+			cascade:  true,
+			wantLevels: [][]string{
+				{"[DEFAULT] ipv4 10.0.0.0/24", "[DEFAULT] ipv4 10.0.1.0/24"},
+				{"[DEFAULT] nhg 1", "[DEFAULT] nhg 3"},
+				{"[DEFAULT] nh 1", "[DEFAULT] nh 2", "[DEFAULT] nhg 2"},
+				{"[DEFAULT] nh 3"},
+			},
+		},
+		{
+			name:        "blocked",
+			selected:    []string{"[DEFAULT] nhg 1"},
+			wantSkipped: map[string]string{"[DEFAULT] nhg 1": "referenced by [DEFAULT] ipv4 10.0.0.0/24"},
+		},
+		{
+			name:     "blocked_cascade",
+			selected: []string{"[DEFAULT] nhg 1", "[DEFAULT] ipv4 10.0.1.0/24"},
+			cascade:  true,
+			// the nhg 1 stays in place with the nh 2 it uses
+			wantLevels: [][]string{
+				{"[DEFAULT] ipv4 10.0.1.0/24"},
+				{"[DEFAULT] nhg 3"},
+			},
+			wantSkipped: map[string]string{"[DEFAULT] nhg 1": "referenced by [DEFAULT] ipv4 10.0.0.0/24"},
+		},
+		{
+			name:     "blocked_by_blocked",
+			selected: []string{"[DEFAULT] nhg 1", "[DEFAULT] nh 3"},
+			wantSkipped: map[string]string{
+				"[DEFAULT] nhg 1": "referenced by [DEFAULT] ipv4 10.0.0.0/24",
+				"[DEFAULT] nh 3":  "referenced by [DEFAULT] nhg 2",
+			},
+		},
+		{
+			name:     "referencing_entries_selected",
+			selected: []string{"[DEFAULT] nh 3", "[DEFAULT] nhg 2", "[DEFAULT] nhg 1", "[DEFAULT] ipv4 10.0.0.0/24"},
+			wantLevels: [][]string{
+				{"[DEFAULT] ipv4 10.0.0.0/24"},
+				{"[DEFAULT] nhg 1"},
+				{"[DEFAULT] nhg 2"},
+				{"[DEFAULT] nh 3"},
+			},
+		},
+		{
+			name:       "other_network_instance_cascade",
+			extra:      []*spb.AFTEntry{vrfRoute(t)},
+			selected:   []string{"[DEFAULT] ipv4 10.0.0.0/24"},
+			cascade:    true,
+			wantLevels: [][]string{{"[DEFAULT] ipv4 10.0.0.0/24"}},
+		},
+		{
+			name:        "other_network_instance_blocked",
+			extra:       []*spb.AFTEntry{vrfRoute(t)},
+			selected:    []string{"[DEFAULT] ipv4 10.0.0.0/24", "[DEFAULT] nhg 1"},
+			wantLevels:  [][]string{{"[DEFAULT] ipv4 10.0.0.0/24"}},
+			wantSkipped: map[string]string{"[DEFAULT] nhg 1": "referenced by [vrf1] ipv4 10.0.0.0/24"},
+		},
+		{
+			name:     "other_network_instance_selected",
+			extra:    []*spb.AFTEntry{vrfRoute(t)},
+			selected: []string{"[DEFAULT] ipv4 10.0.0.0/24", "[vrf1] ipv4 10.0.0.0/24"},
+			cascade:  true,
+			wantLevels: [][]string{
+				{"[DEFAULT] ipv4 10.0.0.0/24", "[vrf1] ipv4 10.0.0.0/24"},
+				{"[DEFAULT] nhg 1"},
+				{"[DEFAULT] nh 1", "[DEFAULT] nhg 2"},
+				{"[DEFAULT] nh 3"},
+			},
+		},
+		{
+			name:     "backup_cycle",
+			selected: []string{"[DEFAULT] nhg 4", "[DEFAULT] nhg 5", "[DEFAULT] ipv4 10.0.2.0/24"},
+			wantLevels: [][]string{
+				{"[DEFAULT] ipv4 10.0.2.0/24"},
+			},
+			wantSkipped: map[string]string{
+				"[DEFAULT] nhg 4": "reference loop",
+				"[DEFAULT] nhg 5": "reference loop",
+			},
+		},
+		{
+			name:     "backup_cycle_cascade",
+			selected: []string{"[DEFAULT] ipv4 10.0.2.0/24"},
+			cascade:  true,
+			// the nhg 4 and 5 reference each other, they are not cascaded
+			wantLevels: [][]string{
+				{"[DEFAULT] ipv4 10.0.2.0/24"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			all := append(flushTestEntries(t), tt.extra...)
+			byKey := make(map[string]*spb.AFTEntry, len(all))
+			for _, e := range all {
+				byKey[aftEntryKey(e)] = e
+			}
+			selected := make([]*spb.AFTEntry, 0, len(tt.selected))
+			for _, k := range tt.selected {
+				e, ok := byKey[k]
+				if !ok {
+					t.Fatalf("unknown entry %q", k)
+				}
+				selected = append(selected, e)
+			}
+			plan := newFlushPlan(all, selected, tt.cascade)
+			levels := make([][]string, 0, len(plan.levels))
+			for _, l := range plan.levels {
+				levels = append(levels, entryKeys(l))
+			}
+			if tt.wantLevels == nil {
+				tt.wantLevels = [][]string{}
+			}
+			if !reflect.DeepEqual(levels, tt.wantLevels) {
+				t.Errorf("levels = %q, want %q", levels, tt.wantLevels)
+			}
+			if tt.wantSkipped == nil {
+				tt.wantSkipped = map[string]string{}
+			}
+			if !reflect.DeepEqual(plan.skipped, tt.wantSkipped) {
+				t.Errorf("skipped = %q, want %q", plan.skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+// modifyRecorder is a gRIBI server recording the Modify requests it receives,
+// the operations on the entries in failed are answered with FAILED.
+// In a RIB and FIB ack session, the RIB results are followed by the FIB results.
+type modifyRecorder struct {
+	spb.UnimplementedGRIBIServer
+	failed map[string]bool
+
+	m    sync.Mutex
+	reqs []string
+}
+
+func (s *modifyRecorder) Modify(stream spb.GRIBI_ModifyServer) error {
+	var fibAck bool
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		s.m.Lock()
+		s.reqs = append(s.reqs, modifyRequestString(req))
+		s.m.Unlock()
+		rsp := new(spb.ModifyResponse)
+		switch {
+		case req.GetParams() != nil:
+			fibAck = req.GetParams().GetAckType() == spb.SessionParameters_RIB_AND_FIB_ACK
+			rsp.SessionParamsResult = &spb.SessionParametersResult{Status: spb.SessionParametersResult_OK}
+		case req.GetElectionId() != nil:
+			rsp.ElectionId = req.GetElectionId()
+		}
+		fibRsp := new(spb.ModifyResponse)
+		for _, op := range req.GetOperation() {
+			if s.failed[aftOperationKey(op)] {
+				rsp.Result = append(rsp.Result, &spb.AFTResult{Id: op.GetId(), Status: spb.AFTResult_FAILED})
+				continue
+			}
+			rsp.Result = append(rsp.Result, &spb.AFTResult{Id: op.GetId(), Status: spb.AFTResult_RIB_PROGRAMMED})
+			if fibAck {
+				fibRsp.Result = append(fibRsp.Result, &spb.AFTResult{Id: op.GetId(), Status: spb.AFTResult_FIB_PROGRAMMED})
+			}
+		}
+		err = stream.Send(rsp)
+		if err != nil {
+			return err
+		}
+		if len(fibRsp.GetResult()) > 0 {
+			err = stream.Send(fibRsp)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// aftOperationKey returns the aftEntryKey of the entry of op.
+func aftOperationKey(op *spb.AFTOperation) string {
+	return aftEntryKey(aftOperationEntry(op))
+}
+
+func modifyRequestString(req *spb.ModifyRequest) string {
+	if p := req.GetParams(); p != nil {
+		return fmt.Sprintf("params %s %s %s", p.GetRedundancy(), p.GetPersistence(), p.GetAckType())
+	}
+	if eid := req.GetElectionId(); eid != nil {
+		return fmt.Sprintf("election-id %d:%d", eid.GetHigh(), eid.GetLow())
+	}
+	ops := make([]string, 0, len(req.GetOperation()))
+	for _, op := range req.GetOperation() {
+		s := fmt.Sprintf("%d %s %s", op.GetId(), op.GetOp(), aftOperationKey(op))
+		if eid := op.GetElectionId(); eid != nil {
+			s += fmt.Sprintf(" election-id %d:%d", eid.GetHigh(), eid.GetLow())
+		}
+		ops = append(ops, s)
+	}
+	return strings.Join(ops, ", ")
+}
+
+func TestApp_runFlushPlan(t *testing.T) {
+	levels := func(t *testing.T) [][]*spb.AFTEntry {
+		return [][]*spb.AFTEntry{
+			{ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1), ipv4Entry(t, "DEFAULT", "10.0.1.0/24", 3)},
+			{nhgEntry(t, "DEFAULT", 1, 1), nhgEntry(t, "DEFAULT", 3, 1)},
+			{nhEntry(t, "DEFAULT", 1, "192.0.2.1")},
+		}
+	}
+	tests := []struct {
+		name        string
+		electionID  string
+		params      *config.SessionParams
+		fibFlag     bool
+		failed      []string
+		wantDeleted int
+		wantErr     string
+		wantReqs    []string
+	}{
+		{
+			name:        "single_primary",
+			electionID:  "1:2",
+			params:      &config.SessionParams{Redundancy: "single-primary", Persistence: "preserve"},
+			wantDeleted: 5,
+			wantReqs: []string{
+				"params SINGLE_PRIMARY PRESERVE RIB_ACK",
+				"election-id 1:2",
+				"1 DELETE [DEFAULT] ipv4 10.0.0.0/24 election-id 1:2, 2 DELETE [DEFAULT] ipv4 10.0.1.0/24 election-id 1:2",
+				"3 DELETE [DEFAULT] nhg 1 election-id 1:2, 4 DELETE [DEFAULT] nhg 3 election-id 1:2",
+				"5 DELETE [DEFAULT] nh 1 election-id 1:2",
+			},
+		},
+		{
+			name:        "all_primary",
+			wantDeleted: 5,
+			wantReqs: []string{
+				"params ALL_PRIMARY DELETE RIB_ACK",
+				"1 DELETE [DEFAULT] ipv4 10.0.0.0/24, 2 DELETE [DEFAULT] ipv4 10.0.1.0/24",
+				"3 DELETE [DEFAULT] nhg 1, 4 DELETE [DEFAULT] nhg 3",
+				"5 DELETE [DEFAULT] nh 1",
+			},
+		},
+		{
+			name: "all_primary_with_election_id",
+			// the election ID is only sent in a single primary session
+			electionID:  "1:2",
+			wantDeleted: 5,
+			wantReqs: []string{
+				"params ALL_PRIMARY DELETE RIB_ACK",
+				"1 DELETE [DEFAULT] ipv4 10.0.0.0/24, 2 DELETE [DEFAULT] ipv4 10.0.1.0/24",
+				"3 DELETE [DEFAULT] nhg 1, 4 DELETE [DEFAULT] nhg 3",
+				"5 DELETE [DEFAULT] nh 1",
+			},
+		},
+		{
+			name:        "rib_fib_flag",
+			fibFlag:     true,
+			params:      &config.SessionParams{Persistence: "preserve"},
+			wantDeleted: 5,
+			wantReqs: []string{
+				"params ALL_PRIMARY PRESERVE RIB_AND_FIB_ACK",
+				"1 DELETE [DEFAULT] ipv4 10.0.0.0/24, 2 DELETE [DEFAULT] ipv4 10.0.1.0/24",
+				"3 DELETE [DEFAULT] nhg 1, 4 DELETE [DEFAULT] nhg 3",
+				"5 DELETE [DEFAULT] nh 1",
+			},
+		},
+		{
+			name:        "target_params_over_flag",
+			fibFlag:     true,
+			params:      &config.SessionParams{AckType: "rib"},
+			wantDeleted: 5,
+			wantReqs: []string{
+				"params ALL_PRIMARY DELETE RIB_ACK",
+				"1 DELETE [DEFAULT] ipv4 10.0.0.0/24, 2 DELETE [DEFAULT] ipv4 10.0.1.0/24",
+				"3 DELETE [DEFAULT] nhg 1, 4 DELETE [DEFAULT] nhg 3",
+				"5 DELETE [DEFAULT] nh 1",
+			},
+		},
+		{
+			name:        "failed_level",
+			failed:      []string{"[DEFAULT] nhg 3", "[DEFAULT] nhg 1"},
+			wantDeleted: 2,
+			wantErr:     "2 delete operation(s) failed: [DEFAULT] nhg 1: FAILED, [DEFAULT] nhg 3: FAILED",
+			// the next levels are not sent
+			wantReqs: []string{
+				"params ALL_PRIMARY DELETE RIB_ACK",
+				"1 DELETE [DEFAULT] ipv4 10.0.0.0/24, 2 DELETE [DEFAULT] ipv4 10.0.1.0/24",
+				"3 DELETE [DEFAULT] nhg 1, 4 DELETE [DEFAULT] nhg 3",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &modifyRecorder{failed: make(map[string]bool)}
+			for _, k := range tt.failed {
+				srv.failed[k] = true
+			}
+			lis := bufconn.Listen(1024 * 1024)
+			s := grpc.NewServer()
+			spb.RegisterGRIBIServer(s, srv)
+			go s.Serve(lis)
+			defer s.Stop()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			conn, err := grpc.DialContext(ctx, "bufnet",
+				grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+					return lis.DialContext(ctx)
+				}),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			a := New()
+			a.Config.ModifySessionRibFibAck = tt.fibFlag
+			tg := NewTarget(&config.TargetConfig{Name: "r1", ElectionID: tt.electionID, SessionParams: tt.params})
+			tg.conn = conn
+			deleted, err := a.runFlushPlan(ctx, tg, &flushPlan{levels: levels(t)})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("runFlushPlan() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("runFlushPlan() error = %v, want %q", err, tt.wantErr)
+			}
+			if deleted != tt.wantDeleted {
+				t.Errorf("runFlushPlan() deleted = %d, want %d", deleted, tt.wantDeleted)
+			}
+			srv.m.Lock()
+			defer srv.m.Unlock()
+			if !reflect.DeepEqual(srv.reqs, tt.wantReqs) {
+				t.Errorf("requests:\n%s\nwant:\n%s", strings.Join(srv.reqs, "\n"), strings.Join(tt.wantReqs, "\n"))
+			}
+		})
+	}
+}
@@ -2,8 +2,13 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/karimra/gribic/api"
 	spb "github.com/openconfig/gribi/v1/proto/service"
@@ -15,13 +20,37 @@ import (
 type getResponse struct {
 	TargetError
 	rsp []*spb.GetResponse
+	// forwarding chains, set with --resolve or --tree
+	tree string
+	// number of entries, set in streaming and count modes
+	count int
+	// entries statistics, set with --summary
+	summary *getSummary
+	// dependency graph, set with the dot and mermaid formats
+	graph string
 }
 
+// getProgressInterval is the interval between two progress logs in streaming and count modes
+const getProgressInterval = time.Second
+
 func (a *App) InitGetFlags(cmd *cobra.Command) {
 	cmd.ResetFlags()
 	//
 	cmd.Flags().StringVarP(&a.Config.GetNetworkInstance, "ns", "", "", "network instance name, an empty network-instance name means query all instances.")
 	cmd.Flags().StringVarP(&a.Config.GetAFT, "aft", "", "ALL", "AFT type, one of: ALL, IPv4, IPv6, NH, NHG, MPLS, MAC or PF")
+	cmd.Flags().StringVarP(&a.Config.GetPrefix, "prefix", "", "", "return the IPv4 and IPv6 entries equal to or contained in this prefix")
+	cmd.Flags().StringVarP(&a.Config.GetLPM, "lpm", "", "", "return the IPv4 or IPv6 entry with the longest prefix containing this address, per network instance")
+	cmd.Flags().Uint64VarP(&a.Config.GetNHG, "nhg", "", 0, "return the NHG entry with this ID and the entries referencing it")
+	cmd.Flags().Uint64VarP(&a.Config.GetNH, "nh", "", 0, "return the NH entry with this index and the NHG entries referencing it")
+	cmd.Flags().StringVarP(&a.Config.GetFIBStatus, "fib-status", "", "", "return the entries with this FIB status, one of: PROGRAMMED, NOT_PROGRAMMED, FIB_FAILED or UNAVAILABLE")
+	cmd.Flags().StringVarP(&a.Config.GetMetadata, "metadata", "", "", "return the entries with this metadata")
+	cmd.Flags().StringVarP(&a.Config.GetResolve, "resolve", "", "", "prefix or address to resolve to its forwarding chain, in the --ns network instance or the target's default one")
+	cmd.Flags().BoolVarP(&a.Config.GetTree, "tree", "", false, "print the forwarding chain of each returned entry")
+	cmd.Flags().StringVarP(&a.Config.GetOutput, "output", "", "", "output file, defaults to stdout. Required with the proto format")
+	cmd.Flags().BoolVarP(&a.Config.GetCount, "count", "", false, "print the number of entries per target instead of the entries")
+	cmd.Flags().BoolVarP(&a.Config.GetWatch, "watch", "", false, "run the Get RPC every --interval and print the added, removed and changed entries, until interrupted")
+	cmd.Flags().DurationVarP(&a.Config.GetInterval, "interval", "", defaultWatchInterval, "interval between two Get RPCs in watch mode")
+	cmd.Flags().BoolVarP(&a.Config.GetSummary, "summary", "", false, "print the entries count per network instance, AFT and FIB status, the NHG sizes and the unreferenced NHs and NHGs per target")
 
 	//
 	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
@@ -30,23 +59,70 @@ func (a *App) InitGetFlags(cmd *cobra.Command) {
 }
 
 func (a *App) GetRunE(cmd *cobra.Command, args []string) error {
+	switch a.Config.Format {
+	case getFormatText, getFormatNDJSON, getFormatProtoJSON, getFormatProto, getFormatDOT, getFormatMermaid:
+	default:
+		return fmt.Errorf("unknown --format %q, the get command accepts: text, ndjson, protojson, proto, dot or mermaid", a.Config.Format)
+	}
+	graph := a.Config.Format == getFormatDOT || a.Config.Format == getFormatMermaid
+	streaming := (a.Config.Format != getFormatText && !graph) || a.Config.GetCount || a.Config.GetSummary
+	if graph && (streaming || a.Config.GetResolve != "" || a.Config.GetTree || a.Config.GetWatch) {
+		return fmt.Errorf("--format %s does not support --count, --summary, --resolve, --tree or --watch", a.Config.Format)
+	}
+	if streaming && (a.Config.GetResolve != "" || a.Config.GetTree) {
+		return errors.New("--resolve and --tree only support the text format, without --count or --summary")
+	}
+	if a.Config.GetWatch && (streaming || a.Config.GetResolve != "" || a.Config.GetTree) {
+		return errors.New("--watch only supports the text format, without --count, --summary, --resolve or --tree")
+	}
+	if a.Config.GetWatch && len(a.Config.Address) == 0 {
+		// watch the targets added and removed by the loader
+		lc, err := a.Config.GetLoader()
+		if err != nil {
+			return err
+		}
+		if lc != nil {
+			return a.runLoadedTargets(a.ctx, lc, a.getWatchTarget)
+		}
+	}
 	targets, err := a.GetTargets()
 	if err != nil {
 		return err
 	}
 	a.Logger.Debugf("targets: %v", targets)
 	numTargets := len(targets)
+	var out *getOutput
+	if a.Config.Format != getFormatText && !graph {
+		out, err = newGetOutput(a.Config.Format, a.Config.GetOutput, numTargets)
+		if err != nil {
+			return err
+		}
+	}
 	responseChan := make(chan *getResponse, numTargets)
 
 	a.wg.Add(numTargets)
 	for _, t := range targets {
 		go func(t *target) {
 			defer a.wg.Done()
+			// watching targets never end, they do not take a --max-concurrency slot
+			if !a.Config.GetWatch {
+				release, err := a.acquireTarget(a.ctx)
+				if err != nil {
+					responseChan <- &getResponse{
+						TargetError: TargetError{
+							TargetName: t.Config.Address,
+							Err:        err,
+						},
+					}
+					return
+				}
+				defer release()
+			}
 			// create context
 			ctx, cancel := context.WithCancel(a.ctx)
 			defer cancel()
-			// append credentials to context
-			ctx = appendCredentials(ctx, t.Config)
+			// append metadata to context
+			ctx = appendMetadata(ctx, t.Config)
 			// create a grpc conn
 			err = a.CreateGrpcClient(ctx, t, a.createBaseDialOpts()...)
 			if err != nil {
@@ -59,6 +135,53 @@ func (a *App) GetRunE(cmd *cobra.Command, args []string) error {
 				return
 			}
 			defer t.Close()
+			if a.Config.GetWatch {
+				responseChan <- &getResponse{
+					TargetError: TargetError{
+						TargetName: t.Config.Address,
+						Err:        a.gribiGetWatch(ctx, t),
+					},
+				}
+				return
+			}
+			if graph {
+				g, err := a.gribiGetGraph(ctx, t)
+				responseChan <- &getResponse{
+					TargetError: TargetError{
+						TargetName: t.Config.Address,
+						Err:        err,
+					},
+					graph: g,
+				}
+				return
+			}
+			if a.Config.GetResolve != "" || a.Config.GetTree {
+				tree, err := a.gribiGetTree(ctx, t)
+				responseChan <- &getResponse{
+					TargetError: TargetError{
+						TargetName: t.Config.Address,
+						Err:        err,
+					},
+					tree: tree,
+				}
+				return
+			}
+			if streaming {
+				var sum *getSummary
+				if a.Config.GetSummary {
+					sum = newGetSummary()
+				}
+				n, err := a.gribiGetStream(ctx, t, out, sum)
+				responseChan <- &getResponse{
+					TargetError: TargetError{
+						TargetName: t.Config.Address,
+						Err:        err,
+					},
+					count:   n,
+					summary: sum,
+				}
+				return
+			}
 			rsp, err := a.gribiGet(ctx, t)
 			responseChan <- &getResponse{
 				TargetError: TargetError{
@@ -74,6 +197,13 @@ func (a *App) GetRunE(cmd *cobra.Command, args []string) error {
 	close(responseChan)
 
 	errs := make([]error, 0) //, numTargets)
+	if out != nil {
+		err = out.close()
+		if err != nil {
+			a.Logger.Error(err)
+			errs = append(errs, err)
+		}
+	}
 	result := make([]*getResponse, 0, numTargets)
 	for rsp := range responseChan {
 		if rsp.Err != nil {
@@ -85,7 +215,36 @@ func (a *App) GetRunE(cmd *cobra.Command, args []string) error {
 		result = append(result, rsp)
 	}
 	a.Logger.Printf("got %d results", len(result))
+	if streaming {
+		for _, r := range result {
+			if r.summary != nil {
+				fmt.Printf("%q:\n%s\n", r.TargetName, r.summary)
+			}
+			if a.Config.GetCount {
+				fmt.Printf("%q: %d entries\n", r.TargetName, r.count)
+				continue
+			}
+			if out != nil {
+				a.Logger.Infof("%q: wrote %d entries", r.TargetName, r.count)
+			}
+		}
+		return a.handleErrs(errs)
+	}
+	if graph {
+		for _, r := range result {
+			err = a.writeGraph(r.TargetName, r.graph, numTargets > 1)
+			if err != nil {
+				a.Logger.Error(err)
+				errs = append(errs, err)
+			}
+		}
+		return a.handleErrs(errs)
+	}
 	for _, r := range result {
+		if r.tree != "" {
+			fmt.Printf("%q:\n%s", r.TargetName, r.tree)
+			continue
+		}
 		for _, gr := range r.rsp {
 			a.Logger.Infof("%q:\n%v", r.TargetName, prototext.Format(gr))
 		}
@@ -94,6 +253,46 @@ func (a *App) GetRunE(cmd *cobra.Command, args []string) error {
 }
 
 func (a *App) gribiGet(ctx context.Context, t *target) (*spb.GetResponse, error) {
+	req, err := a.createGetRequest()
+	if err != nil {
+		return nil, err
+	}
+	f, err := a.newGetFilter()
+	if err != nil {
+		return nil, err
+	}
+	t.gRIBIClient = spb.NewGRIBIClient(t.conn)
+	return a.get(ctx, t, req, f)
+}
+
+// gribiGetStream runs a Get RPC and writes each entry to out as it is received,
+// it returns the number of entries.
+// If out is nil, the entries are only counted.
+// If sum is not nil, the entries are added to the summary.
+func (a *App) gribiGetStream(ctx context.Context, t *target, out *getOutput, sum *getSummary) (int, error) {
+	req, err := a.createGetRequest()
+	if err != nil {
+		return 0, err
+	}
+	f, err := a.newGetFilter()
+	if err != nil {
+		return 0, err
+	}
+	t.gRIBIClient = spb.NewGRIBIClient(t.conn)
+	n, err := a.getEach(ctx, t, req, f, true, func(e *spb.AFTEntry) error {
+		if sum != nil {
+			sum.add(e)
+		}
+		if out == nil {
+			return nil
+		}
+		return out.write(t.Config.Name, e)
+	})
+	a.Logger.Infof("target %s: received %d entries", t.Config.Name, n)
+	return n, err
+}
+
+func (a *App) createGetRequest() (*spb.GetRequest, error) {
 	opts := make([]api.GRIBIOption, 0, 2)
 	opts = append(opts, api.AFTType(a.Config.GetAFT))
 	if a.Config.GetNetworkInstance == "" {
@@ -101,23 +300,165 @@ func (a *App) gribiGet(ctx context.Context, t *target) (*spb.GetResponse, error)
 	} else {
 		opts = append(opts, api.NetworkInstance(a.Config.GetNetworkInstance))
 	}
+	return api.NewGetRequest(opts...)
+}
+
+// gribiGetTree gets all the AFT entries of the target and returns the forwarding chain
+// of the resolved prefix, or of each entry selected by the get flags.
+func (a *App) gribiGetTree(ctx context.Context, t *target) (string, error) {
+	idx, err := a.getAFTIndex(ctx, t)
+	if err != nil {
+		return "", err
+	}
+	sb := new(strings.Builder)
+	if a.Config.GetResolve != "" {
+		ni := a.Config.GetNetworkInstance
+		if ni == "" {
+			ni = t.Config.DefaultNI
+		}
+		e, dst, err := idx.resolve(ni, a.Config.GetResolve)
+		if err != nil {
+			return "", err
+		}
+		if e == nil {
+			return fmt.Sprintf("%s [%s]: no matching route\n", a.Config.GetResolve, ni), nil
+		}
+		sb.WriteString(newChainResolver(idx, dst).tree(e).String())
+		return sb.String(), nil
+	}
+	roots, err := a.getChainRoots(idx)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range roots {
+		var dst netip.Addr
+		if p, ok := entryPrefix(e); ok {
+			dst = p.Addr()
+		}
+		sb.WriteString(newChainResolver(idx, dst).tree(e).String())
+	}
+	return sb.String(), nil
+}
+
+// gribiGetGraph gets all the AFT entries of the target and returns
+// the dependency graph of the entries selected by the get flags, in the --format language.
+func (a *App) gribiGetGraph(ctx context.Context, t *target) (string, error) {
+	idx, err := a.getAFTIndex(ctx, t)
+	if err != nil {
+		return "", err
+	}
+	roots, err := a.getChainRoots(idx)
+	if err != nil {
+		return "", err
+	}
+	g := newAFTGraph(idx)
+	for _, e := range roots {
+		g.add(e)
+	}
+	if a.Config.Format == getFormatMermaid {
+		return g.mermaid(t.Config.Name), nil
+	}
+	return g.dot(t.Config.Name), nil
+}
 
-	req, err := api.NewGetRequest(opts...)
+// writeGraph writes the graph of a target to the --output file,
+// one file per target if there are multiple targets, or to stdout.
+func (a *App) writeGraph(target, graph string, multi bool) error {
+	if a.Config.GetOutput == "" {
+		fmt.Print(graph)
+		return nil
+	}
+	path := targetOutputPath(a.Config.GetOutput, target, multi)
+	err := os.WriteFile(path, []byte(graph), 0644)
+	if err != nil {
+		return fmt.Errorf("%q failed to write graph: %v", target, err)
+	}
+	a.Logger.Infof("%q: wrote graph to %s", target, path)
+	return nil
+}
+
+// getAFTIndex gets all the AFT entries of the target, in all network instances,
+// they are needed to resolve the forwarding chains.
+func (a *App) getAFTIndex(ctx context.Context, t *target) (*aftIndex, error) {
+	req, err := api.NewGetRequest(api.NSAll(), api.AFTTypeAll())
 	if err != nil {
 		return nil, err
 	}
 	t.gRIBIClient = spb.NewGRIBIClient(t.conn)
-	return a.get(ctx, t, req)
+	rsp, err := a.get(ctx, t, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	idx := newAFTIndex()
+	idx.add(rsp.GetEntry()...)
+	return idx, nil
 }
 
-func (a *App) get(ctx context.Context, t *target, req *spb.GetRequest) (*spb.GetResponse, error) {
-	stream, err := t.gRIBIClient.Get(ctx, req)
+// getChainRoots returns the entries of the index selected by the get flags,
+// sorted by network instance, AFT type and key.
+func (a *App) getChainRoots(idx *aftIndex) ([]*spb.AFTEntry, error) {
+	rootsReq, err := api.NewGetRequest(api.AFTType(a.Config.GetAFT))
 	if err != nil {
 		return nil, err
 	}
+	f, err := a.newGetFilter()
+	if err != nil {
+		return nil, err
+	}
+	roots := make([]*spb.AFTEntry, 0)
+	for _, e := range idx.entries() {
+		if a.Config.GetNetworkInstance != "" && e.GetNetworkInstance() != a.Config.GetNetworkInstance {
+			continue
+		}
+		if rootsReq.GetAft() != spb.AFTType_ALL && rootsReq.GetAft() != aftEntryAFTType(e) {
+			continue
+		}
+		roots = append(roots, e)
+	}
+	roots = append(f.filter(roots), f.result()...)
+	return sortedTreeRoots(roots), nil
+}
+
+// get runs a Get RPC and returns the received entries,
+// the filter f, if not nil, is applied to each response chunk.
+func (a *App) get(ctx context.Context, t *target, req *spb.GetRequest, f *getFilter) (*spb.GetResponse, error) {
 	resp := &spb.GetResponse{
 		Entry: make([]*spb.AFTEntry, 0),
 	}
+	_, err := a.getEach(ctx, t, req, f, false, func(e *spb.AFTEntry) error {
+		resp.Entry = append(resp.Entry, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.Logger.Infof("target %s: final get response: %+v", t.Config.Name, resp)
+	return resp, nil
+}
+
+// getEach runs a Get RPC and calls fn for each received entry matching the filter f,
+// it returns the number of matching entries.
+// If progress is true, the number of received entries is logged periodically.
+//
+// The RPC is bounded by the target get-timeout and retried according to its retry policy,
+// unless it fails after the first response is received.
+func (a *App) getEach(ctx context.Context, t *target, req *spb.GetRequest, f *getFilter, progress bool, fn func(*spb.AFTEntry) error) (int, error) {
+	var matched int
+	err := a.withRetry(ctx, t, "Get", t.Config.GetTimeout, func(ctx context.Context) error {
+		var err error
+		matched, err = a.getEachAttempt(ctx, t, req, f, progress, fn)
+		return err
+	})
+	return matched, err
+}
+
+func (a *App) getEachAttempt(ctx context.Context, t *target, req *spb.GetRequest, f *getFilter, progress bool, fn func(*spb.AFTEntry) error) (int, error) {
+	stream, err := t.gRIBIClient.Get(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	var responses, received, matched int
+	lastProgress := time.Now()
 	for {
 		getres, err := stream.Recv()
 		if err == io.EOF {
@@ -125,33 +466,67 @@ func (a *App) get(ctx context.Context, t *target, req *spb.GetRequest) (*spb.Get
 			break
 		}
 		if err != nil {
-			return nil, err
+			if responses > 0 {
+				return matched, &noRetryError{err: err}
+			}
+			return matched, err
 		}
+		responses++
 		a.Logger.Debugf("target %s: intermediate get response: %v", t.Config.Name, getres)
-		resp.Entry = append(resp.Entry, getres.GetEntry()...)
+		received += len(getres.GetEntry())
+		for _, e := range f.filter(getres.GetEntry()) {
+			err = fn(e)
+			if err != nil {
+				return matched, err
+			}
+			matched++
+		}
+		if progress && time.Since(lastProgress) >= getProgressInterval {
+			a.Logger.Infof("target %s: received %d entries, %d matching", t.Config.Name, received, matched)
+			lastProgress = time.Now()
+		}
 	}
-	a.Logger.Infof("target %s: final get response: %+v", t.Config.Name, resp)
-	return resp, nil
+	for _, e := range f.result() {
+		err = fn(e)
+		if err != nil {
+			return matched, err
+		}
+		matched++
+	}
+	return matched, nil
 }
 
+// getChan runs a Get RPC bounded by the target get-timeout
+// and sends the responses on the returned channel,
+// the RPC error, io.EOF when it completes, is sent on the error channel.
 func (a *App) getChan(ctx context.Context, t *target, req *spb.GetRequest) (chan *spb.GetResponse, chan error) {
 	rspChan := make(chan *spb.GetResponse)
 	errChan := make(chan error)
 	go func() {
 		defer close(rspChan)
 		defer close(errChan)
-		stream, err := t.gRIBIClient.Get(ctx, req)
-		if err != nil {
-			errChan <- err
-			return
-		}
-		for {
-			getres, err := stream.Recv()
+		err := callWithTimeout(ctx, "Get", t.Config.GetTimeout, func(sctx context.Context) error {
+			stream, err := t.gRIBIClient.Get(sctx, req)
 			if err != nil {
-				errChan <- err
-				return
+				return err
+			}
+			for {
+				getres, err := stream.Recv()
+				if err != nil {
+					return err
+				}
+				select {
+				case rspChan <- getres:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		})
+		if ctx.Err() == nil {
+			select {
+			case errChan <- err:
+			case <-ctx.Done():
 			}
-			rspChan <- getres
 		}
 	}()
 	return rspChan, errChan
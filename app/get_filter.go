@@ -0,0 +1,212 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+// getFilter selects the Get response entries client side,
+// the filters set are ANDed together.
+type getFilter struct {
+	// IPv4 and IPv6 entries equal to or contained in prefix
+	prefix *netip.Prefix
+	// the IPv4 or IPv6 entry with the longest prefix containing lpm,
+	// per network instance
+	lpm *netip.Addr
+	// NHG entries with this ID and the entries referencing it
+	nhg uint64
+	// NH entries with this index and the NHG entries referencing it
+	nh uint64
+	// entries with this FIB status
+	fibStatus *spb.AFTEntry_Status
+	// entries with this metadata
+	metadata []byte
+
+	// longest prefix match candidates, per network instance
+	lpmBest map[string]*lpmCandidate
+}
+
+type lpmCandidate struct {
+	bits  int
+	entry *spb.AFTEntry
+}
+
+// newGetFilter builds a getFilter from the get command flags,
+// it returns nil if no filter is set.
+func (a *App) newGetFilter() (*getFilter, error) {
+	return newEntryFilter(a.Config.GetPrefix, a.Config.GetLPM, a.Config.GetNHG, a.Config.GetNH,
+		a.Config.GetFIBStatus, a.Config.GetMetadata)
+}
+
+// newEntryFilter builds a getFilter, it returns nil if no filter is set.
+func newEntryFilter(prefix, lpm string, nhg, nh uint64, fibStatus, metadata string) (*getFilter, error) {
+	f := &getFilter{
+		nhg:      nhg,
+		nh:       nh,
+		metadata: []byte(metadata),
+		lpmBest:  make(map[string]*lpmCandidate),
+	}
+	var set bool
+	if prefix != "" {
+		p, err := netip.ParsePrefix(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix filter: %w", err)
+		}
+		p = p.Masked()
+		f.prefix = &p
+		set = true
+	}
+	if lpm != "" {
+		addr, err := netip.ParseAddr(lpm)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lpm address: %w", err)
+		}
+		f.lpm = &addr
+		set = true
+	}
+	if fibStatus != "" {
+		st, err := parseFIBStatus(fibStatus)
+		if err != nil {
+			return nil, err
+		}
+		f.fibStatus = &st
+		set = true
+	}
+	if !set && f.nhg == 0 && f.nh == 0 && len(f.metadata) == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// parseFIBStatus parses an AFT entry status name,
+// FIB_FAILED is accepted as an alias of NOT_PROGRAMMED.
+func parseFIBStatus(s string) (spb.AFTEntry_Status, error) {
+	s = strings.ToUpper(s)
+	if s == "FIB_FAILED" {
+		return spb.AFTEntry_NOT_PROGRAMMED, nil
+	}
+	if v, ok := spb.AFTEntry_Status_value[s]; ok {
+		return spb.AFTEntry_Status(v), nil
+	}
+	return 0, fmt.Errorf("unknown fib status %q, expected one of: PROGRAMMED, NOT_PROGRAMMED, FIB_FAILED or UNAVAILABLE", s)
+}
+
+// filter returns the entries of a Get response chunk that match the filter.
+// If a longest prefix match lookup is set, the matching entries are kept
+// as candidates and returned by result().
+func (f *getFilter) filter(entries []*spb.AFTEntry) []*spb.AFTEntry {
+	if f == nil {
+		return entries
+	}
+	matched := make([]*spb.AFTEntry, 0)
+	for _, e := range entries {
+		if !f.match(e) {
+			continue
+		}
+		if f.lpm != nil {
+			f.lpmCandidate(e)
+			continue
+		}
+		matched = append(matched, e)
+	}
+	return matched
+}
+
+// result returns the longest prefix match entries, if any.
+func (f *getFilter) result() []*spb.AFTEntry {
+	if f == nil || f.lpm == nil {
+		return nil
+	}
+	nis := make([]string, 0, len(f.lpmBest))
+	for ni := range f.lpmBest {
+		nis = append(nis, ni)
+	}
+	sort.Strings(nis)
+	entries := make([]*spb.AFTEntry, 0, len(nis))
+	for _, ni := range nis {
+		entries = append(entries, f.lpmBest[ni].entry)
+	}
+	return entries
+}
+
+func (f *getFilter) match(e *spb.AFTEntry) bool {
+	if f.prefix != nil || f.lpm != nil {
+		p, ok := entryPrefix(e)
+		if !ok {
+			return false
+		}
+		if f.prefix != nil && !(p.Bits() >= f.prefix.Bits() && f.prefix.Contains(p.Addr())) {
+			return false
+		}
+		if f.lpm != nil && !p.Contains(*f.lpm) {
+			return false
+		}
+	}
+	if f.nhg != 0 {
+		switch e.GetEntry().(type) {
+		case *spb.AFTEntry_NextHopGroup:
+			if e.GetNextHopGroup().GetId() != f.nhg {
+				return false
+			}
+		default:
+			if nhg, ok := aftEntryNHG(e); !ok || nhg != f.nhg {
+				return false
+			}
+		}
+	}
+	if f.nh != 0 {
+		switch e.GetEntry().(type) {
+		case *spb.AFTEntry_NextHop:
+			if e.GetNextHop().GetIndex() != f.nh {
+				return false
+			}
+		case *spb.AFTEntry_NextHopGroup:
+			var found bool
+			for _, idx := range nhgNextHops(e) {
+				if idx == f.nh {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	if f.fibStatus != nil && e.GetFibStatus() != *f.fibStatus {
+		return false
+	}
+	if len(f.metadata) > 0 && !bytes.Equal(aftEntryMetadata(e), f.metadata) {
+		return false
+	}
+	return true
+}
+
+func (f *getFilter) lpmCandidate(e *spb.AFTEntry) {
+	p, _ := entryPrefix(e)
+	ni := e.GetNetworkInstance()
+	if c, ok := f.lpmBest[ni]; ok && c.bits >= p.Bits() {
+		return
+	}
+	f.lpmBest[ni] = &lpmCandidate{bits: p.Bits(), entry: e}
+}
+
+// entryPrefix returns the parsed prefix of an IPv4 or IPv6 entry.
+func entryPrefix(e *spb.AFTEntry) (netip.Prefix, bool) {
+	s, ok := aftEntryPrefix(e)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	return p.Masked(), true
+}
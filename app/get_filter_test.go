@@ -0,0 +1,202 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+func TestNewEntryFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    string
+		lpm       string
+		nhg       uint64
+		fibStatus string
+		metadata  string
+		wantNil   bool
+		wantErr   bool
+	}{
+		{name: "no_filter", wantNil: true},
+		{name: "prefix", prefix: "10.1.2.3/16"},
+		{name: "invalid_prefix", prefix: "10.1.2.3", wantErr: true},
+		{name: "lpm", lpm: "10.1.2.3"},
+		{name: "invalid_lpm", lpm: "10.1.2.0/24", wantErr: true},
+		{name: "nhg", nhg: 1},
+		{name: "fib_status", fibStatus: "fib_failed"},
+		{name: "invalid_fib_status", fibStatus: "failed", wantErr: true},
+		{name: "metadata", metadata: "md"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newEntryFilter(tt.prefix, tt.lpm, tt.nhg, 0, tt.fibStatus, tt.metadata)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newEntryFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (f == nil) != tt.wantNil {
+				t.Errorf("newEntryFilter() = %v, wantNil %v", f, tt.wantNil)
+			}
+		})
+	}
+	f, err := newEntryFilter("10.1.2.3/16", "", 0, 0, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.prefix.String() != "10.1.0.0/16" {
+		t.Errorf("prefix filter = %s, want the masked prefix 10.1.0.0/16", f.prefix)
+	}
+	if st, _ := parseFIBStatus("FIB_FAILED"); st != spb.AFTEntry_NOT_PROGRAMMED {
+		t.Errorf("parseFIBStatus(FIB_FAILED) = %s, want NOT_PROGRAMMED", st)
+	}
+}
+
+func TestGetFilter_filter(t *testing.T) {
+	entries := []*spb.AFTEntry{
+		ipv4Entry(t, "DEFAULT", "10.0.0.0/8", 1),
+		ipv4Entry(t, "DEFAULT", "10.1.0.0/16", 2),
+		ipv4Entry(t, "DEFAULT", "10.1.1.0/24", 1),
+		ipv4Entry(t, "DEFAULT", "10.2.0.0/16", 2),
+		ipv6Entry(t, "DEFAULT", "2001:db8::/32", 1),
+		nhgEntry(t, "DEFAULT", 1, 1, 2),
+		nhgEntry(t, "DEFAULT", 2, 3),
+		nhEntry(t, "DEFAULT", 1, "192.0.2.1"),
+		nhEntry(t, "DEFAULT", 2, "192.0.2.2"),
+		testEntry(t, `network_instance: "DEFAULT" fib_status: NOT_PROGRAMMED ipv4: {prefix: "10.3.0.0/16" ipv4_entry: {next_hop_group: {value: 2} entry_metadata: {value: "md"}}}`),
+	}
+	tests := []struct {
+		name      string
+		prefix    string
+		nhg       uint64
+		nh        uint64
+		fibStatus string
+		metadata  string
+		want      []string
+	}{
+		{
+			name: "no_filter",
+			want: entryKeys(entries),
+		},
+		{
+			name:   "prefix_contained",
+			prefix: "10.1.0.0/16",
+			want:   []string{"[DEFAULT] ipv4 10.1.0.0/16", "[DEFAULT] ipv4 10.1.1.0/24"},
+		},
+		{
+			name:   "prefix_ipv6",
+			prefix: "2001:db8::/16",
+			want:   []string{"[DEFAULT] ipv6 2001:db8::/32"},
+		},
+		{
+			name: "nhg_and_references",
+			nhg:  2,
+			want: []string{"[DEFAULT] ipv4 10.1.0.0/16", "[DEFAULT] ipv4 10.2.0.0/16", "[DEFAULT] nhg 2", "[DEFAULT] ipv4 10.3.0.0/16"},
+		},
+		{
+			name: "nh_and_nhgs",
+			nh:   2,
+			want: []string{"[DEFAULT] nhg 1", "[DEFAULT] nh 2"},
+		},
+		{
+			name:   "prefix_and_nhg",
+			prefix: "10.0.0.0/8",
+			nhg:    1,
+			want:   []string{"[DEFAULT] ipv4 10.0.0.0/8", "[DEFAULT] ipv4 10.1.1.0/24"},
+		},
+		{
+			name:      "fib_status",
+			fibStatus: "FIB_FAILED",
+			want:      []string{"[DEFAULT] ipv4 10.3.0.0/16"},
+		},
+		{
+			name:     "metadata",
+			metadata: "md",
+			want:     []string{"[DEFAULT] ipv4 10.3.0.0/16"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newEntryFilter(tt.prefix, "", tt.nhg, tt.nh, tt.fibStatus, tt.metadata)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := entryKeys(f.filter(entries))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filter() = %v, want %v", got, tt.want)
+			}
+			if f.result() != nil {
+				t.Errorf("result() without lpm = %v, want nil", f.result())
+			}
+		})
+	}
+}
+
+func TestGetFilter_lpm(t *testing.T) {
+	// the entries are split in chunks, as in a Get response stream
+	chunks := [][]*spb.AFTEntry{
+		{
+			ipv4Entry(t, "vrf1", "10.0.0.0/8", 1),
+			ipv4Entry(t, "DEFAULT", "0.0.0.0/0", 1),
+			ipv4Entry(t, "DEFAULT", "10.1.0.0/16", 1),
+		},
+		{
+			ipv4Entry(t, "DEFAULT", "10.1.1.0/24", 1),
+			ipv4Entry(t, "DEFAULT", "10.1.2.0/24", 1),
+			ipv4Entry(t, "vrf2", "10.2.0.0/16", 1),
+			ipv6Entry(t, "DEFAULT", "::/0", 1),
+			nhgEntry(t, "DEFAULT", 1, 1),
+		},
+		{
+			ipv4Entry(t, "vrf1", "10.1.0.0/16", 1),
+			ipv4Entry(t, "DEFAULT", "10.0.0.0/8", 1),
+		},
+	}
+	tests := []struct {
+		name string
+		lpm  string
+		nhg  uint64
+		want []string
+	}{
+		{
+			name: "per_network_instance",
+			lpm:  "10.1.1.5",
+			want: []string{"[DEFAULT] ipv4 10.1.1.0/24", "[vrf1] ipv4 10.1.0.0/16"},
+		},
+		{
+			name: "default_route",
+			lpm:  "192.0.2.1",
+			want: []string{"[DEFAULT] ipv4 0.0.0.0/0"},
+		},
+		{
+			name: "ipv6",
+			lpm:  "2001:db8::1",
+			want: []string{"[DEFAULT] ipv6 ::/0"},
+		},
+		{
+			name: "no_match_with_nhg",
+			lpm:  "10.1.1.5",
+			nhg:  2,
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newEntryFilter("", tt.lpm, tt.nhg, 0, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, chunk := range chunks {
+				if matched := f.filter(chunk); len(matched) != 0 {
+					t.Errorf("filter() with lpm returned entries: %v", entryKeys(matched))
+				}
+			}
+			got := entryKeys(f.result())
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package app
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+// Get graph output formats
+const (
+	getFormatDOT     = "dot"
+	getFormatMermaid = "mermaid"
+)
+
+// graph node kinds
+const (
+	graphNodeRoute     = "route"
+	graphNodeNHG       = "nhg"
+	graphNodeNH        = "nh"
+	graphNodeInterface = "interface"
+	graphNodeNI        = "network-instance"
+	graphNodeDangling  = "dangling"
+)
+
+// aftGraph is the dependency graph of AFT entries:
+// routes -> NHGs -> NHs -> interfaces or network instances.
+type aftGraph struct {
+	idx   *aftIndex
+	nodes map[string]*graphNode
+	// node keys in the order they were added
+	order []string
+	edges []*graphEdge
+}
+
+type graphNode struct {
+	id    string
+	kind  string
+	label string
+}
+
+type graphEdge struct {
+	from   string
+	to     string
+	label  string
+	dashed bool
+}
+
+func newAFTGraph(idx *aftIndex) *aftGraph {
+	return &aftGraph{
+		idx:   idx,
+		nodes: make(map[string]*graphNode),
+	}
+}
+
+// node returns the node with the given key, creating it if needed,
+// and whether it was created.
+func (g *aftGraph) node(key, kind, label string) (*graphNode, bool) {
+	if n, ok := g.nodes[key]; ok {
+		return n, false
+	}
+	n := &graphNode{
+		id:    fmt.Sprintf("n%d", len(g.order)+1),
+		kind:  kind,
+		label: label,
+	}
+	g.nodes[key] = n
+	g.order = append(g.order, key)
+	return n, true
+}
+
+func (g *aftGraph) edge(from, to *graphNode, label string, dashed bool) {
+	g.edges = append(g.edges, &graphEdge{from: from.id, to: to.id, label: label, dashed: dashed})
+}
+
+// add adds the entry e and the entries it depends on to the graph.
+func (g *aftGraph) add(e *spb.AFTEntry) *graphNode {
+	kind := graphNodeRoute
+	switch e.GetEntry().(type) {
+	case *spb.AFTEntry_NextHopGroup:
+		kind = graphNodeNHG
+	case *spb.AFTEntry_NextHop:
+		kind = graphNodeNH
+	}
+	n, created := g.node(aftEntryKey(e), kind, entryLabel(e))
+	if !created {
+		return n
+	}
+	ni := e.GetNetworkInstance()
+	switch e.GetEntry().(type) {
+	case *spb.AFTEntry_NextHopGroup:
+		nhg := e.GetNextHopGroup().GetNextHopGroup()
+		for _, m := range nhg.GetNextHop() {
+			label := ""
+			if w := m.GetNextHop().GetWeight(); w != nil {
+				label = fmt.Sprintf("weight %d", w.GetValue())
+			}
+			g.edge(n, g.nhNode(ni, m.GetIndex()), label, false)
+		}
+		if b := nhg.GetBackupNextHopGroup(); b != nil {
+			g.edge(n, g.nhgNode(ni, b.GetValue()), "backup", true)
+		}
+	case *spb.AFTEntry_NextHop:
+		g.addNHTargets(n, e)
+	default:
+		id, ok := aftEntryNHG(e)
+		if !ok {
+			return n
+		}
+		if nhgNI := aftEntryNHGNetworkInstance(e); nhgNI != "" {
+			ni = nhgNI
+		}
+		g.edge(n, g.nhgNode(ni, id), "", false)
+	}
+	return n
+}
+
+func (g *aftGraph) nhgNode(ni string, id uint64) *graphNode {
+	if e, ok := g.idx.nhg(ni, id); ok {
+		return g.add(e)
+	}
+	n, _ := g.node(aftRefKey(ni, aftTypeNHG, id), graphNodeDangling,
+		fmt.Sprintf("nhg %d [%s]: DANGLING, not found", id, ni))
+	return n
+}
+
+func (g *aftGraph) nhNode(ni string, index uint64) *graphNode {
+	if e, ok := g.idx.nh(ni, index); ok {
+		return g.add(e)
+	}
+	n, _ := g.node(aftRefKey(ni, aftTypeNH, index), graphNodeDangling,
+		fmt.Sprintf("nh %d [%s]: DANGLING, not found", index, ni))
+	return n
+}
+
+// addNHTargets links a next hop to its interface and to the network instance it points to.
+// If the next hop encapsulates, the route of the tunnel destination in that network instance
+// is added as well.
+func (g *aftGraph) addNHTargets(n *graphNode, e *spb.AFTEntry) {
+	nh := e.GetNextHop().GetNextHop()
+	if ifr := nh.GetInterfaceRef(); ifr != nil {
+		itf := ifr.GetInterface().GetValue()
+		if sub := ifr.GetSubinterface(); sub != nil {
+			itf = fmt.Sprintf("%s.%d", itf, sub.GetValue())
+		}
+		in, _ := g.node("interface "+itf, graphNodeInterface, "interface "+itf)
+		g.edge(n, in, "", false)
+	}
+	ni := nh.GetNetworkInstance().GetValue()
+	if ni == "" {
+		return
+	}
+	nin, _ := g.node("network-instance "+ni, graphNodeNI, "network-instance "+ni)
+	dst, err := netip.ParseAddr(nh.GetIpInIp().GetDstIp().GetValue())
+	if err != nil {
+		g.edge(n, nin, "", false)
+		return
+	}
+	route := g.idx.lookup(ni, dst)
+	if route == nil {
+		g.edge(n, nin, fmt.Sprintf("lookup %s: no matching route", dst), false)
+		return
+	}
+	g.edge(n, nin, fmt.Sprintf("lookup %s", dst), false)
+	g.edge(nin, g.add(route), dst.String(), false)
+}
+
+// dot returns the graph in the Graphviz DOT language.
+func (g *aftGraph) dot(name string) string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "digraph %s {\n", dotQuote(name))
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [fontname=\"monospace\"];\n")
+	for _, k := range g.order {
+		n := g.nodes[k]
+		fmt.Fprintf(sb, "  %s [label=%s, %s];\n", n.id, dotQuote(n.label), dotNodeStyle(n.kind))
+	}
+	for _, e := range g.edges {
+		attrs := make([]string, 0, 2)
+		if e.label != "" {
+			attrs = append(attrs, "label="+dotQuote(e.label))
+		}
+		if e.dashed {
+			attrs = append(attrs, "style=dashed")
+		}
+		if len(attrs) == 0 {
+			fmt.Fprintf(sb, "  %s -> %s;\n", e.from, e.to)
+			continue
+		}
+		fmt.Fprintf(sb, "  %s -> %s [%s];\n", e.from, e.to, strings.Join(attrs, ", "))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func dotNodeStyle(kind string) string {
+	switch kind {
+	case graphNodeNHG:
+		return "shape=ellipse"
+	case graphNodeNH:
+		return "shape=box, style=rounded"
+	case graphNodeInterface:
+		return "shape=cds"
+	case graphNodeNI:
+		return "shape=folder"
+	case graphNodeDangling:
+		return "shape=box, color=red"
+	}
+	return "shape=box"
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// mermaid returns the graph as a Mermaid flowchart.
+func (g *aftGraph) mermaid(name string) string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "%%%% %s\n", name)
+	sb.WriteString("flowchart LR\n")
+	for _, k := range g.order {
+		n := g.nodes[k]
+		l, r := mermaidNodeShape(n.kind)
+		fmt.Fprintf(sb, "  %s%s\"%s\"%s\n", n.id, l, mermaidEscape(n.label), r)
+	}
+	for _, e := range g.edges {
+		arrow := "-->"
+		if e.dashed {
+			arrow = "-.->"
+		}
+		if e.label == "" {
+			fmt.Fprintf(sb, "  %s %s %s\n", e.from, arrow, e.to)
+			continue
+		}
+		fmt.Fprintf(sb, "  %s %s|\"%s\"| %s\n", e.from, arrow, mermaidEscape(e.label), e.to)
+	}
+	for _, k := range g.order {
+		if n := g.nodes[k]; n.kind == graphNodeDangling {
+			fmt.Fprintf(sb, "  style %s stroke:red\n", n.id)
+		}
+	}
+	return sb.String()
+}
+
+func mermaidNodeShape(kind string) (string, string) {
+	switch kind {
+	case graphNodeNHG:
+		return "((", "))"
+	case graphNodeNH:
+		return "(", ")"
+	case graphNodeInterface:
+		return ">", "]"
+	case graphNodeNI:
+		return "[(", ")]"
+	}
+	return "[", "]"
+}
+
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"testing"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+func testGraph(t *testing.T) *aftGraph {
+	idx := newAFTIndex()
+	idx.add(
+		ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1),
+		testEntry(t, `network_instance: "DEFAULT" next_hop_group: {id: 1 next_hop_group: {
+			next_hop: {index: 1 next_hop: {weight: {value: 1}}}
+			next_hop: {index: 2 next_hop: {weight: {value: 3}}}
+			backup_next_hop_group: {value: 2}}}`),
+		testEntry(t, `network_instance: "DEFAULT" next_hop: {index: 1 next_hop: {
+			interface_ref: {interface: {value: "Ethernet1"} subinterface: {value: 0}}}}`),
+		testEntry(t, `network_instance: "DEFAULT" next_hop: {index: 2 next_hop: {
+			network_instance: {value: "vrf1"}
+			ip_in_ip: {dst_ip: {value: "10.1.1.1"} src_ip: {value: "10.0.0.1"}}}}`),
+		ipv4Entry(t, "vrf1", "10.1.0.0/16", 3),
+		// the route of the NHG 1 from another network instance
+		testEntry(t, `network_instance: "vrf2" ipv4: {prefix: "10.2.0.0/24" ipv4_entry: {
+			next_hop_group: {value: 1} next_hop_group_network_instance: {value: "DEFAULT"}}}`),
+	)
+	g := newAFTGraph(idx)
+	for _, e := range []*spb.AFTEntry{
+		ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1),
+		testEntry(t, `network_instance: "vrf2" ipv4: {prefix: "10.2.0.0/24" ipv4_entry: {
+			next_hop_group: {value: 1} next_hop_group_network_instance: {value: "DEFAULT"}}}`),
+	} {
+		g.add(e)
+	}
+	return g
+}
+
+func TestAFTGraph_dot(t *testing.T) {
+	want := `digraph "r1" {
+  rankdir=LR;
+  node [fontname="monospace"];
+  n1 [label="ipv4 10.0.0.0/24 [DEFAULT]", shape=box];
+  n2 [label="nhg 1 [DEFAULT]", shape=ellipse];
+  n3 [label="nh 1 [DEFAULT]: interface=Ethernet1.0", shape=box, style=rounded];
+  n4 [label="interface Ethernet1.0", shape=cds];
+  n5 [label="nh 2 [DEFAULT]: network-instance=vrf1, ip-in-ip=10.0.0.1->10.1.1.1", shape=box, style=rounded];
+  n6 [label="network-instance vrf1", shape=folder];
+  n7 [label="ipv4 10.1.0.0/16 [vrf1]", shape=box];
+  n8 [label="nhg 3 [vrf1]: DANGLING, not found", shape=box, color=red];
+  n9 [label="nhg 2 [DEFAULT]: DANGLING, not found", shape=box, color=red];
+  n10 [label="ipv4 10.2.0.0/24 [vrf2]", shape=box];
+  n3 -> n4;
+  n2 -> n3 [label="weight 1"];
+  n5 -> n6 [label="lookup 10.1.1.1"];
+  n7 -> n8;
+  n6 -> n7 [label="10.1.1.1"];
+  n2 -> n5 [label="weight 3"];
+  n2 -> n9 [label="backup", style=dashed];
+  n1 -> n2;
+  n10 -> n2;
+}
+`
+	if got := testGraph(t).dot("r1"); got != want {
+		t.Errorf("dot() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestAFTGraph_mermaid(t *testing.T) {
+	want := `%% r1
+flowchart LR
+  n1["ipv4 10.0.0.0/24 [DEFAULT]"]
+  n2(("nhg 1 [DEFAULT]"))
+  n3("nh 1 [DEFAULT]: interface=Ethernet1.0")
+  n4>"interface Ethernet1.0"]
+  n5("nh 2 [DEFAULT]: network-instance=vrf1, ip-in-ip=10.0.0.1->10.1.1.1")
+  n6[("network-instance vrf1")]
+  n7["ipv4 10.1.0.0/16 [vrf1]"]
+  n8["nhg 3 [vrf1]: DANGLING, not found"]
+  n9["nhg 2 [DEFAULT]: DANGLING, not found"]
+  n10["ipv4 10.2.0.0/24 [vrf2]"]
+  n3 --> n4
+  n2 -->|"weight 1"| n3
+  n5 -->|"lookup 10.1.1.1"| n6
+  n7 --> n8
+  n6 -->|"10.1.1.1"| n7
+  n2 -->|"weight 3"| n5
+  n2 -.->|"backup"| n9
+  n1 --> n2
+  n10 --> n2
+  style n8 stroke:red
+  style n9 stroke:red
+`
+	if got := testGraph(t).mermaid("r1"); got != want {
+		t.Errorf("mermaid() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGraphQuoting(t *testing.T) {
+	if got, want := dotQuote(`nh "a" \ b`), `"nh \"a\" \\ b"`; got != want {
+		t.Errorf("dotQuote() = %s, want %s", got, want)
+	}
+	if got, want := mermaidEscape(`metadata="md"`), "metadata=#quot;md#quot;"; got != want {
+		t.Errorf("mermaidEscape() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Get output formats
+const (
+	getFormatText = "text"
+	// one JSON object per line, with the target name and the entry
+	getFormatNDJSON = "ndjson"
+	// one protojson encoded entry per line
+	getFormatProtoJSON = "protojson"
+	// length-delimited binary encoded entries
+	getFormatProto = "proto"
+)
+
+// getOutput writes the Get entries as they are received,
+// without holding them in memory.
+type getOutput struct {
+	m      *sync.Mutex
+	format string
+	path   string
+	// more than one target, the proto format uses one file per target.
+	multi bool
+	// shared output, for the text based formats
+	w *bufio.Writer
+	// per target outputs, for the proto format
+	tw      map[string]*bufio.Writer
+	closers []io.Closer
+}
+
+func newGetOutput(format, path string, numTargets int) (*getOutput, error) {
+	o := &getOutput{
+		m:      new(sync.Mutex),
+		format: format,
+		path:   path,
+		multi:  numTargets > 1,
+		tw:     make(map[string]*bufio.Writer),
+	}
+	switch format {
+	case getFormatNDJSON, getFormatProtoJSON:
+		if path == "" {
+			o.w = bufio.NewWriter(os.Stdout)
+			return o, nil
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		o.closers = append(o.closers, f)
+		o.w = bufio.NewWriter(f)
+	case getFormatProto:
+		if path == "" {
+			return nil, fmt.Errorf("format %q requires an --output file", format)
+		}
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+	return o, nil
+}
+
+// targetPath returns the proto output file of the target.
+func (o *getOutput) targetPath(target string) string {
+	return targetOutputPath(o.path, target, o.multi)
+}
+
+// targetOutputPath returns the output file of a target,
+// the target name is inserted before the file extension if there are multiple targets.
+func targetOutputPath(path, target string, multi bool) string {
+	if !multi {
+		return path
+	}
+	ext := filepath.Ext(path)
+	name := strings.NewReplacer("/", "_", ":", "_").Replace(target)
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(path, ext), name, ext)
+}
+
+func (o *getOutput) write(target string, e *spb.AFTEntry) error {
+	o.m.Lock()
+	defer o.m.Unlock()
+	switch o.format {
+	case getFormatNDJSON:
+		b, err := json.Marshal(struct {
+			Target string          `json:"target,omitempty"`
+			Entry  json.RawMessage `json:"entry,omitempty"`
+		}{
+			Target: target,
+			Entry:  json.RawMessage(protojson.Format(e)),
+		})
+		if err != nil {
+			return err
+		}
+		b = append(b, '\n')
+		_, err = o.w.Write(b)
+		return err
+	case getFormatProtoJSON:
+		b, err := protojson.Marshal(e)
+		if err != nil {
+			return err
+		}
+		// protojson output is not stable, compact it to one line.
+		buf := new(bytes.Buffer)
+		err = json.Compact(buf, b)
+		if err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		_, err = o.w.Write(buf.Bytes())
+		return err
+	case getFormatProto:
+		w, ok := o.tw[target]
+		if !ok {
+			f, err := os.Create(o.targetPath(target))
+			if err != nil {
+				return err
+			}
+			o.closers = append(o.closers, f)
+			w = bufio.NewWriter(f)
+			o.tw[target] = w
+		}
+		_, err := protodelim.MarshalTo(w, e)
+		return err
+	}
+	return nil
+}
+
+func (o *getOutput) close() error {
+	o.m.Lock()
+	defer o.m.Unlock()
+	var errs []string
+	if o.w != nil {
+		if err := o.w.Flush(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	for _, w := range o.tw {
+		if err := w.Flush(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	for _, c := range o.closers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to close get output: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+func TestTargetOutputPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		target string
+		multi  bool
+		want   string
+	}{
+		{path: "out.pb", target: "r1", want: "out.pb"},
+		{path: "out.pb", target: "r1", multi: true, want: "out.r1.pb"},
+		{path: "dir/out", target: "10.0.0.1:57400", multi: true, want: "dir/out.10.0.0.1_57400"},
+		{path: "out.pb", target: "unix:///tmp/g.sock", multi: true, want: "out.unix____tmp_g.sock.pb"},
+	}
+	for _, tt := range tests {
+		if got := targetOutputPath(tt.path, tt.target, tt.multi); got != tt.want {
+			t.Errorf("targetOutputPath(%q, %q, %v) = %q, want %q", tt.path, tt.target, tt.multi, got, tt.want)
+		}
+	}
+}
+
+func TestNewGetOutput_errors(t *testing.T) {
+	if _, err := newGetOutput("yaml", "", 1); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+	if _, err := newGetOutput(getFormatProto, "", 1); err == nil {
+		t.Errorf("expected an error for the proto format without an output file")
+	}
+}
+
+func TestGetOutput_write(t *testing.T) {
+	entries := map[string][]*spb.AFTEntry{
+		"r1": {
+			ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1),
+			nhgEntry(t, "DEFAULT", 1, 1, 2),
+		},
+		"r2": {
+			nhEntry(t, "DEFAULT", 1, "192.0.2.1"),
+		},
+	}
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		format  string
+		targets []string
+		// reads the entries of a target from the written files
+		read func(t *testing.T, path, target string, multi bool) aftSnapshot
+	}{
+		{
+			name:    "ndjson",
+			format:  getFormatNDJSON,
+			targets: []string{"r1"},
+			read: func(t *testing.T, path, _ string, _ bool) aftSnapshot {
+				return readTestFile(t, path, readJSONEntries)
+			},
+		},
+		{
+			name:    "protojson",
+			format:  getFormatProtoJSON,
+			targets: []string{"r1"},
+			read: func(t *testing.T, path, _ string, _ bool) aftSnapshot {
+				return readTestFile(t, path, readJSONEntries)
+			},
+		},
+		{
+			name:    "proto_per_target",
+			format:  getFormatProto,
+			targets: []string{"r1", "r2"},
+			read: func(t *testing.T, path, target string, multi bool) aftSnapshot {
+				return readTestFile(t, targetOutputPath(path, target, multi), readProtoEntries)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".out")
+			o, err := newGetOutput(tt.format, path, len(tt.targets))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, target := range tt.targets {
+				for _, e := range entries[target] {
+					if err = o.write(target, e); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if err = o.close(); err != nil {
+				t.Fatal(err)
+			}
+			for _, target := range tt.targets {
+				got := sortedKeys(tt.read(t, path, target, len(tt.targets) > 1))
+				want := sortedKeys(newAFTSnapshot(entries[target]...))
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("target %s: read entries %v, want %v", target, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetOutput_ndjson_targets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.json")
+	o, err := newGetOutput(getFormatNDJSON, path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = o.write("r1", nhEntry(t, "DEFAULT", 1, "192.0.2.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err = o.write("r2", nhEntry(t, "DEFAULT", 1, "192.0.2.2")); err != nil {
+		t.Fatal(err)
+	}
+	if err = o.close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], `{"target":"r1","entry":{`) || !strings.HasPrefix(lines[1], `{"target":"r2","entry":{`) {
+		t.Errorf("unexpected ndjson output:\n%s", b)
+	}
+	if _, err = readJSONEntries(b); err == nil {
+		t.Errorf("expected an error reading the entries of 2 targets as a snapshot")
+	}
+}
+
+func readTestFile(t *testing.T, path string, read func([]byte) (aftSnapshot, error)) aftSnapshot {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+// aftTypes lists the AFT types in the summary columns order.
+var aftTypes = []string{aftTypeIPv4, aftTypeIPv6, aftTypeNH, aftTypeNHG, aftTypeMPLS, aftTypeMAC, aftTypePF}
+
+// aftRef identifies a NH or NHG within a network instance.
+type aftRef struct {
+	ni string
+	id uint64
+}
+
+// getSummary aggregates Get entries statistics,
+// it keeps counters and NH/NHG keys only, not the entries.
+type getSummary struct {
+	// network instance -> AFT type -> FIB status -> count
+	counts map[string]map[string]map[spb.AFTEntry_Status]int
+	// number of next hops -> number of NHGs
+	nhgSizes map[int]int
+	// defined and referenced NHs and NHGs
+	nhs     map[aftRef]struct{}
+	nhgs    map[aftRef]struct{}
+	refNHs  map[aftRef]struct{}
+	refNHGs map[aftRef]struct{}
+}
+
+func newGetSummary() *getSummary {
+	return &getSummary{
+		counts:   make(map[string]map[string]map[spb.AFTEntry_Status]int),
+		nhgSizes: make(map[int]int),
+		nhs:      make(map[aftRef]struct{}),
+		nhgs:     make(map[aftRef]struct{}),
+		refNHs:   make(map[aftRef]struct{}),
+		refNHGs:  make(map[aftRef]struct{}),
+	}
+}
+
+func (s *getSummary) add(e *spb.AFTEntry) {
+	ni := e.GetNetworkInstance()
+	typ := aftEntryType(e)
+	if s.counts[ni] == nil {
+		s.counts[ni] = make(map[string]map[spb.AFTEntry_Status]int)
+	}
+	if s.counts[ni][typ] == nil {
+		s.counts[ni][typ] = make(map[spb.AFTEntry_Status]int)
+	}
+	s.counts[ni][typ][e.GetFibStatus()]++
+
+	switch e.GetEntry().(type) {
+	case *spb.AFTEntry_Ipv4, *spb.AFTEntry_Ipv6, *spb.AFTEntry_Mpls:
+		nhg, ok := aftEntryNHG(e)
+		if !ok {
+			return
+		}
+		nhgNI := ni
+		if n := aftEntryNHGNetworkInstance(e); n != "" {
+			nhgNI = n
+		}
+		s.refNHGs[aftRef{ni: nhgNI, id: nhg}] = struct{}{}
+	case *spb.AFTEntry_NextHopGroup:
+		s.nhgs[aftRef{ni: ni, id: e.GetNextHopGroup().GetId()}] = struct{}{}
+		nhs := nhgNextHops(e)
+		s.nhgSizes[len(nhs)]++
+		for _, idx := range nhs {
+			s.refNHs[aftRef{ni: ni, id: idx}] = struct{}{}
+		}
+		if b := e.GetNextHopGroup().GetNextHopGroup().GetBackupNextHopGroup(); b != nil {
+			s.refNHGs[aftRef{ni: ni, id: b.GetValue()}] = struct{}{}
+		}
+	case *spb.AFTEntry_NextHop:
+		s.nhs[aftRef{ni: ni, id: e.GetNextHop().GetIndex()}] = struct{}{}
+	}
+}
+
+// unreferenced returns the number of defined keys
+// that are not in the referenced keys.
+func unreferenced(defined, referenced map[aftRef]struct{}) int {
+	var n int
+	for k := range defined {
+		if _, ok := referenced[k]; !ok {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *getSummary) String() string {
+	sb := new(strings.Builder)
+	tw := tabwriter.NewWriter(sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "NETWORK-INSTANCE\tFIB-STATUS\t%s\tTOTAL\n", strings.ToUpper(strings.Join(aftTypes, "\t")))
+	nis := make([]string, 0, len(s.counts))
+	for ni := range s.counts {
+		nis = append(nis, ni)
+	}
+	sort.Strings(nis)
+	totals := make(map[string]int)
+	for _, ni := range nis {
+		for _, st := range s.statuses(ni) {
+			fmt.Fprintf(tw, "%s\t%s", ni, st)
+			var total int
+			for _, typ := range aftTypes {
+				n := s.counts[ni][typ][st]
+				total += n
+				totals[typ] += n
+				fmt.Fprintf(tw, "\t%d", n)
+			}
+			fmt.Fprintf(tw, "\t%d\n", total)
+		}
+	}
+	fmt.Fprintf(tw, "TOTAL\t")
+	var total int
+	for _, typ := range aftTypes {
+		total += totals[typ]
+		fmt.Fprintf(tw, "\t%d", totals[typ])
+	}
+	fmt.Fprintf(tw, "\t%d\n", total)
+	tw.Flush()
+
+	if len(s.nhgSizes) > 0 {
+		sb.WriteString("\nNHG size distribution:\n")
+		tw = tabwriter.NewWriter(sb, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "NEXT-HOPS\tNHGS\n")
+		sizes := make([]int, 0, len(s.nhgSizes))
+		for size := range s.nhgSizes {
+			sizes = append(sizes, size)
+		}
+		sort.Ints(sizes)
+		for _, size := range sizes {
+			fmt.Fprintf(tw, "%d\t%d\n", size, s.nhgSizes[size])
+		}
+		tw.Flush()
+	}
+	fmt.Fprintf(sb, "\nunreferenced NHs: %d\n", unreferenced(s.nhs, s.refNHs))
+	fmt.Fprintf(sb, "unreferenced NHGs: %d\n", unreferenced(s.nhgs, s.refNHGs))
+	return sb.String()
+}
+
+// statuses returns the FIB statuses seen in the network instance ni, sorted.
+func (s *getSummary) statuses(ni string) []spb.AFTEntry_Status {
+	seen := make(map[spb.AFTEntry_Status]struct{})
+	for _, st := range s.counts[ni] {
+		for k := range st {
+			seen[k] = struct{}{}
+		}
+	}
+	sts := make([]spb.AFTEntry_Status, 0, len(seen))
+	for st := range seen {
+		sts = append(sts, st)
+	}
+	sort.Slice(sts, func(i, j int) bool { return sts[i] < sts[j] })
+	return sts
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"testing"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+func TestGetSummary(t *testing.T) {
+	entries := []*spb.AFTEntry{
+		testEntry(t, `network_instance: "DEFAULT" fib_status: PROGRAMMED ipv4: {prefix: "10.0.0.0/24" ipv4_entry: {next_hop_group: {value: 1}}}`),
+		ipv4Entry(t, "DEFAULT", "10.0.1.0/24", 1),
+		ipv6Entry(t, "DEFAULT", "2001:db8::/64", 2),
+		testEntry(t, `network_instance: "DEFAULT" next_hop_group: {id: 1 next_hop_group: {
+			next_hop: {index: 1} next_hop: {index: 2} backup_next_hop_group: {value: 3}}}`),
+		nhgEntry(t, "DEFAULT", 2, 3),
+		nhgEntry(t, "DEFAULT", 3),
+		// unreferenced
+		nhgEntry(t, "DEFAULT", 4, 1),
+		nhEntry(t, "DEFAULT", 1, "192.0.2.1"),
+		nhEntry(t, "DEFAULT", 2, "192.0.2.2"),
+		nhEntry(t, "DEFAULT", 3, "192.0.2.3"),
+		// unreferenced
+		nhEntry(t, "DEFAULT", 4, "192.0.2.4"),
+		// references NHG 2 of the DEFAULT network instance
+		testEntry(t, `network_instance: "vrf1" fib_status: NOT_PROGRAMMED ipv4: {prefix: "10.9.0.0/16" ipv4_entry: {
+			next_hop_group: {value: 2} next_hop_group_network_instance: {value: "DEFAULT"}}}`),
+		// unreferenced, the NHG 1 of vrf1 does not exist
+		nhEntry(t, "vrf1", 1, "192.0.2.1"),
+		testEntry(t, `network_instance: "vrf1" mpls: {label_uint64: 100 label_entry: {next_hop_group: {value: 1}}}`),
+	}
+	s := newGetSummary()
+	for _, e := range entries {
+		s.add(e)
+	}
+	if n := unreferenced(s.nhs, s.refNHs); n != 2 {
+		t.Errorf("unreferenced NHs = %d, want 2", n)
+	}
+	if n := unreferenced(s.nhgs, s.refNHGs); n != 1 {
+		t.Errorf("unreferenced NHGs = %d, want 1", n)
+	}
+	want := `NETWORK-INSTANCE  FIB-STATUS      IPV4  IPV6  NH  NHG  MPLS  MAC  PF  TOTAL
+DEFAULT           UNAVAILABLE     1     1     4   4    0     0    0   10
+DEFAULT           PROGRAMMED      1     0     0   0    0     0    0   1
+vrf1              UNAVAILABLE     0     0     1   0    1     0    0   2
+vrf1              NOT_PROGRAMMED  1     0     0   0    0     0    0   1
+TOTAL                             3     1     5   4    1     0    0   14
+
+NHG size distribution:
+NEXT-HOPS  NHGS
+0          1
+1          2
+2          1
+
+unreferenced NHs: 2
+unreferenced NHGs: 1
+`
+	if got := s.String(); got != want {
+		t.Errorf("String() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGetSummary_empty(t *testing.T) {
+	want := `NETWORK-INSTANCE  FIB-STATUS  IPV4  IPV6  NH  NHG  MPLS  MAC  PF  TOTAL
+TOTAL                         0     0     0   0    0     0    0   0
+
+unreferenced NHs: 0
+unreferenced NHGs: 0
+`
+	if got := newGetSummary().String(); got != want {
+		t.Errorf("String() =\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,369 @@
+package app
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/openconfig/gribi/v1/proto/gribi_aft"
+	"github.com/openconfig/gribi/v1/proto/gribi_aft/enums"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+// maxChainDepth bounds the resolution of recursive chains
+const maxChainDepth = 16
+
+// aftIndex indexes the AFT entries of a target by network instance,
+// it is used to resolve forwarding chains.
+type aftIndex struct {
+	nis map[string]*niAFTs
+	// all the entries, in the order they were added
+	all []*spb.AFTEntry
+}
+
+type niAFTs struct {
+	ip  map[netip.Prefix]*spb.AFTEntry
+	nhg map[uint64]*spb.AFTEntry
+	nh  map[uint64]*spb.AFTEntry
+}
+
+func newAFTIndex() *aftIndex {
+	return &aftIndex{nis: make(map[string]*niAFTs)}
+}
+
+func (x *aftIndex) ni(name string) *niAFTs {
+	n, ok := x.nis[name]
+	if !ok {
+		n = &niAFTs{
+			ip:  make(map[netip.Prefix]*spb.AFTEntry),
+			nhg: make(map[uint64]*spb.AFTEntry),
+			nh:  make(map[uint64]*spb.AFTEntry),
+		}
+		x.nis[name] = n
+	}
+	return n
+}
+
+func (x *aftIndex) add(entries ...*spb.AFTEntry) {
+	x.all = append(x.all, entries...)
+	for _, e := range entries {
+		n := x.ni(e.GetNetworkInstance())
+		switch e.GetEntry().(type) {
+		case *spb.AFTEntry_Ipv4, *spb.AFTEntry_Ipv6:
+			if p, ok := entryPrefix(e); ok {
+				n.ip[p] = e
+			}
+		case *spb.AFTEntry_NextHopGroup:
+			n.nhg[e.GetNextHopGroup().GetId()] = e
+		case *spb.AFTEntry_NextHop:
+			n.nh[e.GetNextHop().GetIndex()] = e
+		}
+	}
+}
+
+// entries returns all the indexed entries, including the ones
+// not used to resolve chains, such as MPLS entries.
+func (x *aftIndex) entries() []*spb.AFTEntry {
+	return x.all
+}
+
+func (x *aftIndex) nhg(ni string, id uint64) (*spb.AFTEntry, bool) {
+	n, ok := x.nis[ni]
+	if !ok {
+		return nil, false
+	}
+	e, ok := n.nhg[id]
+	return e, ok
+}
+
+func (x *aftIndex) nh(ni string, index uint64) (*spb.AFTEntry, bool) {
+	n, ok := x.nis[ni]
+	if !ok {
+		return nil, false
+	}
+	e, ok := n.nh[index]
+	return e, ok
+}
+
+// lookup returns the IPv4 or IPv6 entry of the network instance ni
+// with the longest prefix containing addr.
+func (x *aftIndex) lookup(ni string, addr netip.Addr) *spb.AFTEntry {
+	n, ok := x.nis[ni]
+	if !ok {
+		return nil
+	}
+	for bits := addr.BitLen(); bits >= 0; bits-- {
+		p, err := addr.Prefix(bits)
+		if err != nil {
+			return nil
+		}
+		if e, ok := n.ip[p]; ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// resolve returns the entry for s in the network instance ni,
+// s is either a prefix, looked up as is then by longest prefix match,
+// or an address, looked up by longest prefix match.
+func (x *aftIndex) resolve(ni, s string) (*spb.AFTEntry, netip.Addr, error) {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		p = p.Masked()
+		if n, ok := x.nis[ni]; ok {
+			if e, ok := n.ip[p]; ok {
+				return e, p.Addr(), nil
+			}
+		}
+		return x.lookup(ni, p.Addr()), p.Addr(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return nil, netip.Addr{}, fmt.Errorf("invalid prefix or address %q", s)
+	}
+	return x.lookup(ni, addr), addr, nil
+}
+
+// chainNode is a node of a forwarding chain tree.
+type chainNode struct {
+	label    string
+	children []*chainNode
+}
+
+func (n *chainNode) add(label string) *chainNode {
+	c := &chainNode{label: label}
+	n.children = append(n.children, c)
+	return c
+}
+
+func (n *chainNode) String() string {
+	sb := new(strings.Builder)
+	sb.WriteString(n.label)
+	sb.WriteString("\n")
+	n.render(sb, "")
+	return sb.String()
+}
+
+func (n *chainNode) render(sb *strings.Builder, indent string) {
+	for i, c := range n.children {
+		branch, next := "├── ", "│   "
+		if i == len(n.children)-1 {
+			branch, next = "└── ", "    "
+		}
+		sb.WriteString(indent)
+		sb.WriteString(branch)
+		sb.WriteString(c.label)
+		sb.WriteString("\n")
+		c.render(sb, indent+next)
+	}
+}
+
+// chainResolver builds the forwarding chain of entries using an aftIndex.
+type chainResolver struct {
+	idx *aftIndex
+	// destination address, used to resolve next hops pointing
+	// to another network instance.
+	dst netip.Addr
+	// entries in the current chain, used to detect loops
+	path map[string]bool
+}
+
+func newChainResolver(idx *aftIndex, dst netip.Addr) *chainResolver {
+	return &chainResolver{
+		idx:  idx,
+		dst:  dst,
+		path: make(map[string]bool),
+	}
+}
+
+// tree returns the forwarding chain of the entry e.
+func (r *chainResolver) tree(e *spb.AFTEntry) *chainNode {
+	root := &chainNode{label: entryLabel(e)}
+	r.entry(root, e, 0)
+	return root
+}
+
+func (r *chainResolver) entry(n *chainNode, e *spb.AFTEntry, depth int) {
+	k := aftEntryKey(e)
+	if r.path[k] {
+		n.add("LOOP: " + k + " already in the chain")
+		return
+	}
+	if depth > maxChainDepth {
+		n.add(fmt.Sprintf("TRUNCATED: chain deeper than %d", maxChainDepth))
+		return
+	}
+	r.path[k] = true
+	defer delete(r.path, k)
+
+	ni := e.GetNetworkInstance()
+	switch e.GetEntry().(type) {
+	case *spb.AFTEntry_Ipv4, *spb.AFTEntry_Ipv6, *spb.AFTEntry_Mpls:
+		nhg, ok := aftEntryNHG(e)
+		if !ok {
+			n.add("no next hop group")
+			return
+		}
+		if nhgNI := aftEntryNHGNetworkInstance(e); nhgNI != "" {
+			ni = nhgNI
+		}
+		r.nhg(n, ni, nhg, "", depth)
+	case *spb.AFTEntry_NextHopGroup:
+		r.nhgMembers(n, e, depth)
+	case *spb.AFTEntry_NextHop:
+		r.nhResolution(n, e, depth)
+	}
+}
+
+func (r *chainResolver) nhg(n *chainNode, ni string, id uint64, role string, depth int) {
+	e, ok := r.idx.nhg(ni, id)
+	if !ok {
+		n.add(fmt.Sprintf("%snhg %d [%s]: DANGLING, not found", role, id, ni))
+		return
+	}
+	c := n.add(role + entryLabel(e))
+	r.entry(c, e, depth+1)
+}
+
+func (r *chainResolver) nhgMembers(n *chainNode, e *spb.AFTEntry, depth int) {
+	ni := e.GetNetworkInstance()
+	nhg := e.GetNextHopGroup().GetNextHopGroup()
+	if len(nhg.GetNextHop()) == 0 {
+		n.add("no next hops")
+	}
+	for _, m := range nhg.GetNextHop() {
+		role := "weight " + fmt.Sprint(m.GetNextHop().GetWeight().GetValue()) + ": "
+		nh, ok := r.idx.nh(ni, m.GetIndex())
+		if !ok {
+			n.add(fmt.Sprintf("%snh %d [%s]: DANGLING, not found", role, m.GetIndex(), ni))
+			continue
+		}
+		c := n.add(role + entryLabel(nh))
+		r.entry(c, nh, depth+1)
+	}
+	if b := nhg.GetBackupNextHopGroup(); b != nil {
+		r.nhg(n, ni, b.GetValue(), "backup: ", depth)
+	}
+}
+
+// nhResolution follows a next hop pointing to another network instance,
+// looking up the tunnel destination if the next hop encapsulates,
+// or the chain destination otherwise.
+func (r *chainResolver) nhResolution(n *chainNode, e *spb.AFTEntry, depth int) {
+	nh := e.GetNextHop().GetNextHop()
+	ni := nh.GetNetworkInstance().GetValue()
+	if ni == "" {
+		return
+	}
+	dst := r.dst
+	if d := nh.GetIpInIp().GetDstIp().GetValue(); d != "" {
+		if addr, err := netip.ParseAddr(d); err == nil {
+			dst = addr
+		}
+	}
+	if !dst.IsValid() {
+		n.add(fmt.Sprintf("lookup in [%s]: no destination address", ni))
+		return
+	}
+	c := n.add(fmt.Sprintf("lookup %s in [%s]", dst, ni))
+	if _, ok := r.idx.nis[ni]; !ok {
+		c.add(fmt.Sprintf("DANGLING: network instance %q not found", ni))
+		return
+	}
+	ip := r.idx.lookup(ni, dst)
+	if ip == nil {
+		c.add("DANGLING: no matching route")
+		return
+	}
+	cc := c.add(entryLabel(ip))
+	r.entry(cc, ip, depth+1)
+}
+
+// entryLabel returns a one line description of an entry.
+func entryLabel(e *spb.AFTEntry) string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "%s %s [%s]", aftEntryType(e), aftEntryID(e), e.GetNetworkInstance())
+	attrs := make([]string, 0)
+	switch ee := e.GetEntry().(type) {
+	case *spb.AFTEntry_Ipv4, *spb.AFTEntry_Ipv6, *spb.AFTEntry_Mpls:
+		if md := aftEntryMetadata(e); len(md) > 0 {
+			attrs = append(attrs, fmt.Sprintf("metadata=%q", md))
+		}
+	case *spb.AFTEntry_NextHopGroup:
+		if c := ee.NextHopGroup.GetNextHopGroup().GetColor(); c != nil {
+			attrs = append(attrs, fmt.Sprintf("color=%d", c.GetValue()))
+		}
+	case *spb.AFTEntry_NextHop:
+		attrs = append(attrs, nhAttributes(ee.NextHop.GetNextHop())...)
+	}
+	if e.GetFibStatus() != spb.AFTEntry_UNAVAILABLE {
+		attrs = append(attrs, "fib="+e.GetFibStatus().String())
+	}
+	if len(attrs) > 0 {
+		fmt.Fprintf(sb, ": %s", strings.Join(attrs, ", "))
+	}
+	return sb.String()
+}
+
+func nhAttributes(nh *gribi_aft.Afts_NextHop) []string {
+	attrs := make([]string, 0)
+	if v := nh.GetIpAddress(); v != nil {
+		attrs = append(attrs, "ip="+v.GetValue())
+	}
+	if ifr := nh.GetInterfaceRef(); ifr != nil {
+		itf := ifr.GetInterface().GetValue()
+		if sub := ifr.GetSubinterface(); sub != nil {
+			itf = fmt.Sprintf("%s.%d", itf, sub.GetValue())
+		}
+		attrs = append(attrs, "interface="+itf)
+	}
+	if v := nh.GetMacAddress(); v != nil {
+		attrs = append(attrs, "mac="+v.GetValue())
+	}
+	if v := nh.GetNetworkInstance(); v != nil {
+		attrs = append(attrs, "network-instance="+v.GetValue())
+	}
+	if h := nh.GetEncapsulateHeader(); h != enums.OpenconfigAftTypesEncapsulationHeaderType_OPENCONFIGAFTTYPESENCAPSULATIONHEADERTYPE_UNSET {
+		attrs = append(attrs, "encap="+encapHeaderName(h))
+	}
+	if h := nh.GetDecapsulateHeader(); h != enums.OpenconfigAftTypesEncapsulationHeaderType_OPENCONFIGAFTTYPESENCAPSULATIONHEADERTYPE_UNSET {
+		attrs = append(attrs, "decap="+encapHeaderName(h))
+	}
+	if ipip := nh.GetIpInIp(); ipip != nil {
+		attrs = append(attrs, fmt.Sprintf("ip-in-ip=%s->%s", ipip.GetSrcIp().GetValue(), ipip.GetDstIp().GetValue()))
+	}
+	if stack := nh.GetPushedMplsLabelStack(); len(stack) > 0 {
+		labels := make([]string, 0, len(stack))
+		for _, l := range stack {
+			if l.GetPushedMplsLabelStackOpenconfigmplstypesmplslabelenum() != enums.OpenconfigMplsTypesMplsLabelEnum_OPENCONFIGMPLSTYPESMPLSLABELENUM_UNSET {
+				labels = append(labels, l.GetPushedMplsLabelStackOpenconfigmplstypesmplslabelenum().String())
+				continue
+			}
+			labels = append(labels, fmt.Sprint(l.GetPushedMplsLabelStackUint64()))
+		}
+		attrs = append(attrs, "push-labels=["+strings.Join(labels, " ")+"]")
+	}
+	return attrs
+}
+
+func encapHeaderName(h enums.OpenconfigAftTypesEncapsulationHeaderType) string {
+	return strings.TrimPrefix(h.String(), "OPENCONFIGAFTTYPESENCAPSULATIONHEADERTYPE_")
+}
+
+// sortedTreeRoots returns the entries to display as chain roots,
+// sorted by network instance, AFT type and key.
+func sortedTreeRoots(entries []*spb.AFTEntry) []*spb.AFTEntry {
+	roots := make([]*spb.AFTEntry, len(entries))
+	copy(roots, entries)
+	sort.SliceStable(roots, func(i, j int) bool {
+		if roots[i].GetNetworkInstance() != roots[j].GetNetworkInstance() {
+			return roots[i].GetNetworkInstance() < roots[j].GetNetworkInstance()
+		}
+		if aftEntryType(roots[i]) != aftEntryType(roots[j]) {
+			return aftEntryType(roots[i]) < aftEntryType(roots[j])
+		}
+		return aftEntryID(roots[i]) < aftEntryID(roots[j])
+	})
+	return roots
+}
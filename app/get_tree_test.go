@@ -0,0 +1,174 @@
+package app
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+func TestAFTIndex_resolve(t *testing.T) {
+	idx := newAFTIndex()
+	idx.add(
+		ipv4Entry(t, "DEFAULT", "0.0.0.0/0", 1),
+		ipv4Entry(t, "DEFAULT", "10.0.0.0/8", 1),
+		ipv4Entry(t, "DEFAULT", "10.1.0.0/16", 1),
+		ipv4Entry(t, "vrf1", "10.1.1.0/24", 1),
+		ipv6Entry(t, "DEFAULT", "2001:db8::/32", 1),
+	)
+	tests := []struct {
+		name     string
+		ni       string
+		s        string
+		want     string
+		wantAddr string
+		wantErr  bool
+	}{
+		{name: "address", ni: "DEFAULT", s: "10.1.1.1", want: "[DEFAULT] ipv4 10.1.0.0/16", wantAddr: "10.1.1.1"},
+		{name: "exact_prefix", ni: "DEFAULT", s: "10.0.0.0/8", want: "[DEFAULT] ipv4 10.0.0.0/8", wantAddr: "10.0.0.0"},
+		{name: "prefix_lpm", ni: "DEFAULT", s: "10.1.2.0/24", want: "[DEFAULT] ipv4 10.1.0.0/16", wantAddr: "10.1.2.0"},
+		{name: "unmasked_prefix", ni: "DEFAULT", s: "10.1.7.7/16", want: "[DEFAULT] ipv4 10.1.0.0/16", wantAddr: "10.1.0.0"},
+		{name: "default_route", ni: "DEFAULT", s: "192.0.2.1", want: "[DEFAULT] ipv4 0.0.0.0/0", wantAddr: "192.0.2.1"},
+		{name: "other_network_instance", ni: "vrf1", s: "10.1.1.1", want: "[vrf1] ipv4 10.1.1.0/24", wantAddr: "10.1.1.1"},
+		{name: "no_match", ni: "vrf1", s: "10.2.0.1", wantAddr: "10.2.0.1"},
+		{name: "unknown_network_instance", ni: "vrf2", s: "10.1.1.1", wantAddr: "10.1.1.1"},
+		{name: "ipv6", ni: "DEFAULT", s: "2001:db8::1", want: "[DEFAULT] ipv6 2001:db8::/32", wantAddr: "2001:db8::1"},
+		{name: "invalid", ni: "DEFAULT", s: "not-an-address", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, addr, err := idx.resolve(tt.ni, tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var got string
+			if e != nil {
+				got = aftEntryKey(e)
+			}
+			if got != tt.want {
+				t.Errorf("resolve() entry = %q, want %q", got, tt.want)
+			}
+			if addr.String() != tt.wantAddr {
+				t.Errorf("resolve() address = %s, want %s", addr, tt.wantAddr)
+			}
+		})
+	}
+}
+
+func TestChainResolver_tree(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []*spb.AFTEntry
+		root    string
+		dst     string
+		want    string
+	}{
+		{
+			name: "nhg_nh_backup_and_recursion",
+			entries: []*spb.AFTEntry{
+				ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1),
+				testEntry(t, `network_instance: "DEFAULT" next_hop_group: {id: 1 next_hop_group: {
+					next_hop: {index: 1 next_hop: {weight: {value: 1}}}
+					next_hop: {index: 2 next_hop: {weight: {value: 3}}}
+					backup_next_hop_group: {value: 2}}}`),
+				nhEntry(t, "DEFAULT", 1, "192.0.2.1"),
+				testEntry(t, `network_instance: "DEFAULT" next_hop: {index: 2 next_hop: {
+					network_instance: {value: "vrf1"}
+					ip_in_ip: {src_ip: {value: "192.0.2.254"} dst_ip: {value: "10.9.9.9"}}}}`),
+				nhgEntry(t, "DEFAULT", 2, 3),
+				testEntry(t, `network_instance: "vrf1" ipv4: {prefix: "10.9.0.0/16" ipv4_entry: {
+					next_hop_group: {value: 5} next_hop_group_network_instance: {value: "DEFAULT"}}}`),
+			},
+			root: "10.0.0.0/24",
+			dst:  "10.0.0.1",
+			want: `ipv4 10.0.0.0/24 [DEFAULT]
+└── nhg 1 [DEFAULT]
+    ├── weight 1: nh 1 [DEFAULT]: ip=192.0.2.1
+    ├── weight 3: nh 2 [DEFAULT]: network-instance=vrf1, ip-in-ip=192.0.2.254->10.9.9.9
+    │   └── lookup 10.9.9.9 in [vrf1]
+    │       └── ipv4 10.9.0.0/16 [vrf1]
+    │           └── nhg 5 [DEFAULT]: DANGLING, not found
+    └── backup: nhg 2 [DEFAULT]
+        └── weight 1: nh 3 [DEFAULT]: DANGLING, not found
+`,
+		},
+		{
+			name: "loop",
+			entries: []*spb.AFTEntry{
+				ipv4Entry(t, "DEFAULT", "10.0.0.0/8", 10),
+				nhgEntry(t, "DEFAULT", 10, 10),
+				testEntry(t, `network_instance: "DEFAULT" next_hop: {index: 10 next_hop: {network_instance: {value: "DEFAULT"}}}`),
+			},
+			root: "10.0.0.0/8",
+			dst:  "10.1.1.1",
+			want: `ipv4 10.0.0.0/8 [DEFAULT]
+└── nhg 10 [DEFAULT]
+    └── weight 1: nh 10 [DEFAULT]: network-instance=DEFAULT
+        └── lookup 10.1.1.1 in [DEFAULT]
+            └── ipv4 10.0.0.0/8 [DEFAULT]
+                └── LOOP: [DEFAULT] ipv4 10.0.0.0/8 already in the chain
+`,
+		},
+		{
+			name: "missing_network_instance_and_route",
+			entries: []*spb.AFTEntry{
+				testEntry(t, `network_instance: "DEFAULT" fib_status: PROGRAMMED ipv4: {prefix: "10.0.0.0/8" ipv4_entry: {next_hop_group: {value: 1}}}`),
+				nhgEntry(t, "DEFAULT", 1, 1, 2),
+				testEntry(t, `network_instance: "DEFAULT" next_hop: {index: 1 next_hop: {network_instance: {value: "vrf9"}}}`),
+				testEntry(t, `network_instance: "DEFAULT" next_hop: {index: 2 next_hop: {network_instance: {value: "vrf1"}}}`),
+				ipv4Entry(t, "vrf1", "192.0.2.0/24", 1),
+			},
+			root: "10.0.0.0/8",
+			dst:  "10.1.1.1",
+			want: `ipv4 10.0.0.0/8 [DEFAULT]: fib=PROGRAMMED
+└── nhg 1 [DEFAULT]
+    ├── weight 1: nh 1 [DEFAULT]: network-instance=vrf9
+    │   └── lookup 10.1.1.1 in [vrf9]
+    │       └── DANGLING: network instance "vrf9" not found
+    └── weight 1: nh 2 [DEFAULT]: network-instance=vrf1
+        └── lookup 10.1.1.1 in [vrf1]
+            └── DANGLING: no matching route
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := newAFTIndex()
+			idx.add(tt.entries...)
+			e, _, err := idx.resolve("DEFAULT", tt.root)
+			if err != nil || e == nil {
+				t.Fatalf("resolve(%s) = %v, %v", tt.root, e, err)
+			}
+			got := newChainResolver(idx, netip.MustParseAddr(tt.dst)).tree(e).String()
+			if got != tt.want {
+				t.Errorf("tree() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortedTreeRoots(t *testing.T) {
+	entries := []*spb.AFTEntry{
+		nhEntry(t, "vrf1", 1, "192.0.2.1"),
+		ipv4Entry(t, "DEFAULT", "10.1.0.0/16", 1),
+		nhgEntry(t, "DEFAULT", 1, 1),
+		ipv4Entry(t, "DEFAULT", "10.0.0.0/16", 1),
+	}
+	got := entryKeys(sortedTreeRoots(entries))
+	want := []string{
+		"[DEFAULT] ipv4 10.0.0.0/16",
+		"[DEFAULT] ipv4 10.1.0.0/16",
+		"[DEFAULT] nhg 1",
+		"[vrf1] nh 1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sortedTreeRoots() = %v, want %v", got, want)
+	}
+	if aftEntryKey(entries[0]) != "[vrf1] nh 1" {
+		t.Errorf("sortedTreeRoots() modified its input")
+	}
+}
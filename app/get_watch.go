@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+// defaultWatchInterval is the interval between two Get RPCs in watch mode
+const defaultWatchInterval = 5 * time.Second
+
+// getWatchTarget connects to the target and watches its entries until ctx is done.
+func (a *App) getWatchTarget(ctx context.Context, t *target) error {
+	ctx = appendMetadata(ctx, t.Config)
+	err := a.CreateGrpcClient(ctx, t, a.createBaseDialOpts()...)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	return a.gribiGetWatch(ctx, t)
+}
+
+// gribiGetWatch runs a Get RPC every --interval and prints the entries
+// added, removed and changed since the previous successful Get.
+// It returns when ctx is done, a failed Get is logged and retried at the next interval.
+func (a *App) gribiGetWatch(ctx context.Context, t *target) error {
+	req, err := a.createGetRequest()
+	if err != nil {
+		return err
+	}
+	// validate the filter flags once
+	if _, err = a.newGetFilter(); err != nil {
+		return err
+	}
+	interval := a.Config.GetInterval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	t.gRIBIClient = spb.NewGRIBIClient(t.conn)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var prev aftSnapshot
+	for {
+		// the longest prefix match state is per Get, create a new filter each time.
+		f, _ := a.newGetFilter()
+		curr := make(aftSnapshot)
+		_, err = a.getEach(ctx, t, req, f, false, func(e *spb.AFTEntry) error {
+			curr.add(e)
+			return nil
+		})
+		now := time.Now()
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			a.Logger.Errorf("target %s: watch Get RPC failed: %v", t.Config.Name, err)
+		case prev == nil:
+			a.printWatch(fmt.Sprintf("%s %q: initial snapshot, %d entries\n",
+				now.Format(time.RFC3339Nano), t.Config.Name, len(curr)))
+			prev = curr
+		default:
+			d := diffSnapshots(prev, curr)
+			if !d.empty() {
+				a.printWatch(formatWatchDelta(now, t.Config.Name, d))
+			}
+			prev = curr
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// printWatch prints the output of a target's watch in one write,
+// so that the outputs of multiple targets do not interleave.
+func (a *App) printWatch(s string) {
+	a.pm.Lock()
+	defer a.pm.Unlock()
+	fmt.Print(s)
+}
+
+// formatWatchDelta formats a snapshot delta, one line per added or removed entry,
+// and one line per changed entry followed by its changed fields.
+func formatWatchDelta(ts time.Time, target string, d *aftDelta) string {
+	sb := new(strings.Builder)
+	prefix := fmt.Sprintf("%s %q:", ts.Format(time.RFC3339Nano), target)
+	for _, e := range d.added {
+		fmt.Fprintf(sb, "%s + %s: %s\n", prefix, aftEntryKey(e), prototext.MarshalOptions{}.Format(e))
+	}
+	for _, e := range d.removed {
+		fmt.Fprintf(sb, "%s - %s\n", prefix, aftEntryKey(e))
+	}
+	for _, c := range d.changed {
+		fmt.Fprintf(sb, "%s ~ %s\n", prefix, c.key)
+		sb.WriteString(c.fieldsString("    "))
+	}
+	return sb.String()
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatWatchDelta(t *testing.T) {
+	prev := newAFTSnapshot(
+		nhEntry(t, "DEFAULT", 1, "192.0.2.1"),
+		nhEntry(t, "DEFAULT", 2, "192.0.2.2"),
+	)
+	cur := newAFTSnapshot(
+		nhEntry(t, "DEFAULT", 1, "192.0.2.10"),
+		nhEntry(t, "DEFAULT", 3, "192.0.2.3"),
+	)
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	got := formatWatchDelta(ts, "r1", diffSnapshots(prev, cur))
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4:\n%s", len(lines), got)
+	}
+	prefix := `2024-01-02T03:04:05Z "r1": `
+	// the prototext format of the added entry is not stable, only its key is checked
+	if !strings.HasPrefix(lines[0], prefix+"+ [DEFAULT] nh 3: ") || !strings.Contains(lines[0], "192.0.2.3") {
+		t.Errorf("unexpected added line: %q", lines[0])
+	}
+	want := []string{
+		prefix + "- [DEFAULT] nh 2",
+		prefix + "~ [DEFAULT] nh 1",
+		"    next_hop.next_hop.ip_address.value: 192.0.2.1 -> 192.0.2.10",
+	}
+	for i, w := range want {
+		if lines[i+1] != w {
+			t.Errorf("line %d = %q, want %q", i+1, lines[i+1], w)
+		}
+	}
+	if s := formatWatchDelta(ts, "r1", diffSnapshots(cur, cur)); s != "" {
+		t.Errorf("formatWatchDelta() without changes = %q, want an empty string", s)
+	}
+}
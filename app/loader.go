@@ -0,0 +1,96 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/karimra/gribic/config"
+	"github.com/karimra/gribic/loaders"
+)
+
+// loadTargets adds the targets of the configured loader, if any,
+// to the ones of the config file. It is a no-op if --address is set.
+func (a *App) loadTargets(ctx context.Context) error {
+	if len(a.Config.Address) > 0 {
+		return nil
+	}
+	lc, err := a.Config.GetLoader()
+	if err != nil || lc == nil {
+		return err
+	}
+	l, err := loaders.New(lc, a.Logger)
+	if err != nil {
+		return err
+	}
+	tcs, err := l.Load(ctx)
+	if err != nil {
+		return err
+	}
+	a.Logger.Debugf("loader: loaded %d targets", len(tcs))
+	return a.Config.SetLoadedTargets(tcs)
+}
+
+// runLoadedTargets runs fn against the selected targets until ctx is done,
+// it is started for the targets added by the loader and canceled for the removed ones.
+// A target whose config changes is restarted.
+func (a *App) runLoadedTargets(ctx context.Context, lc *config.LoaderConfig, fn func(ctx context.Context, t *target) error) error {
+	l, err := loaders.New(lc, a.Logger)
+	if err != nil {
+		return err
+	}
+	type running struct {
+		tc     string
+		cancel context.CancelFunc
+	}
+	targets := make(map[string]*running)
+	wg := new(sync.WaitGroup)
+	defer wg.Wait()
+	for tcs := range loaders.Watch(ctx, l, lc.Interval, a.Logger) {
+		err = a.Config.SetLoadedTargets(tcs)
+		if err != nil {
+			a.Logger.Errorf("loader: %v", err)
+			continue
+		}
+		selected, err := a.Config.GetTargets()
+		if err != nil {
+			a.Logger.Warnf("loader: %v", err)
+			selected = nil
+		}
+		for n, r := range targets {
+			if tc, ok := selected[n]; ok && targetFingerprint(tc) == r.tc {
+				continue
+			}
+			a.Logger.Infof("loader: removing target %s", n)
+			r.cancel()
+			delete(targets, n)
+		}
+		for n, tc := range selected {
+			if _, ok := targets[n]; ok {
+				continue
+			}
+			a.Logger.Infof("loader: adding target %s", n)
+			tctx, cancel := context.WithCancel(ctx)
+			targets[n] = &running{tc: targetFingerprint(tc), cancel: cancel}
+			wg.Add(1)
+			go func(t *target) {
+				defer wg.Done()
+				err := fn(tctx, t)
+				if err != nil && tctx.Err() == nil {
+					a.Logger.Errorf("%q: %v", t.Config.Address, err)
+				}
+			}(NewTarget(tc))
+		}
+	}
+	for _, r := range targets {
+		r.cancel()
+	}
+	return nil
+}
+
+// targetFingerprint returns a string identifying the config of a target,
+// unlike tc.String() it does not mask the password and token, so a credentials change is detected.
+func targetFingerprint(tc *config.TargetConfig) string {
+	b, _ := json.Marshal(tc)
+	return string(b)
+}
@@ -0,0 +1,25 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/karimra/gribic/config"
+)
+
+func TestTargetFingerprint(t *testing.T) {
+	pass1, pass2 := "secret1", "secret2"
+	token := "token"
+	tc1 := &config.TargetConfig{Name: "r1", Address: "10.0.0.1:57400", Password: &pass1}
+	tc2 := &config.TargetConfig{Name: "r1", Address: "10.0.0.1:57400", Password: &pass2}
+	if targetFingerprint(tc1) == targetFingerprint(tc2) {
+		t.Errorf("a password change is not detected")
+	}
+	tc3 := &config.TargetConfig{Name: "r1", Address: "10.0.0.1:57400", Password: &pass1, Token: &token}
+	if targetFingerprint(tc1) == targetFingerprint(tc3) {
+		t.Errorf("a token change is not detected")
+	}
+	tc4 := &config.TargetConfig{Name: "r1", Address: "10.0.0.1:57400", Password: &pass1}
+	if targetFingerprint(tc1) != targetFingerprint(tc4) {
+		t.Errorf("identical configs have different fingerprints")
+	}
+}
@@ -57,23 +57,41 @@ func (a *App) ModifyRunE(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	a.Logger.Debugf("targets: %v", targets)
+	errs := a.rollout(targets, a.modifyStage)
+	return a.handleErrs(errs)
+}
+
+// modifyStage runs the modify RPC against the targets of a rollout stage,
+// a target fails if the RPC fails or if one of its operations is FAILED or FIB_FAILED.
+func (a *App) modifyStage(targets map[string]*target) map[string]error {
 	numTargets := len(targets)
 	responseChan := make(chan *modifyResponse, numTargets)
 	a.wg.Add(numTargets)
 	for _, t := range targets {
 		go func(t *target) {
 			defer a.wg.Done()
+			release, err := a.acquireTarget(a.ctx)
+			if err != nil {
+				responseChan <- &modifyResponse{
+					TargetError: TargetError{
+						TargetName: t.Config.Name,
+						Err:        err,
+					},
+				}
+				return
+			}
+			defer release()
 			// create context
 			ctx, cancel := context.WithCancel(a.ctx)
 			defer cancel()
-			// append credentials to context
-			ctx = appendCredentials(ctx, t.Config)
+			// append metadata to context
+			ctx = appendMetadata(ctx, t.Config)
 			// create a grpc conn
 			err = a.CreateGrpcClient(ctx, t, a.createBaseDialOpts()...)
 			if err != nil {
 				responseChan <- &modifyResponse{
 					TargetError: TargetError{
-						TargetName: t.Config.Address,
+						TargetName: t.Config.Name,
 						Err:        err,
 					},
 				}
@@ -81,17 +99,28 @@ func (a *App) ModifyRunE(cmd *cobra.Command, args []string) error {
 			}
 			defer t.Close()
 			rspCh := a.gribiModify(ctx, t)
+			var failure error
 			for {
 				select {
 				case rsp, ok := <-rspCh:
 					if !ok {
+						responseChan <- &modifyResponse{
+							TargetError: TargetError{
+								TargetName: t.Config.Name,
+								Err:        failure,
+							},
+						}
 						return
 					}
 					if rsp != nil {
 						if rsp.TargetError.Err != nil {
 							a.Logger.Errorf("%+v", rsp.TargetError)
+							failure = rsp.TargetError.Err
 						} else {
 							a.Logger.Printf("%s\nresponse: %s", rsp.TargetError.TargetName, prototext.Format(rsp.rsp))
+							if err := modifyResultsError(rsp.rsp); err != nil {
+								failure = err
+							}
 						}
 
 					}
@@ -105,6 +134,26 @@ func (a *App) ModifyRunE(cmd *cobra.Command, args []string) error {
 	a.wg.Wait()
 	close(responseChan)
 
+	errs := make(map[string]error)
+	for rsp := range responseChan {
+		if rsp.Err != nil {
+			wErr := fmt.Errorf("%q Modify RPC failed: %v", rsp.TargetName, rsp.Err)
+			a.Logger.Error(wErr)
+			errs[rsp.TargetName] = wErr
+		}
+	}
+	return errs
+}
+
+// modifyResultsError returns an error if one of the response results
+// is FAILED or FIB_FAILED.
+func modifyResultsError(rsp *spb.ModifyResponse) error {
+	for _, result := range rsp.GetResult() {
+		switch result.GetStatus() {
+		case spb.AFTResult_FAILED, spb.AFTResult_FIB_FAILED:
+			return fmt.Errorf("operation %d: %s", result.GetId(), result.GetStatus())
+		}
+	}
 	return nil
 }
 
@@ -118,6 +167,8 @@ func (a *App) gribiModify(ctx context.Context, t *target) chan *modifyResponse {
 			a.Logger.Infof("target %s modify stream done", t.Config.Name)
 		}()
 		// create client
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
 		modClient, err := t.gRIBIClient.Modify(ctx)
 		if err != nil {
 			rspCh <- &modifyResponse{
@@ -128,7 +179,7 @@ func (a *App) gribiModify(ctx context.Context, t *target) chan *modifyResponse {
 			}
 			return
 		}
-		modifyInput, err := a.Config.GenerateModifyInputs(t.Config.Name)
+		modifyInput, err := a.Config.GenerateModifyInputs(t.Config)
 		if err != nil {
 			rspCh <- &modifyResponse{
 				TargetError: TargetError{
@@ -140,7 +191,7 @@ func (a *App) gribiModify(ctx context.Context, t *target) chan *modifyResponse {
 		}
 
 		// session parameters & election ID
-		modParams, err := a.createModifyRequestParams(modifyInput)
+		modParams, err := a.createModifyRequestParams(t.Config, modifyInput)
 		if err != nil {
 			rspCh <- &modifyResponse{
 				TargetError: TargetError{
@@ -150,6 +201,8 @@ func (a *App) gribiModify(ctx context.Context, t *target) chan *modifyResponse {
 			}
 			return
 		}
+		// the target election ID is validated when loading the targets
+		electionID, _ := a.targetElectionID(t.Config)
 		switch len(modParams) {
 		case 1: // no election ID (all-primary)
 			a.Logger.Printf("sending request=%v to %q", modParams[0], t.Config.Name)
@@ -163,7 +216,7 @@ func (a *App) gribiModify(ctx context.Context, t *target) chan *modifyResponse {
 				}
 				return
 			}
-			modRsp, err := modClient.Recv()
+			modRsp, err := recvModifyResponse(t, modClient, cancel)
 			rspCh <- &modifyResponse{
 				TargetError: TargetError{
 					TargetName: t.Config.Name,
@@ -186,7 +239,7 @@ func (a *App) gribiModify(ctx context.Context, t *target) chan *modifyResponse {
 				}
 				return
 			}
-			modRsp, err := modClient.Recv()
+			modRsp, err := recvModifyResponse(t, modClient, cancel)
 			rspCh <- &modifyResponse{
 				TargetError: TargetError{
 					TargetName: t.Config.Name,
@@ -210,7 +263,7 @@ func (a *App) gribiModify(ctx context.Context, t *target) chan *modifyResponse {
 				}
 				return
 			}
-			modRsp, err = modClient.Recv()
+			modRsp, err = recvModifyResponse(t, modClient, cancel)
 			rspCh <- &modifyResponse{
 				TargetError: TargetError{
 					TargetName: t.Config.Name,
@@ -221,13 +274,13 @@ func (a *App) gribiModify(ctx context.Context, t *target) chan *modifyResponse {
 			if err != nil {
 				return
 			}
-			if a.electionID != nil && modRsp.ElectionId != nil {
-				if a.electionID.High < modRsp.ElectionId.High {
-					a.Logger.Infof("target's last known electionID is higher than client's: %+v > %+v", modRsp.ElectionId, a.electionID)
+			if electionID != nil && modRsp.ElectionId != nil {
+				if electionID.High < modRsp.ElectionId.High {
+					a.Logger.Infof("target's last known electionID is higher than client's: %+v > %+v", modRsp.ElectionId, electionID)
 					return
 				}
-				if a.electionID.High == modRsp.ElectionId.High && a.electionID.Low < modRsp.ElectionId.Low {
-					a.Logger.Infof("target's last known electionID is higher than client's: %+v > %+v", modRsp.ElectionId, a.electionID)
+				if electionID.High == modRsp.ElectionId.High && electionID.Low < modRsp.ElectionId.Low {
+					a.Logger.Infof("target's last known electionID is higher than client's: %+v > %+v", modRsp.ElectionId, electionID)
 					return
 				}
 			}
@@ -255,7 +308,7 @@ func (a *App) gribiModify(ctx context.Context, t *target) chan *modifyResponse {
 				}
 				return
 			}
-			modRsp, err := modClient.Recv()
+			modRsp, err := recvModifyResponse(t, modClient, cancel)
 			rspCh <- &modifyResponse{
 				TargetError: TargetError{
 					TargetName: t.Config.Name,
@@ -284,55 +337,69 @@ func (a *App) gribiModify(ctx context.Context, t *target) chan *modifyResponse {
 	return rspCh
 }
 
-func (a *App) createModifyRequestParams(modifyInput *config.ModifyInput) ([]*spb.ModifyRequest, error) {
-	if modifyInput.Params == nil {
-		modReq, err := api.NewModifyRequest(
-			api.PersistenceDelete(),
-			api.RedundancyAllPrimary(),
-			api.AckTypeRib(),
-		)
-		return []*spb.ModifyRequest{modReq}, err
+// createModifyRequestParams returns the session parameters request of target tc,
+// followed by the election ID request if the session is single-primary.
+// Each session parameter is taken from, in order of precedence:
+// the target's session-params, the modify command flags and the modify input params.
+func (a *App) createModifyRequestParams(tc *config.TargetConfig, modifyInput *config.ModifyInput) ([]*spb.ModifyRequest, error) {
+	flagParams := new(config.SessionParams)
+	if a.Config.ModifySessionRedundancySinglePrimary {
+		flagParams.Redundancy = "single-primary"
 	}
+	if a.Config.ModifySessionPersistancePreserve {
+		flagParams.Persistence = "preserve"
+	}
+	if a.Config.ModifySessionRibFibAck {
+		flagParams.AckType = "rib-fib"
+	}
+	params := config.MergeSessionParams(tc.SessionParams, flagParams, modifyInput.Params)
+	a.Logger.Debugf("target %s session params: %+v", tc.Name, params)
 
-	opts := make([]api.GRIBIOption, 0, 4)
-
-	switch {
-	case a.Config.ModifySessionPersistancePreserve ||
-		(modifyInput.Params.Persistence == "preserve" && !a.Config.ModifySessionPersistancePreserve):
+	opts := make([]api.GRIBIOption, 0, 3)
+	switch params.Persistence {
+	case "preserve":
 		opts = append(opts, api.PersistencePreserve())
 	default:
 		opts = append(opts, api.PersistenceDelete())
 	}
-
-	switch {
-	case a.Config.ModifySessionRedundancySinglePrimary ||
-		(modifyInput.Params.Redundancy == "single-primary" && !a.Config.ModifySessionRedundancySinglePrimary):
-		opts = append(opts,
-			api.RedundancySinglePrimary(),
-			// ,
-		)
-	default:
-		opts = append(opts, api.RedundancyAllPrimary())
-	}
-
-	switch {
-	case a.Config.ModifySessionRibFibAck ||
-		(modifyInput.Params.AckType == "rib-fib" && !a.Config.ModifySessionRibFibAck):
+	switch params.AckType {
+	case "rib-fib":
 		opts = append(opts, api.AckTypeRibFib())
 	default:
 		opts = append(opts, api.AckTypeRib())
 	}
-	sessParams, err := api.NewModifyRequest(opts...)
+	if params.Redundancy != "single-primary" {
+		modReq, err := api.NewModifyRequest(append(opts, api.RedundancyAllPrimary())...)
+		return []*spb.ModifyRequest{modReq}, err
+	}
+	sessParams, err := api.NewModifyRequest(append(opts, api.RedundancySinglePrimary())...)
 	if err != nil {
 		return nil, err
 	}
-	elecIdReq, err := api.NewModifyRequest(api.ElectionID(a.electionID))
+	electionID, err := a.targetElectionID(tc)
+	if err != nil {
+		return nil, err
+	}
+	elecIdReq, err := api.NewModifyRequest(api.ElectionID(electionID))
 	if err != nil {
 		return nil, err
 	}
 	return []*spb.ModifyRequest{sessParams, elecIdReq}, err
 }
 
+// targetElectionID returns the election ID set in the target config,
+// or the one set with --election-id.
+func (a *App) targetElectionID(tc *config.TargetConfig) (*spb.Uint128, error) {
+	eid, err := tc.GetElectionID()
+	if err != nil {
+		return nil, err
+	}
+	if eid != nil {
+		return eid, nil
+	}
+	return a.electionID, nil
+}
+
 func (a *App) createModifyRequestOperation(modifyInput *config.ModifyInput) ([]*spb.ModifyRequest, error) {
 	reqs := make([]*spb.ModifyRequest, 0)
 
@@ -348,7 +415,11 @@ func (a *App) createModifyRequestOperation(modifyInput *config.ModifyInput) ([]*
 	return reqs, nil
 }
 
-func (a *App) modifyChan(ctx context.Context, t *target, modReqCh chan *spb.ModifyRequest) (chan *spb.ModifyResponse, chan error) {
+// modifyChan sends the requests of modReqCh on the target modify stream,
+// the responses channel is closed once all the sent operations got a final result.
+// If fibAck is set, the session ack type is RIB_AND_FIB_ACK and a RIB_PROGRAMMED
+// result is followed by a FIB_PROGRAMMED or FIB_FAILED one.
+func (a *App) modifyChan(ctx context.Context, t *target, modReqCh chan *spb.ModifyRequest, fibAck bool) (chan *spb.ModifyResponse, chan error) {
 	rspChan := make(chan *spb.ModifyResponse)
 	errChan := make(chan error, 1)
 	m := new(sync.Mutex)
@@ -388,9 +459,16 @@ func (a *App) modifyChan(ctx context.Context, t *target, modReqCh chan *spb.Modi
 				errChan <- err
 				return
 			}
-			rspChan <- modRsp
+			select {
+			case rspChan <- modRsp:
+			case <-ctx.Done():
+				return
+			}
 			m.Lock()
 			for _, res := range modRsp.GetResult() {
+				if fibAck && res.GetStatus() == spb.AFTResult_RIB_PROGRAMMED {
+					continue
+				}
 				delete(ops, res.GetId())
 			}
 			if len(ops) == 0 {
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+// withRetry calls fn until it succeeds, it fails with an error the target retry policy
+// does not retry, or the policy attempts are exhausted.
+// Each attempt is bounded by timeout if it is not zero.
+func (a *App) withRetry(ctx context.Context, t *target, rpc string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	rc := t.Config.Retry
+	maxAttempts := 1
+	var backoff time.Duration
+	if rc != nil {
+		maxAttempts = rc.MaxAttempts
+		backoff = rc.Backoff
+	}
+	for attempt := 1; ; attempt++ {
+		err := callWithTimeout(ctx, rpc, timeout, fn)
+		if err == nil || attempt >= maxAttempts || ctx.Err() != nil {
+			return err
+		}
+		var nrErr *noRetryError
+		if errors.As(err, &nrErr) || !rc.Retryable(err) {
+			return err
+		}
+		a.Logger.Warnf("target %s: %s RPC attempt %d/%d failed: %v, retrying in %s",
+			t.Config.Name, rpc, attempt, maxAttempts, err, backoff)
+		err = sleep(ctx, backoff)
+		if err != nil {
+			return err
+		}
+		backoff = rc.NextBackoff(backoff)
+	}
+}
+
+// noRetryError wraps an error withRetry does not retry,
+// e.g: a Get RPC failing after entries were received.
+type noRetryError struct {
+	err error
+}
+
+func (e *noRetryError) Error() string { return e.err.Error() }
+
+func (e *noRetryError) Unwrap() error { return e.err }
+
+// callWithTimeout calls fn with a context bounded by timeout if it is not zero,
+// an expired timeout is reported with the RPC name.
+func callWithTimeout(ctx context.Context, rpc string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+	tctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := fn(tctx)
+	if err != nil && ctx.Err() == nil && errors.Is(tctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s RPC timed out after %s: %w", rpc, timeout, err)
+	}
+	return err
+}
+
+// ackTimer returns a channel receiving once the target ack-timeout expires,
+// it never receives if the target has no ack-timeout.
+// The returned function stops the timer.
+func ackTimer(t *target) (<-chan time.Time, func()) {
+	if t.Config.AckTimeout <= 0 {
+		return nil, func() {}
+	}
+	timer := time.NewTimer(t.Config.AckTimeout)
+	return timer.C, func() { timer.Stop() }
+}
+
+// recvModifyResponse receives a response on the modify stream,
+// the stream is canceled if none is received within the target ack-timeout.
+func recvModifyResponse(t *target, modClient spb.GRIBI_ModifyClient, cancel context.CancelFunc) (*spb.ModifyResponse, error) {
+	if t.Config.AckTimeout <= 0 {
+		return modClient.Recv()
+	}
+	timer := time.AfterFunc(t.Config.AckTimeout, cancel)
+	rsp, err := modClient.Recv()
+	if !timer.Stop() {
+		return nil, ackTimeoutError(t)
+	}
+	return rsp, err
+}
+
+func ackTimeoutError(t *target) error {
+	return fmt.Errorf("modify response not received within the ack-timeout %s", t.Config.AckTimeout)
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+// stageFunc runs a command against the targets of a rollout stage,
+// it returns the errors of the failed targets indexed by target name.
+type stageFunc func(targets map[string]*target) map[string]error
+
+// acquireTarget waits for one of the --max-concurrency slots,
+// the returned function frees it.
+func (a *App) acquireTarget(ctx context.Context) (func(), error) {
+	if a.targetsSem == nil {
+		return func() {}, nil
+	}
+	err := a.targetsSem.Acquire(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	return func() { a.targetsSem.Release(1) }, nil
+}
+
+// rollout runs fn against the targets stage by stage: the --canary targets first,
+// then batches of --batch targets.
+// The targets that succeeded in a stage are checked using the --verify-file workflow, if set.
+// The rollout halts after the first stage that brings the number of failed targets
+// above --max-failures.
+func (a *App) rollout(targets map[string]*target, fn stageFunc) []error {
+	names := make([]string, 0, len(targets))
+	for n := range targets {
+		names = append(names, n)
+	}
+	stages := a.Config.RolloutStages(names)
+	errs := make([]error, 0)
+	var failed, started int
+	for i, stage := range stages {
+		if len(stages) > 1 {
+			a.Logger.Infof("rollout stage %d/%d: %d target(s): %s", i+1, len(stages), len(stage), strings.Join(stage, ", "))
+		}
+		stageTargets := make(map[string]*target, len(stage))
+		for _, n := range stage {
+			stageTargets[n] = targets[n]
+		}
+		stageErrs := fn(stageTargets)
+		if a.Config.VerifyFile != "" {
+			for n, err := range a.verifyStage(stageTargets, stageErrs) {
+				stageErrs[n] = err
+			}
+		}
+		for _, n := range stage {
+			if err, ok := stageErrs[n]; ok {
+				errs = append(errs, err)
+			}
+		}
+		failed += len(stageErrs)
+		started += len(stage)
+		if started == len(names) {
+			break
+		}
+		if failed > a.Config.MaxFailures {
+			err := fmt.Errorf("rollout halted after stage %d/%d: %d target(s) failed, %d target(s) not started",
+				i+1, len(stages), failed, len(names)-started)
+			a.Logger.Error(err)
+			return append(errs, err)
+		}
+		if a.Config.BatchWait > 0 {
+			a.Logger.Infof("rollout stage %d/%d done, waiting %s", i+1, len(stages), a.Config.BatchWait)
+			err := sleep(a.ctx, a.Config.BatchWait)
+			if err != nil {
+				return append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// verifyStage runs the --verify-file workflow against the targets of a stage
+// that are not in failed, it returns the errors of the targets that failed the verification.
+func (a *App) verifyStage(targets map[string]*target, failed map[string]error) map[string]error {
+	responseChan := make(chan *TargetError, len(targets))
+	for n, t := range targets {
+		if _, ok := failed[n]; ok {
+			continue
+		}
+		a.wg.Add(1)
+		go func(t *target) {
+			defer a.wg.Done()
+			err := a.verifyTarget(t)
+			if err != nil {
+				err = fmt.Errorf("target=%q: verification failed: %v", t.Config.Name, err)
+				a.Logger.Error(err)
+			}
+			responseChan <- &TargetError{
+				TargetName: t.Config.Name,
+				Err:        err,
+			}
+		}(t)
+	}
+	a.wg.Wait()
+	close(responseChan)
+
+	errs := make(map[string]error)
+	for rsp := range responseChan {
+		if rsp.Err != nil {
+			errs[rsp.TargetName] = rsp.Err
+		}
+	}
+	return errs
+}
+
+// verifyTarget runs the --verify-file workflow steps against the target
+// using a new connection, it stops at the first failed step.
+func (a *App) verifyTarget(t *target) error {
+	wf, err := a.Config.GenerateVerifyWorkflow(t.Config)
+	if err != nil {
+		return err
+	}
+	release, err := a.acquireTarget(a.ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	// create context
+	ctx, cancel := context.WithCancel(a.ctx)
+	defer cancel()
+	// append metadata to context
+	ctx = appendMetadata(ctx, t.Config)
+	// the stage may still hold the target connection
+	vt := NewTarget(t.Config)
+	err = a.CreateGrpcClient(ctx, vt, a.createBaseDialOpts()...)
+	if err != nil {
+		return err
+	}
+	defer vt.Close()
+	vt.gRIBIClient = spb.NewGRIBIClient(vt.conn)
+
+	wf.SetDefaults()
+	exec := newExec(wf, nil)
+	for _, s := range wf.Steps {
+		if !wf.RunsOn(s, t.Config) {
+			continue
+		}
+		err = a.runStep(ctx, vt, exec, s)
+		if err != nil {
+			return fmt.Errorf("step %s: %v", s.Name, err)
+		}
+	}
+	return nil
+}
@@ -34,6 +34,10 @@ func NewTarget(tc *config.TargetConfig) *target {
 }
 
 func (a *App) GetTargets() (map[string]*target, error) {
+	err := a.loadTargets(a.ctx)
+	if err != nil {
+		return nil, err
+	}
 	targetsConfigs, err := a.Config.GetTargets()
 	if err != nil {
 		return nil, err
@@ -56,6 +60,6 @@ func (t *target) createModifyClient(ctx context.Context) error {
 	mctx, cancel := context.WithCancel(ctx)
 	t.modifyCfn = cancel
 	var err error
-	t.modClient, err = t.gRIBIClient.Modify(appendCredentials(mctx, t.Config))
+	t.modClient, err = t.gRIBIClient.Modify(appendMetadata(mctx, t.Config))
 	return err
 }
@@ -1,6 +1,9 @@
 package app
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 const (
 	defaultGrpcPort   = "57401"
@@ -12,3 +15,21 @@ type TargetError struct {
 	TargetName string
 	Err        error
 }
+
+// ExitError is returned by commands that report their result
+// with a specific exit code, Err is nil if there is nothing to report.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit code %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// vars show output formats, the default text format is yaml
+const (
+	varsFormatText = "text"
+	varsFormatYAML = "yaml"
+	varsFormatJSON = "json"
+)
+
+func (a *App) InitVarsShowFlags(cmd *cobra.Command) {
+	cmd.ResetFlags()
+	//
+	cmd.Flags().StringVarP(&a.Config.VarsShowFile, "file", "", "", "template file, a modify input or workflow file, whose vars file and vars directory are used")
+	//
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		a.Config.FileConfig.BindPFlag(fmt.Sprintf("%s-%s", cmd.Name(), flag.Name), flag)
+	})
+}
+
+// VarsShowRunE prints the template variables of each target,
+// merged from all the variables layers, without connecting to the targets.
+func (a *App) VarsShowRunE(cmd *cobra.Command, args []string) error {
+	switch a.Config.Format {
+	case varsFormatText, varsFormatYAML, varsFormatJSON:
+	default:
+		return fmt.Errorf("unknown --format %q, the vars show command accepts: text, yaml or json", a.Config.Format)
+	}
+	targetsConfigs, err := a.Config.GetTargets()
+	if err != nil {
+		return err
+	}
+	var fileVars map[string]interface{}
+	if a.Config.VarsShowFile != "" {
+		fileVars, err = a.Config.ReadTemplateVarsFile(a.Config.VarsShowFile)
+		if err != nil {
+			return err
+		}
+	}
+	targetNames := make([]string, 0, len(targetsConfigs))
+	for n := range targetsConfigs {
+		targetNames = append(targetNames, n)
+	}
+	sort.Strings(targetNames)
+
+	out := make(map[string]map[string]interface{}, len(targetNames))
+	for _, name := range targetNames {
+		vars, err := a.Config.TargetVars(targetsConfigs[name], a.Config.VarsShowFile, fileVars)
+		if err != nil {
+			return fmt.Errorf("target=%q: %v", name, err)
+		}
+		if vars == nil {
+			vars = make(map[string]interface{})
+		}
+		out[name] = vars
+	}
+
+	var b []byte
+	switch a.Config.Format {
+	case varsFormatJSON:
+		b, err = json.MarshalIndent(out, "", "  ")
+		b = append(b, '\n')
+	default:
+		b, err = yaml.Marshal(out)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
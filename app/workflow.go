@@ -6,13 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"strings"
 	"sync"
 	"time"
 
 	spb "github.com/openconfig/gribi/v1/proto/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/karimra/gribic/api"
 	"github.com/karimra/gribic/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -22,6 +24,12 @@ func (a *App) InitWorkflowFlags(cmd *cobra.Command) {
 	cmd.ResetFlags()
 	//
 	cmd.Flags().StringVarP(&a.Config.WorkflowFile, "file", "", "", "workflow file")
+	cmd.Flags().DurationVarP(&a.Config.WorkflowTimeout, "workflow-timeout", "", 0, "bounds the workflow execution duration per target, 0 means no timeout")
+	cmd.Flags().StringVarP(&a.Config.WorkflowStartAt, "start-at", "", "", "step name, include name or tag to start the workflow at")
+	cmd.Flags().StringSliceVarP(&a.Config.WorkflowOnly, "only", "", nil, "step names, include names or tags to run, the other steps are skipped")
+	cmd.Flags().StringSliceVarP(&a.Config.WorkflowSkip, "skip", "", nil, "step names, include names or tags to skip")
+	cmd.Flags().BoolVarP(&a.Config.WorkflowResume, "resume", "", false, "skip the steps completed by a previous run, as recorded in the checkpoint file")
+	cmd.Flags().StringVarP(&a.Config.WorkflowCheckpoint, "checkpoint", "", "", "checkpoint file path, enables the checkpoint. With --resume only, defaults to the workflow file path with a _checkpoint.json suffix")
 	//
 	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
 		a.Config.FileConfig.BindPFlag(fmt.Sprintf("%s-%s", cmd.Name(), flag.Name), flag)
@@ -46,329 +54,704 @@ func (a *App) WorkflowRunE(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	a.Logger.Debugf("targets: %v", targets)
+	// the checkpoint is written only if --checkpoint or --resume is set
+	var cp *checkpoint
+	switch {
+	case a.Config.WorkflowResume:
+		cp, err = loadCheckpoint(a.Config.WorkflowCheckpointFile())
+		if err != nil {
+			return err
+		}
+	case a.Config.WorkflowCheckpoint != "":
+		cp = newCheckpoint(a.Config.WorkflowCheckpointFile())
+	}
+	err = a.checkWorkflowConcurrency(targets)
+	if err != nil {
+		return err
+	}
+	errs := a.rollout(targets, func(targets map[string]*target) map[string]error {
+		return a.workflowStage(targets, cp)
+	})
+	return a.handleErrs(errs)
+}
+
+// workflowStage runs the workflow against the targets of a rollout stage,
+// the workflow barriers synchronize the targets of the stage.
+func (a *App) workflowStage(targets map[string]*target, cp *checkpoint) map[string]error {
 	numTargets := len(targets)
 	a.wg.Add(numTargets)
-	errCh := make(chan error, numTargets)
+	errCh := make(chan *TargetError, numTargets)
+	bs := newBarriers(numTargets)
 	for _, t := range targets {
 		go func(t *target) {
 			defer a.wg.Done()
+			defer bs.leave()
+			release, err := a.acquireTarget(a.ctx)
+			if err != nil {
+				errCh <- &TargetError{TargetName: t.Config.Name, Err: err}
+				return
+			}
+			defer release()
 			// render the workflow
-			wf, err := a.Config.GenerateWorkflow(t.Config.Name)
+			wf, err := a.Config.GenerateWorkflow(t.Config)
 			if err != nil {
-				errCh <- fmt.Errorf("target=%q: failed to generate workflow: %v", t.Config.Name, err)
+				errCh <- &TargetError{
+					TargetName: t.Config.Name,
+					Err:        fmt.Errorf("target=%q: failed to generate workflow: %v", t.Config.Name, err),
+				}
 				return
 			}
 
 			// create context
 			ctx, cancel := context.WithCancel(a.ctx)
 			defer cancel()
-			// append credentials to context
-			ctx = appendCredentials(ctx, t.Config)
+			// append metadata to context
+			ctx = appendMetadata(ctx, t.Config)
 			// create a gRPC conn
 			err = a.CreateGrpcClient(ctx, t, a.createBaseDialOpts()...)
 			if err != nil {
-				errCh <- fmt.Errorf("target=%q: failed to create a GRPC client: %v", t.Config.Name, err)
+				errCh <- &TargetError{
+					TargetName: t.Config.Name,
+					Err:        fmt.Errorf("target=%q: failed to create a GRPC client: %v", t.Config.Name, err),
+				}
 				return
 			}
 			defer t.Close()
 			//
-			ex, err := a.runWorkflow(ctx, t, wf)
+			ex, err := a.runWorkflow(ctx, t, wf, bs, cp)
+			if ex != nil {
+				a.pm.Lock()
+				fmt.Println(ex.String())
+				a.pm.Unlock()
+			}
 			if err != nil {
 				a.Logger.Errorf("target=%q: failed run workflow: %v", t.Config.Name, err)
-				errCh <- fmt.Errorf("target=%q: failed run workflow: %v", t.Config.Name, err)
-				return
+				errCh <- &TargetError{
+					TargetName: t.Config.Name,
+					Err:        fmt.Errorf("target=%q: failed run workflow: %v", t.Config.Name, err),
+				}
 			}
-			a.pm.Lock()
-			fmt.Println(ex.String())
-			a.pm.Unlock()
 		}(t)
 	}
 	a.wg.Wait()
 	close(errCh)
 
-	errs := make([]error, 0) //, numTargets)
-	for err := range errCh {
-		if err != nil {
-			errs = append(errs, err)
+	errs := make(map[string]error)
+	for te := range errCh {
+		errs[te.TargetName] = te.Err
+	}
+	return errs
+}
+
+// checkWorkflowConcurrency refuses to run a workflow with barrier steps
+// if --max-concurrency does not let all the targets of a stage run at once:
+// the targets waiting at a barrier would hold the slots the others need to reach it.
+func (a *App) checkWorkflowConcurrency(targets map[string]*target) error {
+	if a.Config.MaxConcurrency <= 0 || len(targets) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(targets))
+	for n := range targets {
+		names = append(names, n)
+	}
+	stages := a.Config.RolloutStages(names)
+	var largest int
+	for _, stage := range stages {
+		if len(stage) > largest {
+			largest = len(stage)
 		}
 	}
-	return a.handleErrs(errs)
+	if largest <= a.Config.MaxConcurrency {
+		return nil
+	}
+	wf, err := a.Config.GenerateWorkflow(targets[stages[0][0]].Config)
+	if err != nil {
+		return err
+	}
+	for _, s := range wf.Steps {
+		if s.Kind() == "barrier" {
+			return fmt.Errorf("workflow step %q is a barrier, --max-concurrency %d must not be lower than the %d targets of a rollout stage",
+				s.Name, a.Config.MaxConcurrency, largest)
+		}
+	}
+	return nil
 }
 
-func (a *App) runWorkflow(ctx context.Context, t *target, wf *config.Workflow) (*execution, error) {
+func (a *App) runWorkflow(ctx context.Context, t *target, wf *config.Workflow, bs *barriers, cp *checkpoint) (*execution, error) {
 	if wf == nil {
 		return nil, errors.New("nil workflow")
 	}
 	if len(wf.Steps) == 0 {
 		return nil, fmt.Errorf("workflow %q has no steps", wf.Name)
 	}
+	wf.SetDefaults()
+	selected, err := wf.Select(&config.StepSelector{
+		StartAt: a.Config.WorkflowStartAt,
+		Only:    a.Config.WorkflowOnly,
+		Skip:    a.Config.WorkflowSkip,
+	})
+	if err != nil {
+		return nil, err
+	}
+	digest, err := wf.Digest()
+	if err != nil {
+		return nil, err
+	}
+	completed, err := cp.start(t.Config.Name, wf.Name, digest, a.Config.WorkflowResume)
+	if err != nil {
+		return nil, err
+	}
 
-	exec := newExec(wf)
+	exec := newExec(wf, bs)
 
 	t.gRIBIClient = spb.NewGRIBIClient(t.conn)
 	// run steps
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	for i, s := range wf.Steps {
-		if s.Name == "" {
-			s.Name = fmt.Sprintf("%s.%d", wf.Name, i+1)
+	// the global and the workflow timeouts both apply,
+	// the earliest deadline wins.
+	if a.Config.WorkflowTimeout > 0 {
+		var wfCancel context.CancelFunc
+		ctx, wfCancel = context.WithTimeout(ctx, a.Config.WorkflowTimeout)
+		defer wfCancel()
+	}
+	if wf.Timeout > 0 {
+		var wfCancel context.CancelFunc
+		ctx, wfCancel = context.WithTimeout(ctx, wf.Timeout)
+		defer wfCancel()
+	}
+	defer func() {
+		if t.modifyCfn != nil {
+			t.modifyCfn()
 		}
-		a.Logger.Infof("workflow=%q: target=%q: step=%s: start", wf.Name, t.Config.Name, s.Name)
-		reqs, err := s.BuildRequests()
+	}()
+	if rollsBack(wf, t.Config) {
+		exec.before, err = a.getRollbackSnapshot(ctx, t)
 		if err != nil {
+			return exec, fmt.Errorf("failed to get the entries restored on rollback: %v", err)
+		}
+	}
+	for i, s := range wf.Steps {
+		if !wf.RunsOn(s, t.Config) {
+			a.Logger.Infof("workflow=%q: target=%q: step=%s: skipped, runs on target %q", wf.Name, t.Config.Name, s.Name, s.Target)
+			continue
+		}
+		_, done := completed[s.Name]
+		if !selected[i] || (done && s.Kind() != "barrier") {
+			a.Logger.Infof("workflow=%q: target=%q: step=%s: skipped, not selected or already completed", wf.Name, t.Config.Name, s.Name)
+			// keep the skipped session parameters and election ID
+			// so that they are sent when the modify stream is opened.
+			err = exec.keepSessionRequests(s)
+			if err != nil {
+				return exec, err
+			}
+			continue
+		}
+		err := a.runStep(ctx, t, exec, s)
+		if err == nil {
+			if cpErr := cp.complete(t.Config.Name, s.Name); cpErr != nil {
+				a.Logger.Warnf("workflow=%q: target=%q: step=%s: failed to save checkpoint: %v", wf.Name, t.Config.Name, s.Name, cpErr)
+			}
+			continue
+		}
+		status := stepStatusFailed
+		if isTimeout(err) {
+			status = stepStatusTimeout
+		}
+		a.Logger.Errorf("workflow=%q: target=%q: step=%s: %s: %v", wf.Name, t.Config.Name, s.Name, status, err)
+		exec.addStep(workflowStepExecution{
+			Timestamp: time.Now(),
+			Workflow:  wf.Name,
+			Step:      s.Name,
+			Target:    t.Config.Name,
+			RPC:       s.Kind(),
+			Status:    status,
+			Error:     err,
+		})
+		policy := s.OnFailure
+		// the workflow context is done, there is nothing to continue.
+		if policy == config.OnFailureContinue && ctx.Err() != nil {
+			policy = config.OnFailureAbort
+		}
+		switch policy {
+		case config.OnFailureContinue:
+			a.Logger.Infof("workflow=%q: target=%q: step=%s: continuing after failure", wf.Name, t.Config.Name, s.Name)
+			continue
+		case config.OnFailureRollback:
+			rbErr := a.rollbackWorkflow(ctx, t, exec, s)
+			if rbErr == nil {
+				// the rolled back steps must run again on resume
+				if _, cpErr := cp.start(t.Config.Name, wf.Name, digest, false); cpErr != nil {
+					a.Logger.Warnf("workflow=%q: target=%q: step=%s: failed to reset checkpoint: %v", wf.Name, t.Config.Name, s.Name, cpErr)
+				}
+			}
+			if rbErr != nil {
+				a.Logger.Errorf("workflow=%q: target=%q: step=%s: rollback failed: %v", wf.Name, t.Config.Name, s.Name, rbErr)
+				exec.addStep(workflowStepExecution{
+					Timestamp: time.Now(),
+					Workflow:  wf.Name,
+					Step:      s.Name,
+					Target:    t.Config.Name,
+					RPC:       "modify",
+					Status:    stepStatusRollbackFailed,
+					Error:     rbErr,
+				})
+			}
+		}
+		return exec, err
+	}
+	return exec, nil
+}
+
+func (a *App) runStep(ctx context.Context, t *target, exec *execution, s *config.Step) error {
+	a.Logger.Infof("workflow=%q: target=%q: step=%s: start", exec.wf.Name, t.Config.Name, s.Name)
+	reqs, err := s.BuildRequests()
+	if err != nil {
+		return err
+	}
+	a.Logger.Debugf("workflow=%q: target=%q: step=%s: requests: %+v", exec.wf.Name, t.Config.Name, s.Name, reqs)
+	// wait duration if any
+	a.Logger.Infof("workflow=%q: target=%q: step=%s: waiting %s", exec.wf.Name, t.Config.Name, s.Name, s.Wait)
+	err = sleep(ctx, s.Wait)
+	if err != nil {
+		return err
+	}
+	// the step timeout bounds the RPC(s) execution only
+	sctx := ctx
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		sctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	switch s.Kind() {
+	case "barrier":
+		err = a.runBarrierStep(sctx, t, exec, s)
+	case "get":
+		err = a.runGetStep(sctx, t, exec, s, reqs)
+	case "flush":
+		err = a.runFlushStep(sctx, t, exec, s, reqs)
+	case "modify":
+		err = a.runModifyStep(ctx, sctx, t, exec, s, reqs)
+	case "print":
+		err = a.runPrintStep(t, exec, s)
+	case "exec":
+		err = a.runExecStep(sctx, t, exec, s)
+	case "verify":
+		err = a.runVerifyStep(sctx, t, exec, s, reqs)
+	case "wait-for":
+		err = a.runWaitForStep(sctx, t, exec, s, reqs)
+	}
+	if err != nil {
+		return err
+	}
+	// wait duration if any
+	a.Logger.Infof("workflow=%q: target=%q: step=%s: waiting %s after execution", exec.wf.Name, t.Config.Name, s.Name, s.WaitAfter)
+	return sleep(ctx, s.WaitAfter)
+}
+
+func (a *App) runGetStep(ctx context.Context, t *target, exec *execution, s *config.Step, reqs []proto.Message) error {
+	wf := exec.wf
+	entries := make([]*spb.AFTEntry, 0)
+	defer func() {
+		if s.Capture != "" {
+			exec.setCapture(s.Capture, entries)
+		}
+	}()
+OUTER:
+	for _, req := range reqs {
+		switch req := req.ProtoReflect().Interface().(type) {
+		case *spb.GetRequest:
+			a.Logger.Infof("workflow=%q: target=%q: step=%s: %T: %v", wf.Name, t.Config.Name, s.Name, req, req)
 			exec.addStep(workflowStepExecution{
 				Timestamp: time.Now(),
 				Workflow:  wf.Name,
 				Step:      s.Name,
 				Target:    t.Config.Name,
-				Error:     err,
+				RPC:       "get",
+				Request:   req,
 			})
-			return exec, err
-		}
-		a.Logger.Debugf("workflow=%q: target=%q: step=%s: requests: %+v", wf.Name, t.Config.Name, s.Name, reqs)
-		// wait duration if any
-		a.Logger.Infof("workflow=%q: target=%q: step=%s: waiting %s", wf.Name, t.Config.Name, s.Name, s.Wait)
-		time.Sleep(s.Wait)
-		switch rpc := strings.ToLower(s.RPC); rpc {
-		case "get":
-		OUTER:
-			for _, req := range reqs {
-				switch req := req.ProtoReflect().Interface().(type) {
-				case *spb.GetRequest:
-					a.Logger.Infof("workflow=%q: target=%q: step=%s: %T: %v", wf.Name, t.Config.Name, s.Name, req, req)
+			rspCh, errCh := a.getChan(ctx, t, req)
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case rsp := <-rspCh:
 					exec.addStep(workflowStepExecution{
 						Timestamp: time.Now(),
 						Workflow:  wf.Name,
 						Step:      s.Name,
 						Target:    t.Config.Name,
-						RPC:       rpc,
-						Request:   req,
+						RPC:       "get",
+						Response:  rsp,
 					})
-					rspCh, errCh := a.getChan(ctx, t, req)
-					for {
-						select {
-						case <-ctx.Done():
-							exec.addStep(workflowStepExecution{
-								Timestamp: time.Now(),
-								Workflow:  wf.Name,
-								Step:      s.Name,
-								Target:    t.Config.Name,
-								RPC:       rpc,
-								Error:     ctx.Err(),
-							})
-							return exec, ctx.Err()
-						case rsp := <-rspCh:
-							exec.addStep(workflowStepExecution{
-								Timestamp: time.Now(),
-								Workflow:  wf.Name,
-								Step:      s.Name,
-								Target:    t.Config.Name,
-								RPC:       rpc,
-								Response:  rsp,
-							})
-							a.Logger.Infof("workflow=%q: target=%q: step=%s: %T: %v", wf.Name, t.Config.Name, s.Name, rsp, rsp)
-						case err := <-errCh:
-							if err == io.EOF {
-								continue OUTER
-							}
-							exec.addStep(workflowStepExecution{
-								Timestamp: time.Now(),
-								Workflow:  wf.Name,
-								Step:      s.Name,
-								Target:    t.Config.Name,
-								RPC:       rpc,
-								Error:     err,
-							})
-							return exec, err
-						}
+					a.Logger.Infof("workflow=%q: target=%q: step=%s: %T: %v", wf.Name, t.Config.Name, s.Name, rsp, rsp)
+					entries = append(entries, rsp.GetEntry()...)
+				case err := <-errCh:
+					if err == io.EOF {
+						continue OUTER
 					}
-				default:
-					err = fmt.Errorf("workflow=%q: unexpected request type: expected GetRequest, got %T", wf.Name, req)
-					exec.addStep(workflowStepExecution{
-						Timestamp: time.Now(),
-						Workflow:  wf.Name,
-						Step:      s.Name,
-						Target:    t.Config.Name,
-						RPC:       rpc,
-						Error:     err,
-					})
-					return exec, err
+					return err
 				}
 			}
-		case "flush":
-			for _, req := range reqs {
-				switch req := req.ProtoReflect().Interface().(type) {
-				case *spb.FlushRequest:
-					a.Logger.Infof("workflow=%q: target=%q: step=%s: %T: %v", wf.Name, t.Config.Name, s.Name, req, req)
-					exec.addStep(workflowStepExecution{
-						Timestamp: time.Now(),
-						Workflow:  wf.Name,
-						Step:      s.Name,
-						Target:    t.Config.Name,
-						RPC:       rpc,
-						Request:   req,
-					})
-					rsp, err := a.flush(ctx, t, req)
-					if err != nil {
-						exec.addStep(workflowStepExecution{
-							Timestamp: time.Now(),
-							Workflow:  wf.Name,
-							Step:      s.Name,
-							Target:    t.Config.Name,
-							RPC:       rpc,
-							Error:     err,
-						})
-						return exec, err
-					}
-					a.Logger.Infof("workflow=%q: target=%q: step=%s, %T: %v\n", wf.Name, t.Config.Name, s.Name, rsp, rsp)
-					exec.addStep(workflowStepExecution{
-						Timestamp: time.Now(),
-						Workflow:  wf.Name,
-						Step:      s.Name,
-						Target:    t.Config.Name,
-						RPC:       rpc,
-						Response:  rsp,
-					})
-				default:
-					err = fmt.Errorf("workflow=%q: unexpected request type: expected FlushRequest, got %T", wf.Name, req)
-					exec.addStep(workflowStepExecution{
-						Timestamp: time.Now(),
-						Workflow:  wf.Name,
-						Step:      s.Name,
-						Target:    t.Config.Name,
-						RPC:       rpc,
-						Error:     err,
-					})
-					return exec, err
-				}
+		default:
+			return fmt.Errorf("workflow=%q: unexpected request type: expected GetRequest, got %T", wf.Name, req)
+		}
+	}
+	return nil
+}
+
+func (a *App) runFlushStep(ctx context.Context, t *target, exec *execution, s *config.Step, reqs []proto.Message) error {
+	wf := exec.wf
+	for _, req := range reqs {
+		switch req := req.ProtoReflect().Interface().(type) {
+		case *spb.FlushRequest:
+			a.Logger.Infof("workflow=%q: target=%q: step=%s: %T: %v", wf.Name, t.Config.Name, s.Name, req, req)
+			exec.addStep(workflowStepExecution{
+				Timestamp: time.Now(),
+				Workflow:  wf.Name,
+				Step:      s.Name,
+				Target:    t.Config.Name,
+				RPC:       "flush",
+				Request:   req,
+			})
+			rsp, err := a.flush(ctx, t, req)
+			if err != nil {
+				return err
 			}
-		case "modify":
-			reqCh := make(chan *spb.ModifyRequest)
-			if t.modClient == nil {
-				t.modClient, err = t.gRIBIClient.Modify(ctx)
-				if err != nil {
-					err = fmt.Errorf("failed creating modify stream: %v", err)
-					exec.addStep(workflowStepExecution{
-						Timestamp: time.Now(),
-						Workflow:  wf.Name,
-						Step:      s.Name,
-						Target:    t.Config.Name,
-						RPC:       rpc,
-						Error:     err,
-					})
-					return exec, err
-				}
+			a.Logger.Infof("workflow=%q: target=%q: step=%s, %T: %v\n", wf.Name, t.Config.Name, s.Name, rsp, rsp)
+			exec.addStep(workflowStepExecution{
+				Timestamp: time.Now(),
+				Workflow:  wf.Name,
+				Step:      s.Name,
+				Target:    t.Config.Name,
+				RPC:       "flush",
+				Response:  rsp,
+			})
+		default:
+			return fmt.Errorf("workflow=%q: unexpected request type: expected FlushRequest, got %T", wf.Name, req)
+		}
+	}
+	return nil
+}
+
+// runBarrierStep waits for all the targets running the workflow to reach the barrier.
+func (a *App) runBarrierStep(ctx context.Context, t *target, exec *execution, s *config.Step) error {
+	// a barrier name can be used multiple times in a workflow,
+	// the key includes the occurrence number.
+	exec.barrierCount[s.Barrier]++
+	key := fmt.Sprintf("%s#%d", s.Barrier, exec.barrierCount[s.Barrier])
+	a.Logger.Infof("workflow=%q: target=%q: step=%s: waiting at barrier %q", exec.wf.Name, t.Config.Name, s.Name, s.Barrier)
+	err := exec.bs.wait(ctx, key)
+	if err != nil {
+		return err
+	}
+	a.Logger.Infof("workflow=%q: target=%q: step=%s: barrier %q released", exec.wf.Name, t.Config.Name, s.Name, s.Barrier)
+	return nil
+}
+
+// runModifyStep sends the step modify requests over the target's modify stream,
+// the stream is bound to the workflow context while the requests acknowledgments
+// are bound to the step context.
+func (a *App) runModifyStep(ctx, sctx context.Context, t *target, exec *execution, s *config.Step, reqs []proto.Message) error {
+	wf := exec.wf
+	if t.modClient == nil {
+		err := a.openWorkflowModifyStream(ctx, t, exec, s)
+		if err != nil {
+			return fmt.Errorf("failed creating modify stream: %v", err)
+		}
+	}
+	for _, req := range reqs {
+		switch req := req.ProtoReflect().Interface().(type) {
+		case *spb.ModifyRequest:
+			a.Logger.Infof("workflow=%q: target=%q: step=%s: %T: %v", wf.Name, t.Config.Name, s.Name, req, req)
+			exec.addStep(workflowStepExecution{
+				Timestamp: time.Now(),
+				Workflow:  wf.Name,
+				Step:      s.Name,
+				Target:    t.Config.Name,
+				RPC:       "modify",
+				Request:   req,
+			})
+			err := a.sendWorkflowModifyRequest(sctx, t, exec, s, req)
+			if err != nil {
+				return err
 			}
-			doneCh := make(chan struct{})
-			go func() {
-				rspCh, errCh := a.modifyChan(ctx, t, reqCh)
-				for {
-					select {
-					case <-ctx.Done():
-						if ctx.Err() == nil || ctx.Err() == context.Canceled {
-							return
-						}
-						a.Logger.Infof("workflow=%q: target=%q: step=%s: context done=%v", wf.Name, t.Config.Name, s.Name, ctx.Err())
-						exec.addStep(workflowStepExecution{
-							Timestamp: time.Now(),
-							Workflow:  wf.Name,
-							Step:      s.Name,
-							Target:    t.Config.Name,
-							RPC:       rpc,
-							Error:     ctx.Err(),
-						})
-						return
-					case rsp, ok := <-rspCh:
-						if !ok {
-							close(doneCh)
-							return
-						}
-						a.Logger.Infof("workflow=%q: target=%q: step=%s: %T: %v", wf.Name, t.Config.Name, s.Name, rsp, rsp)
-						exec.addStep(workflowStepExecution{
-							Timestamp: time.Now(),
-							Workflow:  wf.Name,
-							Step:      s.Name,
-							Target:    t.Config.Name,
-							RPC:       rpc,
-							Response:  rsp,
-						})
-					case err, ok := <-errCh:
-						if !ok {
-							close(doneCh)
-							return
-						}
-						a.Logger.Infof("workflow=%q: target=%q: step=%s: err=%v", t.Config.Name, wf.Name, s.Name, err)
-						exec.addStep(workflowStepExecution{
-							Timestamp: time.Now(),
-							Workflow:  wf.Name,
-							Step:      s.Name,
-							Target:    t.Config.Name,
-							RPC:       rpc,
-							Error:     err,
-						})
-						return
-					}
-				}
-			}()
-			for _, req := range reqs {
-				switch req := req.ProtoReflect().Interface().(type) {
-				case *spb.ModifyRequest:
-					a.Logger.Infof("workflow=%q: target=%q: step=%s: %T: %v", wf.Name, t.Config.Name, s.Name, req, req)
-					exec.addStep(workflowStepExecution{
-						Timestamp: time.Now(),
-						Workflow:  wf.Name,
-						Step:      s.Name,
-						Target:    t.Config.Name,
-						RPC:       rpc,
-						Request:   req,
-					})
-					reqCh <- req
+			if len(req.GetOperation()) == 0 {
+				exec.sessionReqs = append(exec.sessionReqs, req)
+			}
+		default:
+			return fmt.Errorf("workflow=%q: unexpected request type: expected ModifyRequest, got %T", wf.Name, req)
+		}
+	}
+	return nil
+}
+
+// openWorkflowModifyStream creates a modify stream bound to ctx and
+// replays the session parameters and election ID requests sent
+// on a previous stream, if any.
+func (a *App) openWorkflowModifyStream(ctx context.Context, t *target, exec *execution, s *config.Step) error {
+	mctx, cancel := context.WithCancel(ctx)
+	var err error
+	t.modClient, err = t.gRIBIClient.Modify(mctx)
+	if err != nil {
+		cancel()
+		t.modClient = nil
+		return err
+	}
+	t.modifyCfn = cancel
+	for _, req := range exec.sessionReqs {
+		a.Logger.Infof("workflow=%q: target=%q: step=%s: replaying session request: %v", exec.wf.Name, t.Config.Name, s.Name, req)
+		err = a.sendWorkflowModifyRequest(ctx, t, exec, s, req)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeWorkflowModifyStream closes the target modify stream,
+// the next modify step opens a new one.
+func (t *target) closeWorkflowModifyStream() {
+	if t.modifyCfn != nil {
+		t.modifyCfn()
+	}
+	t.modClient = nil
+}
+
+// sendWorkflowModifyRequest sends a single modify request and waits for
+// all its operations to be acknowledged or for ctx to be done.
+// With a RIB_AND_FIB_ACK session, it waits for the operations FIB result.
+func (a *App) sendWorkflowModifyRequest(ctx context.Context, t *target, exec *execution, s *config.Step, req *spb.ModifyRequest) error {
+	wf := exec.wf
+	exec.addPending(req)
+	reqCh := make(chan *spb.ModifyRequest, 1)
+	reqCh <- req
+	close(reqCh)
+	timeout, stop := ackTimer(t)
+	defer stop()
+	rspCh, errCh := a.modifyChan(ctx, t, reqCh, exec.fibAck())
+	var failed error
+	for {
+		select {
+		case <-ctx.Done():
+			// the stream state is unknown after a missing acknowledgment
+			t.closeWorkflowModifyStream()
+			return ctx.Err()
+		case <-timeout:
+			t.closeWorkflowModifyStream()
+			return ackTimeoutError(t)
+		case rsp, ok := <-rspCh:
+			if !ok {
+				select {
+				case err := <-errCh:
+					t.closeWorkflowModifyStream()
+					return err
 				default:
-					err = fmt.Errorf("workflow=%q: unexpected request type: expected ModifyRequest, got %T", wf.Name, req)
-					exec.addStep(workflowStepExecution{
-						Timestamp: time.Now(),
-						Workflow:  wf.Name,
-						Step:      s.Name,
-						Target:    t.Config.Name,
-						RPC:       rpc,
-						Error:     err,
-					})
-					return exec, err
+					return failed
 				}
 			}
-			<-doneCh
+			a.Logger.Infof("workflow=%q: target=%q: step=%s: %T: %v", wf.Name, t.Config.Name, s.Name, rsp, rsp)
+			exec.addStep(workflowStepExecution{
+				Timestamp: time.Now(),
+				Workflow:  wf.Name,
+				Step:      s.Name,
+				Target:    t.Config.Name,
+				RPC:       "modify",
+				Response:  rsp,
+			})
+			err := exec.processModifyResponse(rsp)
+			if err != nil && failed == nil {
+				failed = err
+			}
+		case err := <-errCh:
+			t.closeWorkflowModifyStream()
+			return err
+		}
+	}
+}
+
+// rollsBack returns true if a step of the workflow running on the target
+// has the rollback failure policy.
+func rollsBack(wf *config.Workflow, tc *config.TargetConfig) bool {
+	for _, s := range wf.Steps {
+		if s.OnFailure == config.OnFailureRollback && wf.RunsOn(s, tc) {
+			return true
 		}
+	}
+	return false
+}
 
-		// wait duration if any
-		a.Logger.Infof("workflow=%q: target=%q: step=%s: waiting %s after execution", wf.Name, t.Config.Name, s.Name, s.WaitAfter)
-		time.Sleep(s.WaitAfter)
+// getRollbackSnapshot gets all the AFT entries of the target, in all network instances,
+// before the workflow runs.
+func (a *App) getRollbackSnapshot(ctx context.Context, t *target) (aftSnapshot, error) {
+	req, err := api.NewGetRequest(api.NSAll(), api.AFTTypeAll())
+	if err != nil {
+		return nil, err
 	}
-	return exec, nil
+	rsp, err := a.get(ctx, t, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newAFTSnapshot(rsp.GetEntry()...), nil
 }
 
+// rollbackWorkflow reverts the entries added by the workflow so far, in reverse order:
+// the entries that existed before the workflow are restored, the others are deleted.
+// Replaced and deleted entries cannot be restored.
+func (a *App) rollbackWorkflow(ctx context.Context, t *target, exec *execution, s *config.Step) error {
+	wf := exec.wf
+	ops := exec.rollbackOperations()
+	if exec.notRevertible > 0 {
+		a.Logger.Warnf("workflow=%q: target=%q: step=%s: %d replace/delete operation(s) cannot be rolled back",
+			wf.Name, t.Config.Name, s.Name, exec.notRevertible)
+	}
+	if len(ops) == 0 {
+		a.Logger.Infof("workflow=%q: target=%q: step=%s: nothing to rollback", wf.Name, t.Config.Name, s.Name)
+		return nil
+	}
+	a.Logger.Infof("workflow=%q: target=%q: step=%s: rolling back %d operation(s)", wf.Name, t.Config.Name, s.Name, len(ops))
+	// the rollback runs even if the workflow context is done,
+	// it is bound by the step timeout or the target timeout.
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = t.Config.Timeout
+	}
+	rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	if t.modClient == nil || ctx.Err() != nil {
+		t.closeWorkflowModifyStream()
+		err := a.openWorkflowModifyStream(rctx, t, exec, s)
+		if err != nil {
+			return fmt.Errorf("failed creating modify stream: %v", err)
+		}
+	}
+	for _, op := range ops {
+		req := &spb.ModifyRequest{Operation: []*spb.AFTOperation{op}}
+		exec.addStep(workflowStepExecution{
+			Timestamp: time.Now(),
+			Workflow:  wf.Name,
+			Step:      s.Name,
+			Target:    t.Config.Name,
+			RPC:       "modify",
+			Status:    stepStatusRollback,
+			Request:   req,
+		})
+		err := a.sendWorkflowModifyRequest(rctx, t, exec, s, req)
+		if err != nil {
+			return err
+		}
+	}
+	exec.addStep(workflowStepExecution{
+		Timestamp: time.Now(),
+		Workflow:  wf.Name,
+		Step:      s.Name,
+		Target:    t.Config.Name,
+		RPC:       "modify",
+		Status:    stepStatusRolledBack,
+	})
+	return nil
+}
+
+func isTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded
+}
+
+const (
+	stepStatusFailed         = "failed"
+	stepStatusTimeout        = "timeout"
+	stepStatusRollback       = "rollback"
+	stepStatusRolledBack     = "rolled-back"
+	stepStatusRollbackFailed = "rollback-failed"
+)
+
 type workflowStepExecution struct {
 	Timestamp time.Time     `json:"timestamp,omitempty"`
 	Workflow  string        `json:"workflow,omitempty"`
 	Step      string        `json:"step,omitempty"`
 	Target    string        `json:"target,omitempty"`
 	RPC       string        `json:"rpc,omitempty"`
+	Status    string        `json:"status,omitempty"`
 	Request   proto.Message `json:"request,omitempty"`
 	Response  proto.Message `json:"response,omitempty"`
+	Output    string        `json:"output,omitempty"`
 	Error     error         `json:"error,omitempty"`
 }
 
+func (wse workflowStepExecution) MarshalJSON() ([]byte, error) {
+	type alias workflowStepExecution
+	var errMsg string
+	if wse.Error != nil {
+		errMsg = wse.Error.Error()
+	}
+	return json.Marshal(struct {
+		alias
+		Error string `json:"error,omitempty"`
+	}{
+		alias: alias(wse),
+		Error: errMsg,
+	})
+}
+
 type execution struct {
 	wf     *config.Workflow
 	m      *sync.Mutex
 	result []workflowStepExecution
+	// modify operations sent and waiting for a result
+	pending map[uint64]*spb.AFTOperation
+	// ADD operations successfully programmed, in order
+	applied []*spb.AFTOperation
+	// entries present before the workflow, set if it rolls back on failure
+	before aftSnapshot
+	// number of programmed REPLACE and DELETE operations
+	notRevertible int
+	// highest operation ID sent
+	lastID uint64
+	// session parameters and election ID requests
+	sessionReqs []*spb.ModifyRequest
+	// set if the session ack type is RIB_AND_FIB_ACK
+	ribFibAck bool
+	// barriers shared with the other targets
+	bs *barriers
+	// number of times each barrier was reached
+	barrierCount map[string]int
+	// values captured by the steps, referenced by print steps
+	captures map[string]interface{}
 }
 
-func newExec(wf *config.Workflow) *execution {
+func newExec(wf *config.Workflow, bs *barriers) *execution {
 	return &execution{
-		wf:     wf,
-		m:      &sync.Mutex{},
-		result: []workflowStepExecution{},
+		wf:           wf,
+		m:            &sync.Mutex{},
+		result:       []workflowStepExecution{},
+		pending:      make(map[uint64]*spb.AFTOperation),
+		bs:           bs,
+		barrierCount: make(map[string]int),
+		captures:     make(map[string]interface{}),
+	}
+}
+
+// keepSessionRequests stores the session parameters and election ID
+// requests of a skipped modify step.
+func (e *execution) keepSessionRequests(s *config.Step) error {
+	if s.Kind() != "modify" {
+		return nil
 	}
+	reqs, err := s.BuildRequests()
+	if err != nil {
+		return err
+	}
+	for _, req := range reqs {
+		if req, ok := req.(*spb.ModifyRequest); ok && len(req.GetOperation()) == 0 {
+			e.sessionReqs = append(e.sessionReqs, req)
+		}
+	}
+	return nil
+}
+
+func (e *execution) setCapture(name string, v interface{}) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	e.captures[name] = v
 }
 
 func (e *execution) addStep(wse workflowStepExecution) {
@@ -377,6 +760,99 @@ func (e *execution) addStep(wse workflowStepExecution) {
 	e.result = append(e.result, wse)
 }
 
+func (e *execution) addPending(req *spb.ModifyRequest) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	if req.GetParams() != nil {
+		e.ribFibAck = req.GetParams().GetAckType() == spb.SessionParameters_RIB_AND_FIB_ACK
+	}
+	for _, op := range req.GetOperation() {
+		e.pending[op.GetId()] = op
+		if op.GetId() > e.lastID {
+			e.lastID = op.GetId()
+		}
+	}
+}
+
+func (e *execution) fibAck() bool {
+	e.m.Lock()
+	defer e.m.Unlock()
+	return e.ribFibAck
+}
+
+// processModifyResponse keeps track of the programmed operations
+// and returns an error if any of the operations failed.
+func (e *execution) processModifyResponse(rsp *spb.ModifyResponse) error {
+	e.m.Lock()
+	defer e.m.Unlock()
+	var err error
+	for _, res := range rsp.GetResult() {
+		switch res.GetStatus() {
+		case spb.AFTResult_FAILED, spb.AFTResult_FIB_FAILED:
+			delete(e.pending, res.GetId())
+			if err == nil {
+				err = fmt.Errorf("operation ID %d failed: %s", res.GetId(), res.GetStatus())
+			}
+		case spb.AFTResult_RIB_PROGRAMMED, spb.AFTResult_FIB_PROGRAMMED:
+			op, ok := e.pending[res.GetId()]
+			if !ok {
+				continue
+			}
+			delete(e.pending, res.GetId())
+			switch op.GetOp() {
+			case spb.AFTOperation_ADD:
+				e.applied = append(e.applied, op)
+			default:
+				e.notRevertible++
+			}
+		}
+	}
+	return err
+}
+
+// rollbackOperations returns the operations reverting the programmed
+// ADD operations, in reverse order.
+// An entry present in the before snapshot is restored with an ADD of its previous content,
+// an entry added by the workflow is deleted.
+// An entry added several times is reverted once, at the position of its first ADD.
+// The returned operations are removed from the applied list.
+func (e *execution) rollbackOperations() []*spb.AFTOperation {
+	e.m.Lock()
+	defer e.m.Unlock()
+	first := make(map[string]int, len(e.applied))
+	for i, op := range e.applied {
+		k := aftEntryKey(aftOperationEntry(op))
+		if _, ok := first[k]; !ok {
+			first[k] = i
+		}
+	}
+	ops := make([]*spb.AFTOperation, 0, len(first))
+	for i := len(e.applied) - 1; i >= 0; i-- {
+		k := aftEntryKey(aftOperationEntry(e.applied[i]))
+		if first[k] != i {
+			continue
+		}
+		e.lastID++
+		var op *spb.AFTOperation
+		if prev, ok := e.before[k]; ok {
+			var err error
+			op, err = aftEntryOperation(e.lastID, spb.AFTOperation_ADD, prev)
+			if err != nil {
+				// not reached, the entry has the type of a programmed operation
+				continue
+			}
+			op.ElectionId = e.applied[i].GetElectionId()
+		} else {
+			op = proto.Clone(e.applied[i]).(*spb.AFTOperation)
+			op.Id = e.lastID
+			op.Op = spb.AFTOperation_DELETE
+		}
+		ops = append(ops, op)
+	}
+	e.applied = e.applied[:0]
+	return ops
+}
+
 func (e *execution) String() string {
 	b, _ := json.MarshalIndent(e.result, "", "  ")
 	return string(b)
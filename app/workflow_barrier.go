@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"sync"
+)
+
+// barriers synchronizes the workflow executions of multiple targets.
+// A barrier is released when all the targets still running the workflow reached it.
+type barriers struct {
+	m       *sync.Mutex
+	parties int
+	states  map[string]*barrierState
+}
+
+type barrierState struct {
+	arrived  int
+	released bool
+	ch       chan struct{}
+}
+
+func newBarriers(parties int) *barriers {
+	return &barriers{
+		m:       new(sync.Mutex),
+		parties: parties,
+		states:  make(map[string]*barrierState),
+	}
+}
+
+// wait blocks until all the remaining parties reached the barrier key,
+// or until ctx is done.
+func (b *barriers) wait(ctx context.Context, key string) error {
+	b.m.Lock()
+	bs, ok := b.states[key]
+	if !ok {
+		bs = &barrierState{ch: make(chan struct{})}
+		b.states[key] = bs
+	}
+	bs.arrived++
+	b.release()
+	b.m.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-bs.ch:
+		return nil
+	}
+}
+
+// leave removes a party from the barriers,
+// it's called when a target workflow execution ends.
+func (b *barriers) leave() {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.parties--
+	b.release()
+}
+
+// release must be called with the lock held.
+func (b *barriers) release() {
+	for _, bs := range b.states {
+		if !bs.released && bs.arrived >= b.parties {
+			bs.released = true
+			close(bs.ch)
+		}
+	}
+}
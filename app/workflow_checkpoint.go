@@ -0,0 +1,122 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// checkpoint records the workflow steps completed on each target,
+// it is saved after each completed step and used by --resume
+// to continue a previous run.
+type checkpoint struct {
+	m    *sync.Mutex
+	file string
+
+	Targets map[string]*targetCheckpoint `json:"targets,omitempty"`
+}
+
+type targetCheckpoint struct {
+	Workflow string `json:"workflow,omitempty"`
+	// digest of the rendered workflow
+	Digest    string    `json:"digest,omitempty"`
+	Updated   time.Time `json:"updated,omitempty"`
+	Completed []string  `json:"completed,omitempty"`
+}
+
+func newCheckpoint(file string) *checkpoint {
+	return &checkpoint{
+		m:       new(sync.Mutex),
+		file:    file,
+		Targets: make(map[string]*targetCheckpoint),
+	}
+}
+
+func loadCheckpoint(file string) (*checkpoint, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint file: %w", err)
+	}
+	cp := newCheckpoint(file)
+	err = json.Unmarshal(b, cp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint file %q: %w", file, err)
+	}
+	if cp.Targets == nil {
+		cp.Targets = make(map[string]*targetCheckpoint)
+	}
+	return cp, nil
+}
+
+// start returns the steps already completed on the target if resume is true,
+// the rendered workflow digest must match the recorded one.
+// Otherwise, it resets the target's completed steps.
+// A nil checkpoint records nothing.
+func (c *checkpoint) start(target, wfName, digest string, resume bool) (map[string]struct{}, error) {
+	if c == nil {
+		return make(map[string]struct{}), nil
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	completed := make(map[string]struct{})
+	tc, ok := c.Targets[target]
+	if resume && ok {
+		if tc.Workflow != wfName || tc.Digest != digest {
+			return nil, fmt.Errorf("workflow %q changed since checkpoint %q was written", wfName, c.file)
+		}
+		for _, name := range tc.Completed {
+			completed[name] = struct{}{}
+		}
+		return completed, nil
+	}
+	c.Targets[target] = &targetCheckpoint{
+		Workflow:  wfName,
+		Digest:    digest,
+		Updated:   time.Now(),
+		Completed: []string{},
+	}
+	return completed, c.save()
+}
+
+// complete records step as completed on the target and saves the checkpoint.
+func (c *checkpoint) complete(target, step string) error {
+	if c == nil {
+		return nil
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	tc, ok := c.Targets[target]
+	if !ok {
+		return fmt.Errorf("unknown checkpoint target %q", target)
+	}
+	tc.Completed = append(tc.Completed, step)
+	tc.Updated = time.Now()
+	return c.save()
+}
+
+// save writes the checkpoint to a temporary file then renames it,
+// so that an interrupted run does not leave a truncated file.
+func (c *checkpoint) save() error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.file), filepath.Base(c.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.file)
+}
@@ -0,0 +1,233 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	osexec "os/exec"
+	"strings"
+	"time"
+
+	"github.com/karimra/gribic/config"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"google.golang.org/protobuf/proto"
+)
+
+const defaultWaitForInterval = time.Second
+
+func (a *App) runPrintStep(t *target, exec *execution, s *config.Step) error {
+	out, err := a.Config.RenderStepTemplate(s, t.Config, exec.captures)
+	if err != nil {
+		return err
+	}
+	exec.addStep(workflowStepExecution{
+		Timestamp: time.Now(),
+		Workflow:  exec.wf.Name,
+		Step:      s.Name,
+		Target:    t.Config.Name,
+		RPC:       s.Kind(),
+		Output:    out,
+	})
+	a.pm.Lock()
+	fmt.Println(strings.TrimRight(out, "\n"))
+	a.pm.Unlock()
+	return nil
+}
+
+// runExecStep runs the step's command locally,
+// the target name and address are passed to the command as environment variables.
+func (a *App) runExecStep(ctx context.Context, t *target, exec *execution, s *config.Step) error {
+	wf := exec.wf
+	a.Logger.Infof("workflow=%q: target=%q: step=%s: running %q %v", wf.Name, t.Config.Name, s.Name, s.Exec.Command, s.Exec.Args)
+	cmd := osexec.CommandContext(ctx, s.Exec.Command, s.Exec.Args...)
+	cmd.Env = append(os.Environ(),
+		"GRIBIC_TARGET_NAME="+t.Config.Name,
+		"GRIBIC_TARGET_ADDRESS="+t.Config.Address,
+	)
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if stderr.Len() > 0 {
+		a.Logger.Warnf("workflow=%q: target=%q: step=%s: stderr: %s", wf.Name, t.Config.Name, s.Name, stderr.String())
+	}
+	exec.addStep(workflowStepExecution{
+		Timestamp: time.Now(),
+		Workflow:  wf.Name,
+		Step:      s.Name,
+		Target:    t.Config.Name,
+		RPC:       s.Kind(),
+		Output:    stdout.String(),
+		Error:     err,
+	})
+	if err != nil {
+		return fmt.Errorf("command %q failed: %w", s.Exec.Command, err)
+	}
+	a.Logger.Debugf("workflow=%q: target=%q: step=%s: output: %s", wf.Name, t.Config.Name, s.Name, stdout.String())
+	if s.Capture != "" {
+		exec.setCapture(s.Capture, strings.TrimRight(stdout.String(), "\n"))
+	}
+	return nil
+}
+
+// runVerifyStep runs a Get RPC and checks the returned entries against the step condition.
+func (a *App) runVerifyStep(ctx context.Context, t *target, exec *execution, s *config.Step, reqs []proto.Message) error {
+	expected, err := a.stepExpectedEntries(t, s)
+	if err != nil {
+		return err
+	}
+	problems, err := a.checkCondition(ctx, t, exec, s, reqs, expected)
+	if err != nil {
+		return err
+	}
+	return a.recordCondition(t, exec, s, expected, problems)
+}
+
+// runWaitForStep runs a Get RPC periodically until the step condition holds,
+// it is bounded by the step timeout.
+func (a *App) runWaitForStep(ctx context.Context, t *target, exec *execution, s *config.Step, reqs []proto.Message) error {
+	expected, err := a.stepExpectedEntries(t, s)
+	if err != nil {
+		return err
+	}
+	interval := s.WaitFor.Interval
+	if interval <= 0 {
+		interval = defaultWaitForInterval
+	}
+	for {
+		problems, err := a.checkCondition(ctx, t, exec, s, reqs, expected)
+		if err != nil {
+			return err
+		}
+		if len(problems) == 0 {
+			return a.recordCondition(t, exec, s, expected, nil)
+		}
+		a.Logger.Debugf("workflow=%q: target=%q: step=%s: condition not met: %s",
+			exec.wf.Name, t.Config.Name, s.Name, strings.Join(problems, "; "))
+		err = sleep(ctx, interval)
+		if err != nil {
+			return fmt.Errorf("%w: condition not met: %s", err, strings.Join(problems, "; "))
+		}
+	}
+}
+
+func (a *App) stepExpectedEntries(t *target, s *config.Step) ([]*spb.AFTEntry, error) {
+	defaultNI := s.NetworkInstance
+	if defaultNI == "" {
+		defaultNI = t.Config.DefaultNI
+	}
+	return s.Condition().ExpectedEntries(defaultNI)
+}
+
+// checkCondition runs the step Get request(s) and returns the list of
+// differences between the returned entries and the step condition.
+func (a *App) checkCondition(ctx context.Context, t *target, exec *execution, s *config.Step, reqs []proto.Message, expected []*spb.AFTEntry) ([]string, error) {
+	entries := make([]*spb.AFTEntry, 0)
+	for _, req := range reqs {
+		req, ok := req.(*spb.GetRequest)
+		if !ok {
+			return nil, fmt.Errorf("workflow=%q: unexpected request type: expected GetRequest, got %T", exec.wf.Name, req)
+		}
+		rspEntries, err := a.getEntries(ctx, t, req)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, rspEntries...)
+	}
+	v := s.Condition()
+	return checkEntries(expected, entries, v.Exact, v.Absent, v.Count), nil
+}
+
+func (a *App) recordCondition(t *target, exec *execution, s *config.Step, expected []*spb.AFTEntry, problems []string) error {
+	var err error
+	output := fmt.Sprintf("condition met: %d expected entries", len(expected))
+	if len(problems) > 0 {
+		err = fmt.Errorf("condition not met: %s", strings.Join(problems, "; "))
+		output = ""
+	}
+	exec.addStep(workflowStepExecution{
+		Timestamp: time.Now(),
+		Workflow:  exec.wf.Name,
+		Step:      s.Name,
+		Target:    t.Config.Name,
+		RPC:       s.Kind(),
+		Output:    output,
+		Error:     err,
+	})
+	if err == nil {
+		a.Logger.Infof("workflow=%q: target=%q: step=%s: %s", exec.wf.Name, t.Config.Name, s.Name, output)
+	}
+	return err
+}
+
+// getEntries runs a Get RPC and returns all the received entries.
+func (a *App) getEntries(ctx context.Context, t *target, req *spb.GetRequest) ([]*spb.AFTEntry, error) {
+	entries := make([]*spb.AFTEntry, 0)
+	rspCh, errCh := a.getChan(ctx, t, req)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case rsp, ok := <-rspCh:
+			if !ok {
+				rspCh = nil
+				continue
+			}
+			entries = append(entries, rsp.GetEntry()...)
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			if err == io.EOF {
+				return entries, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+// checkEntries compares the entries returned by a Get RPC with the expected ones,
+// it returns a description of each difference.
+// If absent is true, the expected entries must not be present,
+// if exact is true, entries that are not expected are reported
+// and if count is not nil, the number of entries must match it.
+func checkEntries(expected, entries []*spb.AFTEntry, exact, absent bool, count *int) []string {
+	problems := make([]string, 0)
+	got := make(map[string]*spb.AFTEntry, len(entries))
+	for _, e := range entries {
+		got[aftEntryKey(e)] = e
+	}
+	want := make(map[string]struct{}, len(expected))
+	for _, e := range expected {
+		k := aftEntryKey(e)
+		want[k] = struct{}{}
+		ge, ok := got[k]
+		switch {
+		case absent && ok:
+			problems = append(problems, fmt.Sprintf("unexpected entry %s", k))
+		case absent:
+		case !ok:
+			problems = append(problems, fmt.Sprintf("missing entry %s", k))
+		case !protoContains(e.ProtoReflect(), ge.ProtoReflect()):
+			problems = append(problems, fmt.Sprintf("entry %s mismatch: got %v", k, ge))
+		}
+	}
+	if exact {
+		for _, e := range entries {
+			k := aftEntryKey(e)
+			if _, ok := want[k]; !ok {
+				problems = append(problems, fmt.Sprintf("unexpected entry %s", k))
+			}
+		}
+	}
+	if count != nil && *count != len(entries) {
+		problems = append(problems, fmt.Sprintf("got %d entries, expected %d", len(entries), *count))
+	}
+	return problems
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"testing"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+func testOperation(t *testing.T, id uint64, op spb.AFTOperation_Operation, e *spb.AFTEntry) *spb.AFTOperation {
+	t.Helper()
+	aftOp, err := aftEntryOperation(id, op, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aftOp
+}
+
+func TestExecution_rollbackOperations(t *testing.T) {
+	nh1 := nhEntry(t, "DEFAULT", 1, "192.168.1.1")
+	nh1New := nhEntry(t, "DEFAULT", 1, "192.168.1.2")
+	nh2 := nhEntry(t, "DEFAULT", 2, "192.168.2.1")
+	nh2New := nhEntry(t, "DEFAULT", 2, "192.168.2.2")
+	nhg1 := nhgEntry(t, "DEFAULT", 1, 1, 2)
+	route := ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 10)
+	routeNew := ipv4Entry(t, "DEFAULT", "10.0.0.0/24", 1)
+	applied := func() []*spb.AFTOperation {
+		return []*spb.AFTOperation{
+			testOperation(t, 1, spb.AFTOperation_ADD, nh1New),
+			testOperation(t, 2, spb.AFTOperation_ADD, nh2),
+			testOperation(t, 3, spb.AFTOperation_ADD, nhg1),
+			testOperation(t, 4, spb.AFTOperation_ADD, routeNew),
+			testOperation(t, 5, spb.AFTOperation_ADD, nh2New),
+		}
+	}
+	tests := []struct {
+		name   string
+		before aftSnapshot
+		want   []*spb.AFTOperation
+	}{
+		{
+			name: "no_snapshot",
+			want: []*spb.AFTOperation{
+				testOperation(t, 6, spb.AFTOperation_DELETE, routeNew),
+				testOperation(t, 7, spb.AFTOperation_DELETE, nhg1),
+				testOperation(t, 8, spb.AFTOperation_DELETE, nh2),
+				testOperation(t, 9, spb.AFTOperation_DELETE, nh1New),
+			},
+		},
+		{
+			name:   "restore_existing_entries",
+			before: newAFTSnapshot(nh1, route),
+			want: []*spb.AFTOperation{
+				testOperation(t, 6, spb.AFTOperation_ADD, route),
+				testOperation(t, 7, spb.AFTOperation_DELETE, nhg1),
+				testOperation(t, 8, spb.AFTOperation_DELETE, nh2),
+				testOperation(t, 9, spb.AFTOperation_ADD, nh1),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newExec(nil, nil)
+			e.applied = applied()
+			e.lastID = 5
+			e.before = tt.before
+			got := e.rollbackOperations()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d operations, want %d: %v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if !proto.Equal(got[i], tt.want[i]) {
+					t.Errorf("operation %d:\ngot  %s\nwant %s", i, prototext.Format(got[i]), prototext.Format(tt.want[i]))
+				}
+			}
+			if len(e.applied) != 0 {
+				t.Errorf("applied operations not cleared: %v", e.applied)
+			}
+		})
+	}
+}
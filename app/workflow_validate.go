@@ -0,0 +1,142 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/karimra/gribic/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+func (a *App) InitWorkflowValidateFlags(cmd *cobra.Command) {
+	cmd.ResetFlags()
+	//
+	cmd.Flags().StringVarP(&a.Config.WorkflowFile, "file", "", "", "workflow file")
+	//
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		a.Config.FileConfig.BindPFlag(fmt.Sprintf("%s-%s", cmd.Name(), flag.Name), flag)
+	})
+}
+
+// WorkflowValidateRunE renders the workflow for each configured target,
+// validates its steps and prints the execution plan without connecting to the targets.
+func (a *App) WorkflowValidateRunE(cmd *cobra.Command, args []string) error {
+	targetsConfigs, err := a.Config.GetTargets()
+	if err != nil {
+		return err
+	}
+	targetNames := make([]string, 0, len(targetsConfigs))
+	for n := range targetsConfigs {
+		targetNames = append(targetNames, n)
+	}
+	sort.Strings(targetNames)
+
+	errs := make([]error, 0)
+	for _, name := range targetNames {
+		wf, err := a.Config.GenerateWorkflow(targetsConfigs[name])
+		if err != nil {
+			wErr := fmt.Errorf("target=%q: failed to generate workflow: %v", name, err)
+			a.Logger.Error(wErr)
+			errs = append(errs, wErr)
+			continue
+		}
+		wf.SetDefaults()
+		wfErrs := wf.Validate()
+		wfErrs = append(wfErrs, validateStepTargets(wf, targetsConfigs)...)
+		for _, err := range wfErrs {
+			wErr := fmt.Errorf("target=%q: workflow=%q: %v", name, wf.Name, err)
+			a.Logger.Error(wErr)
+			errs = append(errs, wErr)
+		}
+		fmt.Println(workflowPlan(targetsConfigs[name], wf))
+	}
+	if len(errs) > 0 {
+		return a.handleErrs(errs)
+	}
+	a.Logger.Infof("workflow file %q is valid for %d target(s)", a.Config.WorkflowFile, len(targetNames))
+	return nil
+}
+
+// validateStepTargets returns an error for each step with a target
+// that does not match any of the configured targets.
+func validateStepTargets(wf *config.Workflow, tcs map[string]*config.TargetConfig) []error {
+	errs := make([]error, 0)
+	for i, s := range wf.Steps {
+		if s.Target == "" {
+			continue
+		}
+		var found bool
+		for _, tc := range tcs {
+			if wf.RunsOn(s, tc) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("step %d %q: target %q does not match any target or group", i+1, s.Name, s.Target))
+		}
+	}
+	return errs
+}
+
+// workflowPlan returns a text representation of the workflow steps
+// and the requests they resolve to for the given target.
+func workflowPlan(tc *config.TargetConfig, wf *config.Workflow) string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "target=%q: workflow=%q: %d step(s)\n", tc.Name, wf.Name, len(wf.Steps))
+	for i, s := range wf.Steps {
+		fmt.Fprintf(sb, "  step %d %q: %s", i+1, s.Name, s.Kind())
+		if s.Barrier != "" {
+			fmt.Fprintf(sb, " %q", s.Barrier)
+		}
+		if s.Target != "" {
+			fmt.Fprintf(sb, " target=%s", s.Target)
+		}
+		fmt.Fprintf(sb, " wait=%s wait-after=%s timeout=%s on-failure=%s\n",
+			s.Wait, s.WaitAfter, s.Timeout, s.OnFailure)
+		if !wf.RunsOn(s, tc) {
+			fmt.Fprintf(sb, "    skipped on this target\n")
+			continue
+		}
+		switch s.Kind() {
+		case "barrier":
+			continue
+		case "print":
+			fmt.Fprintf(sb, "    template: %q\n", s.Print)
+			continue
+		case "exec":
+			fmt.Fprintf(sb, "    command: %s\n", strings.Join(append([]string{s.Exec.Command}, s.Exec.Args...), " "))
+			continue
+		case "verify", "wait-for":
+			v := s.Condition()
+			fmt.Fprintf(sb, "    expects %d entries: exact=%t absent=%t", len(v.Entries), v.Exact, v.Absent)
+			if v.Count != nil {
+				fmt.Fprintf(sb, " count=%d", *v.Count)
+			}
+			fmt.Fprintln(sb)
+		}
+		reqs, err := s.BuildRequests()
+		if err != nil {
+			fmt.Fprintf(sb, "    invalid: %v\n", err)
+			continue
+		}
+		if len(reqs) == 0 {
+			fmt.Fprintf(sb, "    no requests\n")
+			continue
+		}
+		for _, req := range reqs {
+			fmt.Fprintf(sb, "    %s:\n", req.ProtoReflect().Descriptor().Name())
+			txt := strings.TrimSpace(prototext.Format(req))
+			if txt == "" {
+				continue
+			}
+			for _, l := range strings.Split(txt, "\n") {
+				fmt.Fprintf(sb, "      %s\n", l)
+			}
+		}
+	}
+	return sb.String()
+}
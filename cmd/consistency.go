@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newConsistencyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "consistency",
+		Short: "compare the AFT entries of all the targets",
+		Long: `run a Get RPC on all the targets concurrently and report the entries
+that deviate from the majority of the targets or from a reference target.
+The exit code is 0 if all the targets are consistent, 1 if any target deviates and 2 on failure.`,
+		PreRun: func(cmd *cobra.Command, _ []string) {
+			gApp.Config.SetLocalFlagsFromFile(cmd)
+		},
+		RunE:          gApp.ConsistencyRunE,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Annotations: map[string]string{
+			failureExitCodeAnnotation: "2",
+		},
+	}
+	// init flags
+	gApp.InitConsistencyFlags(cmd)
+	return cmd
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff <a> <b>",
+		Short: "compare the AFT entries of two Get snapshot files, modify input files or targets",
+		Long: `compare the AFT entries of two sources, each one being a Get snapshot file,
+a modify input file or a target name or address.
+The exit code is 0 if there is no difference, 1 if there are differences and 2 on failure.`,
+		PreRun: func(cmd *cobra.Command, _ []string) {
+			gApp.Config.SetLocalFlagsFromFile(cmd)
+		},
+		RunE:          gApp.DiffRunE,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Annotations: map[string]string{
+			failureExitCodeAnnotation: "2",
+		},
+	}
+	// init flags
+	gApp.InitDiffFlags(cmd)
+	return cmd
+}
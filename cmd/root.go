@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 
 	"github.com/karimra/gribic/app"
 	"github.com/spf13/cobra"
@@ -11,6 +14,10 @@ import (
 
 var gApp = app.New()
 
+// failureExitCodeAnnotation is the annotation of the commands that report their result with an exit code,
+// its value is the exit code of a failure, e.g: a flag parsing or validation error.
+const failureExitCodeAnnotation = "failure-exit-code"
+
 func newRootCmd() *cobra.Command {
 	gApp.RootCmd = &cobra.Command{
 		Use:   "gribic",
@@ -24,12 +31,19 @@ func newRootCmd() *cobra.Command {
 	//
 	versionCmd := newVersionCmd()
 	versionCmd.AddCommand(newVersionUpgradeCmd())
+	workflowCmd := newWorkflowCmd()
+	workflowCmd.AddCommand(newWorkflowValidateCmd())
+	varsCmd := newVarsCmd()
+	varsCmd.AddCommand(newVarsShowCmd())
 	gApp.RootCmd.AddCommand(
 		versionCmd,
 		newGetCmd(),
 		newModifyCmd(),
 		newFlushCmd(),
-		newWorkflowCmd(),
+		workflowCmd,
+		newDiffCmd(),
+		newConsistencyCmd(),
+		varsCmd,
 	)
 	return gApp.RootCmd
 }
@@ -37,10 +51,29 @@ func newRootCmd() *cobra.Command {
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := newRootCmd().Execute()
+	cmd, err := newRootCmd().ExecuteC()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(cmd, err, os.Stderr))
+	}
+}
+
+// exitCode returns the exit code of the command cmd that failed with err,
+// it writes err to w if cobra did not print it.
+func exitCode(cmd *cobra.Command, err error, w io.Writer) int {
+	eErr := new(app.ExitError)
+	if errors.As(err, &eErr) {
+		if eErr.Err != nil {
+			fmt.Fprintf(w, "Error: %v\n", eErr.Err)
+		}
+		return eErr.Code
+	}
+	if cmd.SilenceErrors {
+		fmt.Fprintf(w, "Error: %v\n", err)
+	}
+	if code, err := strconv.Atoi(cmd.Annotations[failureExitCodeAnnotation]); err == nil {
+		return code
 	}
+	return 1
 }
 
 func init() {
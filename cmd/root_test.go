@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    int
+		wantErr string
+	}{
+		{
+			name:    "diff_unknown_flag",
+			args:    []string{"diff", "--bogus", "a", "b"},
+			want:    2,
+			wantErr: "unknown flag: --bogus",
+		},
+		{
+			name:    "diff_invalid_global_flag",
+			args:    []string{"diff", "--get-timeout", "-1s", "a", "b"},
+			want:    2,
+			wantErr: "Error: --get-timeout, --flush-timeout and --ack-timeout must be positive",
+		},
+		{
+			name:    "diff_unknown_format",
+			args:    []string{"diff", "--format", "yaml", "a", "b"},
+			want:    2,
+			wantErr: `unknown --format "yaml", the diff command accepts: text or json`,
+		},
+		{
+			name:    "consistency_unknown_retry_code",
+			args:    []string{"consistency", "--retry-codes", "FOO"},
+			want:    2,
+			wantErr: `unknown gRPC status code "FOO"`,
+		},
+		{
+			name:    "consistency_unknown_flag",
+			args:    []string{"consistency", "--bogus"},
+			want:    2,
+			wantErr: "unknown flag: --bogus",
+		},
+		{
+			name: "get_unknown_flag",
+			args: []string{"get", "--bogus"},
+			want: 1,
+		},
+		{
+			name: "get_unknown_format",
+			args: []string{"get", "--format", "json"},
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := newRootCmd()
+			root.SetArgs(tt.args)
+			root.SetOut(io.Discard)
+			root.SetErr(io.Discard)
+			cmd, err := root.ExecuteC()
+			if err == nil {
+				t.Fatalf("%v: expected an error", tt.args)
+			}
+			w := new(bytes.Buffer)
+			if got := exitCode(cmd, err, w); got != tt.want {
+				t.Errorf("exitCode() = %d, want %d", got, tt.want)
+			}
+			if !strings.Contains(w.String(), tt.wantErr) {
+				t.Errorf("exitCode() output = %q, want it to contain %q", w.String(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newVarsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "vars",
+		Short: "manage the template variables",
+	}
+	return cmd
+}
+
+func newVarsShowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "print the merged template variables of the targets",
+		Long: `print the template variables of each target, merged from the template vars file,
+the groups and targets vars files, the GRIBIC_VAR_<KEY> environment variables and the --var flags.`,
+		PreRun: func(cmd *cobra.Command, _ []string) {
+			gApp.Config.SetLocalFlagsFromFile(cmd)
+		},
+		RunE:         gApp.VarsShowRunE,
+		SilenceUsage: true,
+	}
+	// init flags
+	gApp.InitVarsShowFlags(cmd)
+	return cmd
+}
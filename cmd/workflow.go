@@ -23,3 +23,17 @@ func newWorkflowCmd() *cobra.Command {
 	gApp.InitWorkflowFlags(cmd)
 	return cmd
 }
+
+func newWorkflowValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "validate",
+		Aliases:      []string{"lint", "plan"},
+		Short:        "validate a workflow and print its execution plan without connecting to the targets",
+		PreRunE:      gApp.WorkflowPreRunE,
+		RunE:         gApp.WorkflowValidateRunE,
+		SilenceUsage: true,
+	}
+	// init flags
+	gApp.InitWorkflowValidateFlags(cmd)
+	return cmd
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -35,6 +36,12 @@ type Config struct {
 	//
 	workflowTemplate *template.Template
 	workflowVars     map[string]interface{}
+	// targets returned by the loader
+	loadedTargets map[string]*TargetConfig
+	// --data-file content, read by the first lookup
+	templateDataOnce sync.Once
+	templateData     map[string]map[string]interface{}
+	templateDataErr  error
 }
 
 type GlobalFlags struct {
@@ -42,6 +49,11 @@ type GlobalFlags struct {
 	Address       []string      `mapstructure:"address,omitempty" json:"address,omitempty" yaml:"address,omitempty"`
 	Username      string        `mapstructure:"username,omitempty" json:"username,omitempty" yaml:"username,omitempty"`
 	Password      string        `mapstructure:"password,omitempty" json:"password,omitempty" yaml:"password,omitempty"`
+	PasswordFile  string        `mapstructure:"password-file,omitempty" json:"password-file,omitempty" yaml:"password-file,omitempty"`
+	PasswordEnv   string        `mapstructure:"password-env,omitempty" json:"password-env,omitempty" yaml:"password-env,omitempty"`
+	AskPassword   bool          `mapstructure:"ask-password,omitempty" json:"ask-password,omitempty" yaml:"ask-password,omitempty"`
+	Token         string        `mapstructure:"token,omitempty" json:"token,omitempty" yaml:"token,omitempty"`
+	TokenFile     string        `mapstructure:"token-file,omitempty" json:"token-file,omitempty" yaml:"token-file,omitempty"`
 	Port          string        `mapstructure:"port,omitempty" json:"port,omitempty" yaml:"port,omitempty"`
 	Insecure      bool          `mapstructure:"insecure,omitempty" json:"insecure,omitempty" yaml:"insecure,omitempty"`
 	TLSCa         string        `mapstructure:"tls-ca,omitempty" json:"tls-ca,omitempty" yaml:"tls-ca,omitempty"`
@@ -50,7 +62,11 @@ type GlobalFlags struct {
 	TLSMinVersion string        `mapstructure:"tls-min-version,omitempty" json:"tls-min-version,omitempty" yaml:"tls-min-version,omitempty"`
 	TLSMaxVersion string        `mapstructure:"tls-max-version,omitempty" json:"tls-max-version,omitempty" yaml:"tls-max-version,omitempty"`
 	TLSVersion    string        `mapstructure:"tls-version,omitempty" json:"tls-version,omitempty" yaml:"tls-version,omitempty"`
+	TLSServerName string        `mapstructure:"tls-server-name,omitempty" json:"tls-server-name,omitempty" yaml:"tls-server-name,omitempty"`
 	Timeout       time.Duration `mapstructure:"timeout,omitempty" json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	GetTimeout    time.Duration `mapstructure:"get-timeout,omitempty" json:"get-timeout,omitempty" yaml:"get-timeout,omitempty"`
+	FlushTimeout  time.Duration `mapstructure:"flush-timeout,omitempty" json:"flush-timeout,omitempty" yaml:"flush-timeout,omitempty"`
+	AckTimeout    time.Duration `mapstructure:"ack-timeout,omitempty" json:"ack-timeout,omitempty" yaml:"ack-timeout,omitempty"`
 	SkipVerify    bool          `mapstructure:"skip-verify,omitempty" json:"skip-verify,omitempty" yaml:"skip-verify,omitempty"`
 	ProxyFromEnv  bool          `mapstructure:"proxy-from-env,omitempty" json:"proxy-from-env,omitempty" yaml:"proxy-from-env,omitempty"`
 	Gzip          bool          `mapstructure:"gzip,omitempty" json:"gzip,omitempty" yaml:"gzip,omitempty"`
@@ -59,6 +75,29 @@ type GlobalFlags struct {
 	Debug         bool          `mapstructure:"debug,omitempty" json:"debug,omitempty" yaml:"debug,omitempty"`
 	//
 	ElectionID string `mapstructure:"election-id,omitempty" json:"election-id,omitempty" yaml:"election-id,omitempty"`
+	// Flush and Get RPCs retry policy
+	RetryMaxAttempts int           `mapstructure:"retry-max-attempts,omitempty" json:"retry-max-attempts,omitempty" yaml:"retry-max-attempts,omitempty"`
+	RetryBackoff     time.Duration `mapstructure:"retry-backoff,omitempty" json:"retry-backoff,omitempty" yaml:"retry-backoff,omitempty"`
+	RetryMaxBackoff  time.Duration `mapstructure:"retry-max-backoff,omitempty" json:"retry-max-backoff,omitempty" yaml:"retry-max-backoff,omitempty"`
+	RetryCodes       []string      `mapstructure:"retry-codes,omitempty" json:"retry-codes,omitempty" yaml:"retry-codes,omitempty"`
+	// targets selection
+	TargetNames    []string `mapstructure:"target,omitempty" json:"target,omitempty" yaml:"target,omitempty"`
+	TargetGroups   []string `mapstructure:"group,omitempty" json:"group,omitempty" yaml:"group,omitempty"`
+	TargetTags     []string `mapstructure:"tag,omitempty" json:"tag,omitempty" yaml:"tag,omitempty"`
+	ExcludeTargets []string `mapstructure:"exclude-target,omitempty" json:"exclude-target,omitempty" yaml:"exclude-target,omitempty"`
+	ExcludeGroups  []string `mapstructure:"exclude-group,omitempty" json:"exclude-group,omitempty" yaml:"exclude-group,omitempty"`
+	ExcludeTags    []string `mapstructure:"exclude-tag,omitempty" json:"exclude-tag,omitempty" yaml:"exclude-tag,omitempty"`
+	// concurrency and staged rollout
+	MaxConcurrency int           `mapstructure:"max-concurrency,omitempty" json:"max-concurrency,omitempty" yaml:"max-concurrency,omitempty"`
+	Canary         int           `mapstructure:"canary,omitempty" json:"canary,omitempty" yaml:"canary,omitempty"`
+	Batch          int           `mapstructure:"batch,omitempty" json:"batch,omitempty" yaml:"batch,omitempty"`
+	BatchWait      time.Duration `mapstructure:"batch-wait,omitempty" json:"batch-wait,omitempty" yaml:"batch-wait,omitempty"`
+	MaxFailures    int           `mapstructure:"max-failures,omitempty" json:"max-failures,omitempty" yaml:"max-failures,omitempty"`
+	VerifyFile     string        `mapstructure:"verify-file,omitempty" json:"verify-file,omitempty" yaml:"verify-file,omitempty"`
+	// templates
+	DataFile string   `mapstructure:"data-file,omitempty" json:"data-file,omitempty" yaml:"data-file,omitempty"`
+	VarsDir  string   `mapstructure:"vars-dir,omitempty" json:"vars-dir,omitempty" yaml:"vars-dir,omitempty"`
+	Vars     []string `mapstructure:"var,omitempty" json:"var,omitempty" yaml:"var,omitempty"`
 }
 
 type LocalFlags struct {
@@ -67,10 +106,41 @@ type LocalFlags struct {
 	// Get
 	GetNetworkInstance string
 	GetAFT             string
+	GetPrefix          string
+	GetLPM             string
+	GetNHG             uint64
+	GetNH              uint64
+	GetFIBStatus       string
+	GetMetadata        string
+	GetResolve         string
+	GetTree            bool
+	GetOutput          string
+	GetCount           bool
+	GetSummary         bool
+	GetWatch           bool
+	GetInterval        time.Duration
+	// diff
+	DiffNetworkInstance string
+	DiffAFT             string
+	DiffFIBStatus       bool
+	// consistency
+	ConsistencyReference       string
+	ConsistencyNetworkInstance string
+	ConsistencyAFT             string
+	ConsistencyIgnore          []string
+	ConsistencyNormalizeFile   string
+	ConsistencyFIBStatus       bool
 	// flush
 	FlushNetworkInstance    string
 	FlushNetworkInstanceAll bool
 	FlushElectionIDOverride bool
+	FlushAFT                string
+	FlushPrefix             string
+	FlushNHG                uint64
+	FlushNH                 uint64
+	FlushCascade            bool
+	FlushYes                bool
+	FlushForce              bool
 
 	// modify redundancy
 	// ModifySessionRedundancyAllPrimary    bool
@@ -87,6 +157,15 @@ type LocalFlags struct {
 	// workflow
 	WorkflowFile          string
 	WorkflowInputVarsFile string
+	WorkflowTimeout       time.Duration
+	WorkflowStartAt       string
+	WorkflowOnly          []string
+	WorkflowSkip          []string
+	WorkflowResume        bool
+	WorkflowCheckpoint    string
+
+	// vars show
+	VarsShowFile string
 }
 
 func New() *Config {
@@ -100,6 +179,10 @@ func New() *Config {
 		nil,
 		nil,
 		nil,
+		nil,
+		sync.Once{},
+		nil,
+		nil,
 	}
 }
 
@@ -202,8 +285,12 @@ func (c *Config) setFlagValue(cmd *cobra.Command, fName string, val interface{})
 		cmd.Flags().Set(fName, strings.Join(nVal, ","))
 	default:
 		if c.Debug {
+			logVal := val
+			if isSecretFlag(fName) {
+				logVal = maskedSecret
+			}
 			c.logger.Printf("cmd=%s, flagName=%s, valueType=%T, value=%#v",
-				cmd.Name(), fName, val, val)
+				cmd.Name(), fName, val, logVal)
 		}
 		cmd.Flags().Set(fName, fmt.Sprintf("%v", val))
 	}
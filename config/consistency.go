@@ -0,0 +1,35 @@
+package config
+
+import (
+	"gopkg.in/yaml.v2"
+)
+
+// Normalization describes how the entries of a target are normalized
+// before being compared with the entries of the other targets.
+type Normalization struct {
+	// entry field paths to ignore, e.g: next_hop.next_hop.ip_address.
+	// A path matches the field itself and all its sub fields.
+	Ignore []string `yaml:"ignore,omitempty" json:"ignore,omitempty"`
+	// entry field values to replace, e.g: a per target next hop address
+	// replaced with a common name.
+	Replace map[string]string `yaml:"replace,omitempty" json:"replace,omitempty"`
+}
+
+// GetNormalization returns the normalization rules of the target targetName:
+// the --ignore flag paths and the rules of the --normalize file,
+// rendered as a template for the target.
+func (c *Config) GetNormalization(targetName string) (*Normalization, error) {
+	n := new(Normalization)
+	if c.ConsistencyNormalizeFile != "" {
+		b, err := c.renderFileTemplate(c.ConsistencyNormalizeFile, targetName)
+		if err != nil {
+			return nil, err
+		}
+		err = yaml.Unmarshal(b, n)
+		if err != nil {
+			return nil, err
+		}
+	}
+	n.Ignore = append(n.Ignore, c.ConsistencyIgnore...)
+	return n, nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfig_GetNormalization(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"norm.yaml": `
+ignore:
+  - next_hop.next_hop.interface_ref
+replace:
+  {{ index .Vars .TargetName }}: peer
+`,
+		"norm_vars.yaml": `
+router1: 192.168.1.1
+router2: 192.168.2.1
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name       string
+		file       string
+		ignore     []string
+		targetName string
+		want       *Normalization
+	}{
+		{
+			name:       "no_file",
+			ignore:     []string{"next_hop.next_hop.ip_address"},
+			targetName: "router1",
+			want: &Normalization{
+				Ignore: []string{"next_hop.next_hop.ip_address"},
+			},
+		},
+		{
+			name:       "file_router1",
+			file:       "norm.yaml",
+			targetName: "router1",
+			want: &Normalization{
+				Ignore:  []string{"next_hop.next_hop.interface_ref"},
+				Replace: map[string]string{"192.168.1.1": "peer"},
+			},
+		},
+		{
+			name:       "file_and_flag_router2",
+			file:       "norm.yaml",
+			ignore:     []string{"next_hop.next_hop.mac_address"},
+			targetName: "router2",
+			want: &Normalization{
+				Ignore:  []string{"next_hop.next_hop.interface_ref", "next_hop.next_hop.mac_address"},
+				Replace: map[string]string{"192.168.2.1": "peer"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			c.SetLogger()
+			if tt.file != "" {
+				c.ConsistencyNormalizeFile = filepath.Join(dir, tt.file)
+			}
+			c.ConsistencyIgnore = tt.ignore
+			got, err := c.GetNormalization(tt.targetName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const maskedSecret = "****"
+
+// ResolveCredentials sets the global password from --password-file or --password-env
+// and the global token from --token-file, unless set with --password and --token.
+func (c *Config) ResolveCredentials() error {
+	var err error
+	if c.Password == "" {
+		c.Password, err = readSecret(c.PasswordFile, c.PasswordEnv)
+		if err != nil {
+			return fmt.Errorf("password: %v", err)
+		}
+	}
+	if c.Token == "" {
+		c.Token, err = readSecret(c.TokenFile, "")
+		if err != nil {
+			return fmt.Errorf("token: %v", err)
+		}
+	}
+	return nil
+}
+
+// resolveCredentials sets the target password from its password-file or password-env
+// and its token from its token-file, unless set with password and token.
+// The targets without any of them use the global values.
+func (tc *TargetConfig) resolveCredentials() error {
+	if tc.Password == nil && (tc.PasswordFile != "" || tc.PasswordEnv != "") {
+		p, err := readSecret(tc.PasswordFile, tc.PasswordEnv)
+		if err != nil {
+			return fmt.Errorf("password: %v", err)
+		}
+		tc.Password = &p
+	}
+	if tc.Token == nil && tc.TokenFile != "" {
+		t, err := readSecret(tc.TokenFile, "")
+		if err != nil {
+			return fmt.Errorf("token: %v", err)
+		}
+		tc.Token = &t
+	}
+	return nil
+}
+
+// readSecret returns the content of file without its trailing new line if file is set,
+// otherwise the value of the environment variable env if set.
+func readSecret(file, env string) (string, error) {
+	if file != "" {
+		b, err := os.ReadFile(os.ExpandEnv(file))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	if env != "" {
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %q is not set", env)
+		}
+		return v, nil
+	}
+	return "", nil
+}
+
+func maskSecret(s *string) *string {
+	if s == nil || *s == "" {
+		return s
+	}
+	m := maskedSecret
+	return &m
+}
+
+func isSecretFlag(name string) bool {
+	switch name {
+	case "password", "token":
+		return true
+	}
+	return false
+}
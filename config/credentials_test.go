@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTargetConfig_resolveCredentials(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("filepw\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("tok\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GRIBIC_TEST_PASSWORD", "envpw")
+
+	tests := []struct {
+		name         string
+		tc           *TargetConfig
+		wantPassword *string
+		wantToken    *string
+		wantErr      bool
+	}{
+		{
+			name: "none",
+			tc:   &TargetConfig{},
+		},
+		{
+			name:         "password_takes_precedence",
+			tc:           &TargetConfig{Password: strPtr("pw"), PasswordFile: passwordFile},
+			wantPassword: strPtr("pw"),
+		},
+		{
+			name:         "password_file_takes_precedence",
+			tc:           &TargetConfig{PasswordFile: passwordFile, PasswordEnv: "GRIBIC_TEST_PASSWORD"},
+			wantPassword: strPtr("filepw"),
+		},
+		{
+			name:         "password_env",
+			tc:           &TargetConfig{PasswordEnv: "GRIBIC_TEST_PASSWORD"},
+			wantPassword: strPtr("envpw"),
+		},
+		{
+			name:    "password_env_not_set",
+			tc:      &TargetConfig{PasswordEnv: "GRIBIC_TEST_UNSET"},
+			wantErr: true,
+		},
+		{
+			name:    "password_file_not_found",
+			tc:      &TargetConfig{PasswordFile: filepath.Join(dir, "missing")},
+			wantErr: true,
+		},
+		{
+			name:      "token_file",
+			tc:        &TargetConfig{TokenFile: tokenFile},
+			wantToken: strPtr("tok"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tc.resolveCredentials()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !equalStrPtr(tt.tc.Password, tt.wantPassword) {
+				t.Errorf("password = %v, want %v", tt.tc.Password, tt.wantPassword)
+			}
+			if !equalStrPtr(tt.tc.Token, tt.wantToken) {
+				t.Errorf("token = %v, want %v", tt.tc.Token, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestTargetConfig_String(t *testing.T) {
+	tc := &TargetConfig{
+		Address:  "10.0.0.1:57400",
+		Username: strPtr("admin"),
+		Password: strPtr("s3cret"),
+		Token:    strPtr("tok"),
+	}
+	s := tc.String()
+	for _, secret := range []string{"s3cret", "tok\""} {
+		if strings.Contains(s, secret) {
+			t.Errorf("String() = %s, contains %q", s, secret)
+		}
+	}
+	if *tc.Password != "s3cret" || *tc.Token != "tok" {
+		t.Errorf("String() modified the target config")
+	}
+}
+
+func strPtr(s string) *string { return &s }
+
+func equalStrPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
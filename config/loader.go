@@ -0,0 +1,274 @@
+package config
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// loader types
+const (
+	LoaderTypeFile = "file"
+	LoaderTypeHTTP = "http"
+	LoaderTypeDNS  = "dns"
+)
+
+// targets file formats
+const (
+	TargetsFormatYAML = "yaml"
+	TargetsFormatJSON = "json"
+	TargetsFormatCSV  = "csv"
+)
+
+const (
+	defaultLoaderInterval = 30 * time.Second
+	defaultLoaderTimeout  = 10 * time.Second
+)
+
+// LoaderConfig configures the loader adding targets
+// to the ones of the config file.
+type LoaderConfig struct {
+	// file, http or dns
+	Type string `mapstructure:"type,omitempty" json:"type,omitempty"`
+	// file: path to the targets file, it is watched for changes.
+	Path string `mapstructure:"path,omitempty" json:"path,omitempty"`
+	// file: yaml, json or csv, derived from the file extension if not set.
+	Format string `mapstructure:"format,omitempty" json:"format,omitempty"`
+	// http: URL returning the targets in JSON.
+	URL        string            `mapstructure:"url,omitempty" json:"url,omitempty"`
+	Headers    map[string]string `mapstructure:"headers,omitempty" json:"headers,omitempty"`
+	SkipVerify bool              `mapstructure:"skip-verify,omitempty" json:"skip-verify,omitempty"`
+	// dns: SRV record name, e.g: _gribi._tcp.lab.example.com
+	Service string `mapstructure:"service,omitempty" json:"service,omitempty"`
+	// dns: server address, the system resolver is used if not set.
+	Server string `mapstructure:"server,omitempty" json:"server,omitempty"`
+	// http and dns: request timeout
+	Timeout time.Duration `mapstructure:"timeout,omitempty" json:"timeout,omitempty"`
+	// http and dns: polling interval in long running modes
+	Interval time.Duration `mapstructure:"interval,omitempty" json:"interval,omitempty"`
+}
+
+// GetLoader returns the loader configuration, nil if none is configured.
+func (c *Config) GetLoader() (*LoaderConfig, error) {
+	m := c.FileConfig.GetStringMap("loader")
+	if len(m) == 0 {
+		return nil, nil
+	}
+	lc := new(LoaderConfig)
+	err := decodeConfig(m, lc, true)
+	if err != nil {
+		return nil, fmt.Errorf("loader: %v", err)
+	}
+	switch lc.Type {
+	case LoaderTypeFile:
+		if lc.Path == "" {
+			return nil, errors.New("loader: missing path")
+		}
+		lc.Path = os.ExpandEnv(lc.Path)
+		if lc.Format == "" {
+			lc.Format = strings.TrimPrefix(filepath.Ext(lc.Path), ".")
+			if lc.Format == "yml" {
+				lc.Format = TargetsFormatYAML
+			}
+		}
+		switch lc.Format {
+		case TargetsFormatYAML, TargetsFormatJSON, TargetsFormatCSV:
+		default:
+			return nil, fmt.Errorf("loader: unknown targets file format %q, expected yaml, json or csv", lc.Format)
+		}
+	case LoaderTypeHTTP:
+		if lc.URL == "" {
+			return nil, errors.New("loader: missing url")
+		}
+		lc.URL = os.ExpandEnv(lc.URL)
+		for k, v := range lc.Headers {
+			lc.Headers[k] = os.ExpandEnv(v)
+		}
+	case LoaderTypeDNS:
+		if lc.Service == "" {
+			return nil, errors.New("loader: missing service")
+		}
+	case "":
+		return nil, errors.New("loader: missing type")
+	default:
+		return nil, fmt.Errorf("loader: unknown type %q, expected file, http or dns", lc.Type)
+	}
+	if lc.Interval <= 0 {
+		lc.Interval = defaultLoaderInterval
+	}
+	if lc.Timeout <= 0 {
+		lc.Timeout = defaultLoaderTimeout
+	}
+	return lc, nil
+}
+
+// SetLoadedTargets validates the targets returned by the loader and sets their defaults,
+// they are then returned by GetTargets along with the config file targets.
+func (c *Config) SetLoadedTargets(tcs []*TargetConfig) error {
+	loaded := make(map[string]*TargetConfig, len(tcs))
+	for _, tc := range tcs {
+		err := c.completeTargetConfig(tc, tc.Address)
+		if err != nil {
+			return err
+		}
+		loaded[tc.Name] = tc
+	}
+	c.loadedTargets = loaded
+	return nil
+}
+
+// ParseTargets parses a list of targets in the yaml, json or csv format.
+//
+// In yaml and json, the targets are either a map keyed by address, like the config file targets,
+// or a list of objects with an address field, optionally under a top level "targets" key.
+//
+// In csv, the first line is the header naming the target config fields of each column,
+// groups are separated by ";" and tags are formatted as key=value separated by ";".
+func ParseTargets(b []byte, format string) ([]*TargetConfig, error) {
+	var v interface{}
+	switch format {
+	case TargetsFormatYAML:
+		err := yaml.Unmarshal(b, &v)
+		if err != nil {
+			return nil, err
+		}
+		v = convertYAML(v)
+	case TargetsFormatJSON:
+		err := json.Unmarshal(b, &v)
+		if err != nil {
+			return nil, err
+		}
+	case TargetsFormatCSV:
+		return parseTargetsCSV(b)
+	default:
+		return nil, fmt.Errorf("unknown targets format %q", format)
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		if t, ok := m["targets"]; ok && len(m) == 1 {
+			v = t
+		}
+	}
+	tcs := make([]*TargetConfig, 0)
+	switch v := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		for addr, t := range v {
+			tc := new(TargetConfig)
+			switch t := t.(type) {
+			case map[string]interface{}:
+				err := decodeConfig(t, tc, true)
+				if err != nil {
+					return nil, fmt.Errorf("%q: %v", addr, err)
+				}
+			case nil:
+			default:
+				return nil, fmt.Errorf("%q: unexpected target format, got a %T", addr, t)
+			}
+			tc.Address = addr
+			tcs = append(tcs, tc)
+		}
+	case []interface{}:
+		for i, t := range v {
+			m, ok := t.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("target index %d: unexpected target format, got a %T", i, t)
+			}
+			tc := new(TargetConfig)
+			err := decodeConfig(m, tc, true)
+			if err != nil {
+				return nil, fmt.Errorf("target index %d: %v", i, err)
+			}
+			if tc.Address == "" {
+				return nil, fmt.Errorf("target index %d: missing address", i)
+			}
+			tcs = append(tcs, tc)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected targets format, got a %T", v)
+	}
+	return tcs, nil
+}
+
+func parseTargetsCSV(b []byte) ([]*TargetConfig, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	tcs := make([]*TargetConfig, 0)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return tcs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		m := make(map[string]interface{}, len(record))
+		for i, f := range record {
+			f = strings.TrimSpace(f)
+			if f == "" {
+				continue
+			}
+			switch header[i] {
+			case "groups":
+				m[header[i]] = strings.Split(f, ";")
+			case "tags":
+				tags := make(map[string]interface{})
+				for _, tag := range strings.Split(f, ";") {
+					k, v, _ := strings.Cut(tag, "=")
+					tags[strings.TrimSpace(k)] = strings.TrimSpace(v)
+				}
+				m[header[i]] = tags
+			default:
+				m[header[i]] = f
+			}
+		}
+		tc := new(TargetConfig)
+		err = decodeConfig(m, tc, true)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if tc.Address == "" {
+			return nil, fmt.Errorf("line %d: missing address", line)
+		}
+		tcs = append(tcs, tc)
+	}
+}
+
+// convertYAML converts the map[interface{}]interface{} values returned by yaml.v2
+// to map[string]interface{}.
+func convertYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = convertYAML(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = convertYAML(e)
+		}
+		return v
+	}
+	return v
+}
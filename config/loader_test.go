@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		format  string
+		want    []*TargetConfig
+		wantErr bool
+	}{
+		{
+			name:   "yaml_map",
+			format: TargetsFormatYAML,
+			in: `
+10.0.0.1:
+  name: spine1
+  groups: [spines]
+10.0.0.2:
+`,
+			want: []*TargetConfig{
+				{Address: "10.0.0.1", Name: "spine1", Groups: []string{"spines"}},
+				{Address: "10.0.0.2"},
+			},
+		},
+		{
+			name:   "yaml_targets_list",
+			format: TargetsFormatYAML,
+			in: `
+targets:
+  - address: 10.0.0.1:57400
+    insecure: true
+    tags:
+      site: paris
+`,
+			want: []*TargetConfig{
+				{Address: "10.0.0.1:57400", Insecure: boolPtr(true), Tags: map[string]string{"site": "paris"}},
+			},
+		},
+		{
+			name:   "json_list",
+			format: TargetsFormatJSON,
+			in:     `[{"address": "10.0.0.1", "name": "spine1"}, {"address": "10.0.0.2"}]`,
+			want: []*TargetConfig{
+				{Address: "10.0.0.1", Name: "spine1"},
+				{Address: "10.0.0.2"},
+			},
+		},
+		{
+			name:    "json_missing_address",
+			format:  TargetsFormatJSON,
+			in:      `[{"name": "spine1"}]`,
+			wantErr: true,
+		},
+		{
+			name:   "csv",
+			format: TargetsFormatCSV,
+			in: `address,name,groups,tags
+# comment
+10.0.0.1:57400, spine1, spines;lab1, site=paris;protected=
+10.0.0.2:57400,,,
+`,
+			want: []*TargetConfig{
+				{
+					Address: "10.0.0.1:57400",
+					Name:    "spine1",
+					Groups:  []string{"spines", "lab1"},
+					Tags:    map[string]string{"site": "paris", "protected": ""},
+				},
+				{Address: "10.0.0.2:57400"},
+			},
+		},
+		{
+			name:    "csv_missing_address",
+			format:  TargetsFormatCSV,
+			in:      "name\nspine1\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown_format",
+			format:  "xml",
+			in:      "<targets/>",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTargets([]byte(tt.in), tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTargets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			sort.Slice(got, func(i, j int) bool {
+				return got[i].Address < got[j].Address
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTargets() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_GetLoader(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     string
+		want    *LoaderConfig
+		wantErr bool
+	}{
+		{
+			name: "none",
+			cfg:  "port: 57400",
+		},
+		{
+			name: "file_format_from_extension",
+			cfg: `
+loader:
+  type: file
+  path: targets.yml
+`,
+			want: &LoaderConfig{
+				Type:     LoaderTypeFile,
+				Path:     "targets.yml",
+				Format:   TargetsFormatYAML,
+				Timeout:  defaultLoaderTimeout,
+				Interval: defaultLoaderInterval,
+			},
+		},
+		{
+			name: "file_unknown_format",
+			cfg: `
+loader:
+  type: file
+  path: targets.txt
+`,
+			wantErr: true,
+		},
+		{
+			name: "http",
+			cfg: `
+loader:
+  type: http
+  url: http://inventory/targets
+  interval: 1m
+`,
+			want: &LoaderConfig{
+				Type:     LoaderTypeHTTP,
+				URL:      "http://inventory/targets",
+				Timeout:  defaultLoaderTimeout,
+				Interval: time.Minute,
+			},
+		},
+		{
+			name: "dns_missing_service",
+			cfg: `
+loader:
+  type: dns
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown_type",
+			cfg: `
+loader:
+  type: consul
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			c.SetLogger()
+			c.FileConfig.SetConfigType("yaml")
+			if err := c.FileConfig.ReadConfig(bytes.NewBufferString(tt.cfg)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.GetLoader()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetLoader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetLoader() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
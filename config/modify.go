@@ -173,17 +173,105 @@ func (o *OperationConfig) CreateAftOper() (*spb.AFTOperation, error) {
 	return api.NewAFTOperation(opts...)
 }
 
+// CreateAFTEntry returns the AFT entry described by the operation,
+// the operation type and election ID are ignored.
+func (o *OperationConfig) CreateAFTEntry() (*spb.AFTEntry, error) {
+	err := o.validate()
+	if err != nil {
+		return nil, err
+	}
+	oc := *o
+	oc.Operation = "add"
+	oc.ElectionID = ""
+	op, err := oc.CreateAftOper()
+	if err != nil {
+		return nil, err
+	}
+	entry := &spb.AFTEntry{NetworkInstance: op.GetNetworkInstance()}
+	switch e := op.GetEntry().(type) {
+	case *spb.AFTOperation_Ipv4:
+		entry.Entry = &spb.AFTEntry_Ipv4{Ipv4: e.Ipv4}
+	case *spb.AFTOperation_Ipv6:
+		entry.Entry = &spb.AFTEntry_Ipv6{Ipv6: e.Ipv6}
+	case *spb.AFTOperation_NextHopGroup:
+		entry.Entry = &spb.AFTEntry_NextHopGroup{NextHopGroup: e.NextHopGroup}
+	case *spb.AFTOperation_NextHop:
+		entry.Entry = &spb.AFTEntry_NextHop{NextHop: e.NextHop}
+	default:
+		return nil, fmt.Errorf("unsupported entry type %T", e)
+	}
+	return entry, nil
+}
+
 type ModifyInput struct {
 	DefaultNetworkInstance string             `yaml:"default-network-instance" json:"default-network-instance,omitempty"`
 	DefaultOperation       string             `yaml:"default-operation" json:"default-operation,omitempty"`
-	Params                 *sessionParams     `yaml:"params,omitempty" json:"params,omitempty"`
+	Params                 *SessionParams     `yaml:"params,omitempty" json:"params,omitempty"`
 	Operations             []*OperationConfig `yaml:"operations,omitempty" json:"operations,omitempty"`
 }
 
-type sessionParams struct {
-	Redundancy  string `yaml:"redundancy,omitempty" json:"redundancy,omitempty"`
-	Persistence string `yaml:"persistence,omitempty" json:"persistence,omitempty"`
-	AckType     string `yaml:"ack-type,omitempty" json:"ack-type,omitempty"`
+// SessionParams are the Modify RPC session parameters,
+// an empty value means the parameter is not set.
+type SessionParams struct {
+	// all-primary or single-primary
+	Redundancy string `yaml:"redundancy,omitempty" json:"redundancy,omitempty" mapstructure:"redundancy,omitempty"`
+	// delete or preserve
+	Persistence string `yaml:"persistence,omitempty" json:"persistence,omitempty" mapstructure:"persistence,omitempty"`
+	// rib or rib-fib
+	AckType string `yaml:"ack-type,omitempty" json:"ack-type,omitempty" mapstructure:"ack-type,omitempty"`
+}
+
+func (p *SessionParams) validate() error {
+	if p == nil {
+		return nil
+	}
+	switch p.Redundancy {
+	case "", "all-primary", "single-primary":
+	default:
+		return fmt.Errorf("unknown redundancy %q, expected all-primary or single-primary", p.Redundancy)
+	}
+	switch p.Persistence {
+	case "", "delete", "preserve":
+	default:
+		return fmt.Errorf("unknown persistence %q, expected delete or preserve", p.Persistence)
+	}
+	switch p.AckType {
+	case "", "rib", "rib-fib":
+	default:
+		return fmt.Errorf("unknown ack-type %q, expected rib or rib-fib", p.AckType)
+	}
+	return nil
+}
+
+// MergeSessionParams returns the session parameters where each parameter
+// is taken from the first element of ps that sets it,
+// unset parameters default to all-primary, delete and rib.
+func MergeSessionParams(ps ...*SessionParams) *SessionParams {
+	m := new(SessionParams)
+	for _, p := range ps {
+		if p == nil {
+			continue
+		}
+		if m.Redundancy == "" {
+			m.Redundancy = p.Redundancy
+		}
+		if m.Persistence == "" {
+			m.Persistence = p.Persistence
+		}
+		if m.AckType == "" {
+			m.AckType = p.AckType
+		}
+	}
+	if m.Redundancy == "" {
+		m.Redundancy = "all-primary"
+	}
+	if m.Persistence == "" {
+		m.Persistence = "delete"
+	}
+	if m.AckType == "" {
+		m.AckType = "rib"
+	}
+	return m
 }
 
 type ipv4v6Entry struct {
@@ -237,17 +325,27 @@ type ipinip struct {
 	SRCIP string `yaml:"src-ip,omitempty" json:"src-ip,omitempty"`
 }
 
-func (c *Config) GenerateModifyInputs(targetName string) (*ModifyInput, error) {
+func (c *Config) GenerateModifyInputs(tc *TargetConfig) (*ModifyInput, error) {
+	vars, err := c.TargetVars(tc, c.ModifyInputFile, c.modifyInputVars)
+	if err != nil {
+		return nil, err
+	}
 	buf := new(bytes.Buffer)
-	err := c.modifyInputTemplate.Execute(buf, templateInput{
-		TargetName: targetName,
-		Vars:       c.modifyInputVars,
+	err = c.modifyInputTemplate.Execute(buf, templateInput{
+		TargetName: tc.Name,
+		Vars:       vars,
 	})
 	if err != nil {
 		return nil, err
 	}
+	return parseModifyInput(buf.Bytes())
+}
+
+// parseModifyInput parses a rendered modify input file,
+// sets the operations defaults and validates them.
+func parseModifyInput(b []byte) (*ModifyInput, error) {
 	result := new(ModifyInput)
-	err = yaml.Unmarshal(buf.Bytes(), result)
+	err := yaml.Unmarshal(b, result)
 	if err != nil {
 		return nil, err
 	}
@@ -265,7 +363,62 @@ func (c *Config) GenerateModifyInputs(targetName string) (*ModifyInput, error) {
 			return nil, fmt.Errorf("operation index %d is invalid: %w", op.ID, err)
 		}
 	}
-	return result, err
+	return result, nil
+}
+
+// ReadModifyInput reads and renders the modify input file name for the target targetName,
+// independently of the modify command input file.
+// The variables are read from the file with the same name and a _vars suffix, if it exists.
+func (c *Config) ReadModifyInput(name, targetName string) (*ModifyInput, error) {
+	b, err := c.renderFileTemplate(name, targetName)
+	if err != nil {
+		return nil, err
+	}
+	return parseModifyInput(b)
+}
+
+// renderFileTemplate renders the template file name for the target targetName,
+// with the variables read from the file with the same name and a _vars suffix, if it exists.
+func (c *Config) renderFileTemplate(name, targetName string) ([]byte, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := c.createTemplate(name, string(b))
+	if err != nil {
+		return nil, err
+	}
+	var vars map[string]interface{}
+	ext := filepath.Ext(name)
+	varsFile := fmt.Sprintf("%s%s%s", name[0:len(name)-len(ext)], varFileSuffix, ext)
+	_, err = os.Stat(varsFile)
+	switch {
+	case err == nil:
+		b, err = readFile(varsFile)
+		if err != nil {
+			return nil, err
+		}
+		err = yaml.Unmarshal(b, &vars)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := utils.Convert(vars).(map[string]interface{})
+		if !ok {
+			return nil, errors.New("unexpected variables file format")
+		}
+		vars = m
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	err = tpl.Execute(buf, templateInput{
+		TargetName: targetName,
+		Vars:       vars,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 func (c *Config) ReadModifyFileTemplate() error {
@@ -273,7 +426,7 @@ func (c *Config) ReadModifyFileTemplate() error {
 	if err != nil {
 		return err
 	}
-	c.modifyInputTemplate, err = utils.CreateTemplate("modify-rpc-input", string(b))
+	c.modifyInputTemplate, err = c.createTemplate("modify-rpc-input", string(b))
 	if err != nil {
 		return err
 	}
@@ -331,27 +484,27 @@ func ParseUint128(v string) (*spb.Uint128, error) {
 	lh := strings.SplitN(v, ":", 2)
 	switch len(lh) {
 	case 1:
-		vi, err := strconv.Atoi(lh[0])
+		vi, err := strconv.ParseUint(lh[0], 10, 64)
 		if err != nil {
 			return nil, err
 		}
-		return &spb.Uint128{Low: uint64(vi)}, nil
+		return &spb.Uint128{Low: vi}, nil
 	case 2:
 		if lh[0] == "" {
 			lh[0] = "0"
 		}
-		v0i, err := strconv.Atoi(lh[0])
+		v0i, err := strconv.ParseUint(lh[0], 10, 64)
 		if err != nil {
 			return nil, err
 		}
 		if lh[1] == "" {
 			lh[1] = "0"
 		}
-		v1i, err := strconv.Atoi(lh[1])
+		v1i, err := strconv.ParseUint(lh[1], 10, 64)
 		if err != nil {
 			return nil, err
 		}
-		return &spb.Uint128{High: uint64(v0i), Low: uint64(v1i)}, nil
+		return &spb.Uint128{High: v0i, Low: v1i}, nil
 	}
 	return nil, nil
 }
@@ -397,6 +550,9 @@ func tryYAML(data []byte) ([]byte, error) {
 type templateInput struct {
 	TargetName string
 	Vars       map[string]interface{}
+	// values captured by previous workflow steps,
+	// only set when rendering "print" steps.
+	Captures map[string]interface{}
 }
 
 // sortOperations sorts the given []*OperationConfig slice based on the ordering type
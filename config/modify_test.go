@@ -1,6 +1,9 @@
 package config
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -370,3 +373,87 @@ func Test_sortOperationsDRA(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_ReadModifyInput(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"input.yaml": `
+default-network-instance: default
+default-operation: add
+operations:
+  - op: delete
+    ipv4:
+      prefix: 10.0.0.0/24
+  - nh:
+      index: {{ .Vars.index }}
+      ip-address: 192.168.1.1
+  - network-instance: {{ .TargetName }}
+    nhg:
+      id: 1
+      next-hop:
+        - index: {{ .Vars.index }}
+`,
+		"input_vars.yaml": `
+index: 42
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := New()
+	c.SetLogger()
+	mi, err := c.ReadModifyInput(filepath.Join(dir, "input.yaml"), "vrf1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(mi.Operations))
+	for _, op := range mi.Operations {
+		got = append(got, fmt.Sprintf("%d %s %s", op.ID, op.Operation, op.NetworkInstance))
+	}
+	want := []string{"1 delete default", "2 add default", "3 add vrf1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got operations %v, want %v", got, want)
+	}
+	if mi.Operations[1].NH.Index != 42 {
+		t.Errorf("got NH index %d, want 42", mi.Operations[1].NH.Index)
+	}
+	if _, err = c.ReadModifyInput(filepath.Join(dir, "missing.yaml"), ""); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
+
+func TestMergeSessionParams(t *testing.T) {
+	tests := []struct {
+		name string
+		ps   []*SessionParams
+		want *SessionParams
+	}{
+		{
+			name: "defaults",
+			want: &SessionParams{Redundancy: "all-primary", Persistence: "delete", AckType: "rib"},
+		},
+		{
+			name: "nil_layers",
+			ps:   []*SessionParams{nil, {AckType: "rib-fib"}, nil},
+			want: &SessionParams{Redundancy: "all-primary", Persistence: "delete", AckType: "rib-fib"},
+		},
+		{
+			name: "target_over_flags_over_input",
+			ps: []*SessionParams{
+				{AckType: "rib"},
+				{AckType: "rib-fib", Persistence: "preserve"},
+				{Redundancy: "single-primary", Persistence: "delete", AckType: "rib-fib"},
+			},
+			want: &SessionParams{Redundancy: "single-primary", Persistence: "preserve", AckType: "rib"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeSessionParams(tt.ps...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeSessionParams() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// verifyStepKinds are the step kinds allowed in a rollout verify file,
+// none of them changes the target RIB.
+var verifyStepKinds = map[string]struct{}{
+	"get":      {},
+	"verify":   {},
+	"wait-for": {},
+	"print":    {},
+	"exec":     {},
+}
+
+// ValidateRolloutFlags validates the concurrency and staged rollout flags.
+func (c *Config) ValidateRolloutFlags() error {
+	if c.MaxConcurrency < 0 || c.Canary < 0 || c.Batch < 0 || c.MaxFailures < 0 || c.BatchWait < 0 {
+		return errors.New("--max-concurrency, --canary, --batch, --batch-wait and --max-failures must be positive")
+	}
+	return nil
+}
+
+// RolloutStages splits the target names in the rollout stages:
+// the first --canary targets, then batches of --batch targets.
+// Without --canary and --batch, all the targets are in a single stage.
+// The targets are sorted by name so that the stages are the same from one run to the next.
+func (c *Config) RolloutStages(names []string) [][]string {
+	sorted := make([]string, len(names))
+	copy(sorted, names)
+	sort.Strings(sorted)
+	stages := make([][]string, 0)
+	if c.Canary > 0 && len(sorted) > 0 {
+		n := c.Canary
+		if n > len(sorted) {
+			n = len(sorted)
+		}
+		stages = append(stages, sorted[:n])
+		sorted = sorted[n:]
+	}
+	if c.Batch <= 0 {
+		if len(sorted) > 0 {
+			stages = append(stages, sorted)
+		}
+		return stages
+	}
+	for len(sorted) > 0 {
+		n := c.Batch
+		if n > len(sorted) {
+			n = len(sorted)
+		}
+		stages = append(stages, sorted[:n])
+		sorted = sorted[n:]
+	}
+	return stages
+}
+
+// GenerateVerifyWorkflow renders the --verify-file workflow for target tc,
+// with the vars of its _vars file.
+// The workflow is run against the targets of each rollout stage,
+// it may only contain get, verify, wait-for, print and exec steps.
+func (c *Config) GenerateVerifyWorkflow(tc *TargetConfig) (*Workflow, error) {
+	absPath, err := filepath.Abs(c.VerifyFile)
+	if err != nil {
+		return nil, err
+	}
+	// the vars of the verify file, not the ones of the workflow command file
+	fileVars, err := c.ReadTemplateVarsFile(c.VerifyFile)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := c.TargetVars(tc, c.VerifyFile, fileVars)
+	if err != nil {
+		return nil, err
+	}
+	wf, err := c.renderIncludedWorkflow(absPath, tc.Name, vars)
+	if err != nil {
+		return nil, err
+	}
+	if wf.Name == "" {
+		wf.Name = strings.TrimSuffix(filepath.Base(absPath), filepath.Ext(absPath))
+	}
+	err = c.expandIncludes(wf, tc.Name, vars, []string{absPath})
+	if err != nil {
+		return nil, err
+	}
+	errs := wf.Validate()
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("verify file %q: %v", c.VerifyFile, errs[0])
+	}
+	for _, s := range wf.Steps {
+		if _, ok := verifyStepKinds[s.Kind()]; !ok {
+			return nil, fmt.Errorf("verify file %q: step %q: %s steps are not allowed, only get, verify, wait-for, print and exec are",
+				c.VerifyFile, s.Name, s.Kind())
+		}
+	}
+	return wf, nil
+}
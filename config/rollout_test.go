@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfig_RolloutStages(t *testing.T) {
+	names := []string{"r5", "r3", "r1", "r4", "r2"}
+	tests := []struct {
+		name   string
+		canary int
+		batch  int
+		want   [][]string
+	}{
+		{
+			name: "single_stage",
+			want: [][]string{{"r1", "r2", "r3", "r4", "r5"}},
+		},
+		{
+			name:   "canary_only",
+			canary: 1,
+			want:   [][]string{{"r1"}, {"r2", "r3", "r4", "r5"}},
+		},
+		{
+			name:  "batch_only",
+			batch: 2,
+			want:  [][]string{{"r1", "r2"}, {"r3", "r4"}, {"r5"}},
+		},
+		{
+			name:   "canary_and_batch",
+			canary: 2,
+			batch:  2,
+			want:   [][]string{{"r1", "r2"}, {"r3", "r4"}, {"r5"}},
+		},
+		{
+			name:   "canary_covers_all",
+			canary: 10,
+			batch:  2,
+			want:   [][]string{{"r1", "r2", "r3", "r4", "r5"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			c.Canary = tt.canary
+			c.Batch = tt.batch
+			got := c.RolloutStages(names)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RolloutStages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := New().RolloutStages(nil); len(got) != 0 {
+		t.Errorf("RolloutStages(nil) = %v, want no stage", got)
+	}
+	if names[0] != "r5" {
+		t.Errorf("RolloutStages() modified its input: %v", names)
+	}
+}
+
+func TestConfig_GenerateVerifyWorkflow(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"verify.yaml": `
+steps:
+  - name: routes
+    verify:
+      entries:
+        - ipv4:
+            prefix: 10.0.0.0/24
+  - print: "{{ .TargetName }} verified, {{ .Vars.expected }} routes"
+`,
+		"verify_vars.yaml": `
+expected: 2
+`,
+		"modify.yaml": `
+steps:
+  - rpc: modify
+    operations:
+      - op: add
+        nh:
+          index: 1
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := New()
+	c.SetLogger()
+	c.VerifyFile = filepath.Join(dir, "verify.yaml")
+	// the vars of the workflow command file are not used by the verify file
+	c.workflowVars = map[string]interface{}{"expected": 1}
+	wf, err := c.GenerateVerifyWorkflow(&TargetConfig{Name: "target1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wf.Name != "verify" {
+		t.Errorf("workflow name = %q, want %q", wf.Name, "verify")
+	}
+	if len(wf.Steps) != 2 || wf.Steps[1].Name != "verify.2" || wf.Steps[1].Print != "target1 verified, 2 routes" {
+		t.Errorf("unexpected steps: %+v", wf.Steps)
+	}
+
+	c.VerifyFile = filepath.Join(dir, "modify.yaml")
+	if _, err = c.GenerateVerifyWorkflow(&TargetConfig{Name: "target1"}); err == nil {
+		t.Errorf("expected an error for a verify file with a modify step")
+	}
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultRetryBackoff    = time.Second
+	defaultRetryMaxBackoff = 30 * time.Second
+)
+
+var defaultRetryCodes = []string{"UNAVAILABLE"}
+
+// RetryConfig is the retry policy of the Flush and Get RPCs.
+type RetryConfig struct {
+	// number of attempts, including the first one, 1 disables the retries.
+	MaxAttempts int `json:"max-attempts,omitempty" mapstructure:"max-attempts,omitempty"`
+	// wait before the first retry, doubled after each retry.
+	Backoff time.Duration `json:"backoff,omitempty" mapstructure:"backoff,omitempty"`
+	// upper bound of the wait between two attempts.
+	MaxBackoff time.Duration `json:"max-backoff,omitempty" mapstructure:"max-backoff,omitempty"`
+	// gRPC status codes of the failed attempts that are retried,
+	// e.g: UNAVAILABLE or DEADLINE_EXCEEDED.
+	Codes []string `json:"codes,omitempty" mapstructure:"codes,omitempty"`
+
+	codes []codes.Code
+}
+
+func (rc *RetryConfig) validate() error {
+	if rc == nil {
+		return nil
+	}
+	if rc.MaxAttempts < 0 {
+		return errors.New("max-attempts must be positive")
+	}
+	if rc.Backoff < 0 || rc.MaxBackoff < 0 {
+		return errors.New("backoff must be positive")
+	}
+	for _, c := range rc.Codes {
+		if _, err := parseCode(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setDefaults sets the unset retry parameters to the global ones,
+// then to the defaults.
+func (rc *RetryConfig) setDefaults(c *Config) {
+	if rc.MaxAttempts == 0 {
+		rc.MaxAttempts = c.RetryMaxAttempts
+	}
+	if rc.MaxAttempts <= 0 {
+		rc.MaxAttempts = 1
+	}
+	if rc.Backoff == 0 {
+		rc.Backoff = c.RetryBackoff
+	}
+	if rc.Backoff <= 0 {
+		rc.Backoff = defaultRetryBackoff
+	}
+	if rc.MaxBackoff == 0 {
+		rc.MaxBackoff = c.RetryMaxBackoff
+	}
+	if rc.MaxBackoff <= 0 {
+		rc.MaxBackoff = defaultRetryMaxBackoff
+	}
+	if len(rc.Codes) == 0 {
+		rc.Codes = c.RetryCodes
+	}
+	if len(rc.Codes) == 0 {
+		rc.Codes = defaultRetryCodes
+	}
+	rc.codes = make([]codes.Code, 0, len(rc.Codes))
+	for _, s := range rc.Codes {
+		// the codes are validated when loading the targets
+		code, _ := parseCode(s)
+		rc.codes = append(rc.codes, code)
+	}
+}
+
+// Retryable returns true if err has one of the retry policy codes.
+func (rc *RetryConfig) Retryable(err error) bool {
+	code := status.Code(err)
+	for _, c := range rc.codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// NextBackoff returns the wait before the retry following a wait of d.
+func (rc *RetryConfig) NextBackoff(d time.Duration) time.Duration {
+	d *= 2
+	if d > rc.MaxBackoff {
+		return rc.MaxBackoff
+	}
+	return d
+}
+
+// parseCode parses a gRPC status code from its name, case and underscore insensitive,
+// e.g: UNAVAILABLE, DEADLINE_EXCEEDED or DeadlineExceeded, or from its number.
+func parseCode(s string) (codes.Code, error) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil && n <= uint64(codes.Unauthenticated) {
+		return codes.Code(n), nil
+	}
+	name := strings.ReplaceAll(s, "_", "")
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.EqualFold(c.String(), name) {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown gRPC status code %q", s)
+}
+
+// ValidateRPCFlags validates the global RPC timeouts and retry policy.
+func (c *Config) ValidateRPCFlags() error {
+	if c.GetTimeout < 0 || c.FlushTimeout < 0 || c.AckTimeout < 0 {
+		return errors.New("--get-timeout, --flush-timeout and --ack-timeout must be positive")
+	}
+	rc := &RetryConfig{
+		MaxAttempts: c.RetryMaxAttempts,
+		Backoff:     c.RetryBackoff,
+		MaxBackoff:  c.RetryMaxBackoff,
+		Codes:       c.RetryCodes,
+	}
+	err := rc.validate()
+	if err != nil {
+		return fmt.Errorf("invalid retry flags: %v", err)
+	}
+	return nil
+}
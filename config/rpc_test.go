@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseCode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    codes.Code
+		wantErr bool
+	}{
+		{in: "UNAVAILABLE", want: codes.Unavailable},
+		{in: "deadline_exceeded", want: codes.DeadlineExceeded},
+		{in: "ResourceExhausted", want: codes.ResourceExhausted},
+		{in: "14", want: codes.Unavailable},
+		{in: "17", wantErr: true},
+		{in: "bogus", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseCode(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryConfig_setDefaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		global GlobalFlags
+		rc     *RetryConfig
+		want   RetryConfig
+	}{
+		{
+			name: "defaults",
+			rc:   &RetryConfig{},
+			want: RetryConfig{
+				MaxAttempts: 1,
+				Backoff:     defaultRetryBackoff,
+				MaxBackoff:  defaultRetryMaxBackoff,
+				Codes:       defaultRetryCodes,
+			},
+		},
+		{
+			name: "global",
+			global: GlobalFlags{
+				RetryMaxAttempts: 3,
+				RetryBackoff:     100 * time.Millisecond,
+				RetryCodes:       []string{"UNAVAILABLE", "DEADLINE_EXCEEDED"},
+			},
+			rc: &RetryConfig{},
+			want: RetryConfig{
+				MaxAttempts: 3,
+				Backoff:     100 * time.Millisecond,
+				MaxBackoff:  defaultRetryMaxBackoff,
+				Codes:       []string{"UNAVAILABLE", "DEADLINE_EXCEEDED"},
+			},
+		},
+		{
+			name: "target_takes_precedence",
+			global: GlobalFlags{
+				RetryMaxAttempts: 3,
+				RetryCodes:       []string{"UNAVAILABLE"},
+			},
+			rc: &RetryConfig{MaxAttempts: 5, MaxBackoff: time.Second, Codes: []string{"ABORTED"}},
+			want: RetryConfig{
+				MaxAttempts: 5,
+				Backoff:     defaultRetryBackoff,
+				MaxBackoff:  time.Second,
+				Codes:       []string{"ABORTED"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			c.GlobalFlags = tt.global
+			tt.rc.setDefaults(c)
+			if tt.rc.MaxAttempts != tt.want.MaxAttempts || tt.rc.Backoff != tt.want.Backoff ||
+				tt.rc.MaxBackoff != tt.want.MaxBackoff || fmt.Sprint(tt.rc.Codes) != fmt.Sprint(tt.want.Codes) {
+				t.Errorf("setDefaults() = %+v, want %+v", *tt.rc, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryConfig_Retryable(t *testing.T) {
+	rc := &RetryConfig{Codes: []string{"UNAVAILABLE"}}
+	rc.setDefaults(New())
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "unavailable", err: status.Error(codes.Unavailable, ""), want: true},
+		{name: "wrapped", err: fmt.Errorf("Get RPC failed: %w", status.Error(codes.Unavailable, "")), want: true},
+		{name: "deadline_exceeded", err: status.Error(codes.DeadlineExceeded, "")},
+		{name: "not_a_status", err: errors.New("failed")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rc.Retryable(tt.err); got != tt.want {
+				t.Errorf("Retryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryConfig_NextBackoff(t *testing.T) {
+	rc := &RetryConfig{MaxBackoff: 3 * time.Second}
+	got := make([]time.Duration, 0, 3)
+	d := time.Second
+	for i := 0; i < 3; i++ {
+		d = rc.NextBackoff(d)
+		got = append(got, d)
+	}
+	want := []time.Duration{2 * time.Second, 3 * time.Second, 3 * time.Second}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("NextBackoff() = %v, want %v", got, want)
+	}
+}
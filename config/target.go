@@ -12,10 +12,12 @@ import (
 	"time"
 
 	"github.com/mitchellh/mapstructure"
+	spb "github.com/openconfig/gribi/v1/proto/service"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
 )
 
 const (
@@ -30,6 +32,10 @@ type TargetConfig struct {
 	SkipVerify    *bool         `json:"skip-verify,omitempty" mapstructure:"skip-verify,omitempty"`
 	Username      *string       `json:"username,omitempty" mapstructure:"username,omitempty"`
 	Password      *string       `json:"password,omitempty" mapstructure:"password,omitempty"`
+	PasswordFile  string        `json:"password-file,omitempty" mapstructure:"password-file,omitempty"`
+	PasswordEnv   string        `json:"password-env,omitempty" mapstructure:"password-env,omitempty"`
+	Token         *string       `json:"token,omitempty" mapstructure:"token,omitempty"`
+	TokenFile     string        `json:"token-file,omitempty" mapstructure:"token-file,omitempty"`
 	Timeout       time.Duration `json:"timeout,omitempty" mapstructure:"timeout,omitempty"`
 	TLSCert       *string       `json:"tls-cert,omitempty" mapstructure:"tls-cert,omitempty"`
 	TLSKey        *string       `json:"tls-key,omitempty" mapstructure:"tls-key,omitempty"`
@@ -37,15 +43,55 @@ type TargetConfig struct {
 	TLSMinVersion string        `json:"tls-min-version,omitempty" mapstructure:"tls-min-version,omitempty"`
 	TLSMaxVersion string        `json:"tls-max-version,omitempty" mapstructure:"tls-max-version,omitempty"`
 	TLSVersion    string        `json:"tls-version,omitempty" mapstructure:"tls-version,omitempty"`
+	TLSServerName string        `json:"tls-server-name,omitempty" mapstructure:"tls-server-name,omitempty"`
 	Gzip          *bool         `json:"gzip,omitempty" mapstructure:"gzip,omitempty"`
 	MaxRcvMsgSize int           `json:"max-rcv-msg-size,omitempty" mapstructure:"max-rcv-msg-size,omitempty"`
-	// modify RPC session params
-	// Redundancy  string `json:"redundancy,omitempty" mapstructure:"redundancy,omitempty"`
-	// Persistence string `json:"persistence,omitempty" mapstructure:"persistence,omitempty"`
-	// AckType     string `json:"ack-type,omitempty" mapstructure:"ack-type,omitempty"`
+	// Metadata is added to the context of every RPC sent to the target.
+	Metadata  map[string]string `json:"metadata,omitempty" mapstructure:"metadata,omitempty"`
+	Keepalive *KeepaliveConfig  `json:"keepalive,omitempty" mapstructure:"keepalive,omitempty"`
+	// bounds each Get RPC attempt, including the entries streaming.
+	GetTimeout time.Duration `json:"get-timeout,omitempty" mapstructure:"get-timeout,omitempty"`
+	// bounds each Flush RPC attempt.
+	FlushTimeout time.Duration `json:"flush-timeout,omitempty" mapstructure:"flush-timeout,omitempty"`
+	// bounds the wait for the acknowledgment of each Modify request operation.
+	AckTimeout time.Duration `json:"ack-timeout,omitempty" mapstructure:"ack-timeout,omitempty"`
+	// retry policy of the Flush and Get RPCs.
+	Retry *RetryConfig `json:"retry,omitempty" mapstructure:"retry,omitempty"`
+	// Tags are arbitrary key/value labels attached to the target.
+	Tags map[string]string `json:"tags,omitempty" mapstructure:"tags,omitempty"`
+	// Groups are the names of the groups the target belongs to.
+	Groups []string `json:"groups,omitempty" mapstructure:"groups,omitempty"`
+	// modify RPC session params, they take precedence over
+	// the modify command flags and the modify input file params.
+	SessionParams *SessionParams `json:"session-params,omitempty" mapstructure:"session-params,omitempty"`
+	// ElectionID takes precedence over the --election-id flag.
+	ElectionID string `json:"election-id,omitempty" mapstructure:"election-id,omitempty"`
 }
 
+// KeepaliveConfig sets the gRPC client keepalive parameters.
+type KeepaliveConfig struct {
+	// interval between pings when there is no activity on the connection
+	Time time.Duration `json:"time,omitempty" mapstructure:"time,omitempty"`
+	// time to wait for a ping ack before closing the connection
+	Timeout time.Duration `json:"timeout,omitempty" mapstructure:"timeout,omitempty"`
+	// send pings even without active RPCs
+	PermitWithoutStream bool `json:"permit-without-stream,omitempty" mapstructure:"permit-without-stream,omitempty"`
+}
+
+// GetTargets returns the targets set with --address or in the config file,
+// narrowed down by the targets selection flags.
 func (c *Config) GetTargets() (map[string]*TargetConfig, error) {
+	targetsConfigs, err := c.getAllTargets()
+	if err != nil {
+		return nil, err
+	}
+	return c.selectTargets(targetsConfigs)
+}
+
+// getAllTargets returns the targets set with --address,
+// otherwise the targets of the config file and the ones returned by the loader.
+// A config file target takes precedence over a loaded target with the same name.
+func (c *Config) getAllTargets() (map[string]*TargetConfig, error) {
 	targetsConfigs := make(map[string]*TargetConfig)
 	if len(c.Address) > 0 {
 		var err error
@@ -62,23 +108,17 @@ func (c *Config) GetTargets() (map[string]*TargetConfig, error) {
 		return targetsConfigs, nil
 	}
 	targetsMap := c.FileConfig.GetStringMap("targets")
-	if len(targetsMap) == 0 {
+	if len(targetsMap) == 0 && len(c.loadedTargets) == 0 {
 		return nil, errors.New("no targets found")
 	}
+	for n, tc := range c.loadedTargets {
+		targetsConfigs[n] = tc
+	}
 	for addr, t := range targetsMap {
 		tc := new(TargetConfig)
 		switch t := t.(type) {
 		case map[string]interface{}:
-			decoder, err := mapstructure.NewDecoder(
-				&mapstructure.DecoderConfig{
-					DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
-					Result:     tc,
-				},
-			)
-			if err != nil {
-				return nil, err
-			}
-			err = decoder.Decode(t)
+			err := decodeConfig(t, tc, false)
 			if err != nil {
 				return nil, err
 			}
@@ -86,18 +126,184 @@ func (c *Config) GetTargets() (map[string]*TargetConfig, error) {
 		default:
 			return nil, fmt.Errorf("unexpected targets format, got a %T", t)
 		}
-		err := c.parseAddress(tc, addr)
+		err := c.completeTargetConfig(tc, addr)
 		if err != nil {
-			return nil, fmt.Errorf("%q failed to parse address: %v", addr, err)
+			return nil, err
 		}
-		c.setTargetConfigDefaults(tc)
 		targetsConfigs[tc.Name] = tc
 		c.logger.Debugf("%q target-config: %s", addr, tc)
 	}
 	return targetsConfigs, nil
 }
 
+// decodeConfig decodes m into result,
+// weak allows converting values from strings, e.g: CSV fields.
+func decodeConfig(m map[string]interface{}, result interface{}, weak bool) error {
+	decoder, err := mapstructure.NewDecoder(
+		&mapstructure.DecoderConfig{
+			DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+			WeaklyTypedInput: weak,
+			Result:           result,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(m)
+}
+
+// completeTargetConfig validates a target config and sets its defaults.
+func (c *Config) completeTargetConfig(tc *TargetConfig, addr string) error {
+	err := c.parseAddress(tc, addr)
+	if err != nil {
+		return fmt.Errorf("%q failed to parse address: %v", addr, err)
+	}
+	err = tc.SessionParams.validate()
+	if err != nil {
+		return fmt.Errorf("%q invalid session-params: %v", addr, err)
+	}
+	if tc.ElectionID != "" {
+		_, err = ParseUint128(tc.ElectionID)
+		if err != nil {
+			return fmt.Errorf("%q invalid election-id: %v", addr, err)
+		}
+	}
+	for k := range tc.Metadata {
+		if strings.HasPrefix(strings.ToLower(k), "grpc-") {
+			return fmt.Errorf("%q invalid metadata key %q: the grpc- prefix is reserved", addr, k)
+		}
+	}
+	if tc.GetTimeout < 0 || tc.FlushTimeout < 0 || tc.AckTimeout < 0 {
+		return fmt.Errorf("%q: get-timeout, flush-timeout and ack-timeout must be positive", addr)
+	}
+	err = tc.Retry.validate()
+	if err != nil {
+		return fmt.Errorf("%q invalid retry: %v", addr, err)
+	}
+	err = tc.resolveCredentials()
+	if err != nil {
+		return fmt.Errorf("%q: %v", addr, err)
+	}
+	c.setTargetConfigDefaults(tc)
+	return nil
+}
+
+// GetTarget returns the configuration of the target with the given name,
+// from the configured targets if it is one of them,
+// otherwise name is used as the target address with the global flags values.
+func (c *Config) GetTarget(name string) (*TargetConfig, error) {
+	if len(c.Address) > 0 || len(c.FileConfig.GetStringMap("targets")) > 0 {
+		tcs, err := c.getAllTargets()
+		if err != nil {
+			return nil, err
+		}
+		if tc, ok := tcs[name]; ok {
+			return tc, nil
+		}
+		for _, tc := range tcs {
+			if tc.Address == name {
+				return tc, nil
+			}
+		}
+	}
+	tc := new(TargetConfig)
+	err := c.parseAddress(tc, name)
+	if err != nil {
+		return nil, fmt.Errorf("%q failed to parse address: %v", name, err)
+	}
+	c.setTargetConfigDefaults(tc)
+	return tc, nil
+}
+
+// selectTargets returns the targets matching the --target, --group and --tag flags
+// and none of the --exclude-target, --exclude-group and --exclude-tag flags.
+// A target matches a flag if it matches any of its values,
+// and the selection flags that are set must all match.
+func (c *Config) selectTargets(tcs map[string]*TargetConfig) (map[string]*TargetConfig, error) {
+	if len(c.TargetNames) == 0 && len(c.TargetGroups) == 0 && len(c.TargetTags) == 0 &&
+		len(c.ExcludeTargets) == 0 && len(c.ExcludeGroups) == 0 && len(c.ExcludeTags) == 0 {
+		return tcs, nil
+	}
+	// catch misspelled target names
+	names := make([]string, 0, len(c.TargetNames)+len(c.ExcludeTargets))
+	names = append(names, c.TargetNames...)
+	for _, n := range append(names, c.ExcludeTargets...) {
+		var found bool
+		for _, tc := range tcs {
+			if tc.matchName(n) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown target %q", n)
+		}
+	}
+	selected := make(map[string]*TargetConfig)
+	for n, tc := range tcs {
+		if len(c.TargetNames) > 0 && !matchAny(c.TargetNames, tc.matchName) {
+			continue
+		}
+		if len(c.TargetGroups) > 0 && !matchAny(c.TargetGroups, tc.inGroup) {
+			continue
+		}
+		if len(c.TargetTags) > 0 && !matchAny(c.TargetTags, tc.matchTag) {
+			continue
+		}
+		if matchAny(c.ExcludeTargets, tc.matchName) ||
+			matchAny(c.ExcludeGroups, tc.inGroup) ||
+			matchAny(c.ExcludeTags, tc.matchTag) {
+			continue
+		}
+		selected[n] = tc
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("no targets match the selection flags")
+	}
+	return selected, nil
+}
+
+func matchAny(values []string, match func(string) bool) bool {
+	for _, v := range values {
+		if match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (tc *TargetConfig) matchName(name string) bool {
+	return tc.Name == name || tc.Address == name
+}
+
+func (tc *TargetConfig) inGroup(group string) bool {
+	for _, g := range tc.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTag returns true if the target has the tag key,
+// with the given value if tag is formatted as key=value.
+// Tag keys are case insensitive.
+func (tc *TargetConfig) matchTag(tag string) bool {
+	k, v, hasValue := strings.Cut(tag, "=")
+	for tk, tv := range tc.Tags {
+		if strings.EqualFold(tk, k) {
+			return !hasValue || tv == v
+		}
+	}
+	return false
+}
+
 func (c *Config) parseAddress(tc *TargetConfig, addr string) error {
+	// unix socket, e.g: unix:///var/run/gribi.sock
+	if strings.HasPrefix(addr, "unix:") {
+		tc.Address = addr
+		return nil
+	}
 	_, _, err := net.SplitHostPort(addr)
 	if err != nil {
 		if strings.Contains(err.Error(), "missing port in address") ||
@@ -125,12 +331,28 @@ func (c *Config) setTargetConfigDefaults(tc *TargetConfig) {
 	if tc.Timeout <= 0 {
 		tc.Timeout = c.Timeout
 	}
+	if tc.GetTimeout <= 0 {
+		tc.GetTimeout = c.GetTimeout
+	}
+	if tc.FlushTimeout <= 0 {
+		tc.FlushTimeout = c.FlushTimeout
+	}
+	if tc.AckTimeout <= 0 {
+		tc.AckTimeout = c.AckTimeout
+	}
+	if tc.Retry == nil {
+		tc.Retry = new(RetryConfig)
+	}
+	tc.Retry.setDefaults(c)
 	if tc.Username == nil {
 		tc.Username = &c.Username
 	}
 	if tc.Password == nil {
 		tc.Password = &c.Password
 	}
+	if tc.Token == nil {
+		tc.Token = &c.Token
+	}
 	if tc.SkipVerify == nil {
 		tc.SkipVerify = &c.SkipVerify
 	}
@@ -156,6 +378,9 @@ func (c *Config) setTargetConfigDefaults(tc *TargetConfig) {
 	if tc.TLSMaxVersion == "" {
 		tc.TLSMaxVersion = c.TLSMaxVersion
 	}
+	if tc.TLSServerName == "" {
+		tc.TLSServerName = c.TLSServerName
+	}
 	if tc.Gzip == nil {
 		tc.Gzip = &c.Gzip
 	}
@@ -164,6 +389,21 @@ func (c *Config) setTargetConfigDefaults(tc *TargetConfig) {
 	}
 }
 
+// Protected returns true if the target is tagged "protected",
+// unless the tag value is "false".
+func (tc *TargetConfig) Protected() bool {
+	v, ok := tc.Tags["protected"]
+	return ok && v != "false"
+}
+
+// GetElectionID returns the target's election ID if set, nil otherwise.
+func (tc *TargetConfig) GetElectionID() (*spb.Uint128, error) {
+	if tc.ElectionID == "" {
+		return nil, nil
+	}
+	return ParseUint128(tc.ElectionID)
+}
+
 func (tc *TargetConfig) DialOpts() ([]grpc.DialOption, error) {
 	tOpts := make([]grpc.DialOption, 0)
 	if tc.Insecure != nil && *tc.Insecure {
@@ -181,6 +421,13 @@ func (tc *TargetConfig) DialOpts() ([]grpc.DialOption, error) {
 	if tc.MaxRcvMsgSize > 0 {
 		tOpts = append(tOpts, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(tc.MaxRcvMsgSize)))
 	}
+	if tc.Keepalive != nil && tc.Keepalive.Time > 0 {
+		tOpts = append(tOpts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                tc.Keepalive.Time,
+			Timeout:             tc.Keepalive.Timeout,
+			PermitWithoutStream: tc.Keepalive.PermitWithoutStream,
+		}))
+	}
 	return tOpts, nil
 }
 
@@ -190,6 +437,7 @@ func (tc *TargetConfig) newTLS() (*tls.Config, error) {
 		InsecureSkipVerify: *tc.SkipVerify,
 		MaxVersion:         tc.getTLSMaxVersion(),
 		MinVersion:         tc.getTLSMinVersion(),
+		ServerName:         tc.TLSServerName,
 	}
 	err := loadCerts(tlsConfig, tc)
 	if err != nil {
@@ -252,8 +500,12 @@ func loadCerts(tlscfg *tls.Config, tc *TargetConfig) error {
 	return nil
 }
 
+// String returns the target config in JSON, with its password and token masked.
 func (tc *TargetConfig) String() string {
-	b, err := json.Marshal(tc)
+	ntc := *tc
+	ntc.Password = maskSecret(tc.Password)
+	ntc.Token = maskSecret(tc.Token)
+	b, err := json.Marshal(&ntc)
 	if err != nil {
 		return ""
 	}
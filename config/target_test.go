@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestConfig_GetTargets_Selection(t *testing.T) {
+	cfg := `
+port: 57400
+targets:
+  10.0.0.1:
+    name: spine1
+    groups: [spines, lab1]
+    tags:
+      site: paris
+      protected: ""
+  10.0.0.2:
+    name: spine2
+    groups: [spines, lab2]
+    tags:
+      site: london
+  10.0.0.3:
+    name: leaf1
+    groups: [leaves, lab1]
+    tags:
+      site: paris
+`
+	tests := []struct {
+		name           string
+		targets        []string
+		groups         []string
+		tags           []string
+		excludeTargets []string
+		excludeGroups  []string
+		excludeTags    []string
+		want           []string
+		wantErr        bool
+	}{
+		{
+			name: "no_selection",
+			want: []string{"leaf1", "spine1", "spine2"},
+		},
+		{
+			name:    "by_name_and_address",
+			targets: []string{"spine1", "10.0.0.3:57400"},
+			want:    []string{"leaf1", "spine1"},
+		},
+		{
+			name:    "unknown_target",
+			targets: []string{"spine3"},
+			wantErr: true,
+		},
+		{
+			name:   "by_group",
+			groups: []string{"spines"},
+			want:   []string{"spine1", "spine2"},
+		},
+		{
+			name:   "by_group_and_tag",
+			groups: []string{"lab1"},
+			tags:   []string{"site=paris"},
+			want:   []string{"leaf1", "spine1"},
+		},
+		{
+			name: "by_tag_key",
+			tags: []string{"protected"},
+			want: []string{"spine1"},
+		},
+		{
+			name: "by_tag_values",
+			tags: []string{"site=london", "Site=paris"},
+			want: []string{"leaf1", "spine1", "spine2"},
+		},
+		{
+			name:          "exclude_group",
+			groups:        []string{"spines"},
+			excludeGroups: []string{"lab2"},
+			want:          []string{"spine1"},
+		},
+		{
+			name:        "exclude_tag",
+			excludeTags: []string{"protected"},
+			want:        []string{"leaf1", "spine2"},
+		},
+		{
+			name:           "exclude_target",
+			excludeTargets: []string{"leaf1"},
+			want:           []string{"spine1", "spine2"},
+		},
+		{
+			name:          "no_match",
+			groups:        []string{"leaves"},
+			excludeGroups: []string{"lab1"},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			c.SetLogger()
+			c.FileConfig.SetConfigType("yaml")
+			if err := c.FileConfig.ReadConfig(bytes.NewBufferString(cfg)); err != nil {
+				t.Fatal(err)
+			}
+			c.Port = "57400"
+			c.TargetNames = tt.targets
+			c.TargetGroups = tt.groups
+			c.TargetTags = tt.tags
+			c.ExcludeTargets = tt.excludeTargets
+			c.ExcludeGroups = tt.excludeGroups
+			c.ExcludeTags = tt.excludeTags
+			tcs, err := c.GetTargets()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetTargets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := make([]string, 0, len(tcs))
+			for n := range tcs {
+				got = append(got, n)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetTargets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_completeTargetConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		tc             *TargetConfig
+		addr           string
+		wantAddress    string
+		wantServerName string
+		wantErr        bool
+	}{
+		{
+			name:           "default_port",
+			tc:             &TargetConfig{},
+			addr:           "10.0.0.1",
+			wantAddress:    "10.0.0.1:57400",
+			wantServerName: "global.example.com",
+		},
+		{
+			name:           "unix_socket",
+			tc:             &TargetConfig{},
+			addr:           "unix:///var/run/gribi.sock",
+			wantAddress:    "unix:///var/run/gribi.sock",
+			wantServerName: "global.example.com",
+		},
+		{
+			name:           "tls_server_name",
+			tc:             &TargetConfig{TLSServerName: "r1.example.com"},
+			addr:           "10.0.0.1:57401",
+			wantAddress:    "10.0.0.1:57401",
+			wantServerName: "r1.example.com",
+		},
+		{
+			name:           "metadata",
+			tc:             &TargetConfig{Metadata: map[string]string{"x-tenant": "blue"}},
+			addr:           "10.0.0.1",
+			wantAddress:    "10.0.0.1:57400",
+			wantServerName: "global.example.com",
+		},
+		{
+			name:    "reserved_metadata_key",
+			tc:      &TargetConfig{Metadata: map[string]string{"grpc-timeout": "1S"}},
+			addr:    "10.0.0.1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			c.Port = "57400"
+			c.TLSServerName = "global.example.com"
+			err := c.completeTargetConfig(tt.tc, tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("completeTargetConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.tc.Address != tt.wantAddress {
+				t.Errorf("address = %q, want %q", tt.tc.Address, tt.wantAddress)
+			}
+			if tt.tc.TLSServerName != tt.wantServerName {
+				t.Errorf("tls-server-name = %q, want %q", tt.tc.TLSServerName, tt.wantServerName)
+			}
+		})
+	}
+}
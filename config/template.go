@@ -0,0 +1,332 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/hairyhenderson/gomplate/v3"
+	"github.com/hairyhenderson/gomplate/v3/data"
+	"gopkg.in/yaml.v2"
+)
+
+// maxSubnetsBits bounds the number of subnets returned by cidrSubnets.
+const maxSubnetsBits = 16
+
+// createTemplate parses a modify input or workflow template,
+// with the gomplate functions and the network functions below.
+func (c *Config) createTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).
+		Option("missingkey=zero").
+		Funcs(gomplate.CreateFuncs(context.TODO(), new(data.Data))).
+		Funcs(template.FuncMap{
+			"ipAdd":       ipAdd,
+			"cidrHost":    cidrHost,
+			"cidrSubnets": cidrSubnets,
+			"prefixRange": prefixRange,
+			"macAdd":      macAdd,
+			"uint128":     uint128,
+			"lookup":      c.lookup,
+		}).
+		Parse(text)
+}
+
+// ipAdd returns the address ip + n, n can be negative.
+func ipAdd(n, ip interface{}) (string, error) {
+	addr, err := netip.ParseAddr(fmt.Sprint(ip))
+	if err != nil {
+		return "", err
+	}
+	off, err := toBigInt(n)
+	if err != nil {
+		return "", err
+	}
+	r, err := addrAdd(addr, off)
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+// cidrHost returns the address number hostnum of prefix,
+// a negative hostnum counts from the end of the prefix, -1 being its last address.
+func cidrHost(hostnum, prefix interface{}) (string, error) {
+	p, err := netip.ParsePrefix(fmt.Sprint(prefix))
+	if err != nil {
+		return "", err
+	}
+	p = p.Masked()
+	n, err := toBigInt(hostnum)
+	if err != nil {
+		return "", err
+	}
+	size := new(big.Int).Lsh(big.NewInt(1), uint(p.Addr().BitLen()-p.Bits()))
+	if n.Sign() < 0 {
+		n.Add(n, size)
+	}
+	if n.Sign() < 0 || n.Cmp(size) >= 0 {
+		return "", fmt.Errorf("prefix %s has no host number %v", p, hostnum)
+	}
+	r, err := addrAdd(p.Addr(), n)
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+// cidrSubnets returns all the subnets of prefix with newbits more bits in their length.
+func cidrSubnets(newbits, prefix interface{}) ([]string, error) {
+	p, err := netip.ParsePrefix(fmt.Sprint(prefix))
+	if err != nil {
+		return nil, err
+	}
+	nb, err := toInt(newbits)
+	if err != nil {
+		return nil, err
+	}
+	if nb < 0 || nb > maxSubnetsBits {
+		return nil, fmt.Errorf("newbits must be between 0 and %d", maxSubnetsBits)
+	}
+	if p.Bits()+nb > p.Addr().BitLen() {
+		return nil, fmt.Errorf("prefix %s cannot be extended by %d bits", p, nb)
+	}
+	return prefixRange(1<<nb, netip.PrefixFrom(p.Masked().Addr(), p.Bits()+nb).String())
+}
+
+// prefixRange returns count consecutive prefixes of the same length, starting at prefix.
+func prefixRange(count, prefix interface{}) ([]string, error) {
+	p, err := netip.ParsePrefix(fmt.Sprint(prefix))
+	if err != nil {
+		return nil, err
+	}
+	p = p.Masked()
+	n, err := toInt(count)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, errors.New("count must be positive")
+	}
+	step := new(big.Int).Lsh(big.NewInt(1), uint(p.Addr().BitLen()-p.Bits()))
+	prefixes := make([]string, 0, n)
+	addr := p.Addr()
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			addr, err = addrAdd(addr, step)
+			if err != nil {
+				return nil, fmt.Errorf("prefix range of %d from %s: %v", n, p, err)
+			}
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, p.Bits()).String())
+	}
+	return prefixes, nil
+}
+
+// macAdd returns the 48 bits MAC address mac + n, n can be negative.
+func macAdd(n, mac interface{}) (string, error) {
+	hw, err := net.ParseMAC(fmt.Sprint(mac))
+	if err != nil {
+		return "", err
+	}
+	if len(hw) != 6 {
+		return "", fmt.Errorf("%s is not a 48 bits MAC address", hw)
+	}
+	v := new(big.Int).SetBytes(hw)
+	off, err := toBigInt(n)
+	if err != nil {
+		return "", err
+	}
+	v.Add(v, off)
+	if v.Sign() < 0 || v.BitLen() > 48 {
+		return "", fmt.Errorf("%s + %v overflows", hw, n)
+	}
+	b := make([]byte, 6)
+	return net.HardwareAddr(v.FillBytes(b)).String(), nil
+}
+
+// uint128 formats a number up to 2^128-1 as high:low,
+// the format of the election IDs.
+func uint128(v interface{}) (string, error) {
+	n, err := toBigInt(v)
+	if err != nil {
+		return "", err
+	}
+	if n.Sign() < 0 || n.BitLen() > 128 {
+		return "", fmt.Errorf("%v does not fit in a uint128", v)
+	}
+	low := new(big.Int).And(n, new(big.Int).SetUint64(^uint64(0)))
+	high := new(big.Int).Rsh(n, 64)
+	return fmt.Sprintf("%d:%d", high.Uint64(), low.Uint64()), nil
+}
+
+// lookup returns the data file entry of key, or its field if one is given.
+func (c *Config) lookup(key interface{}, field ...string) (interface{}, error) {
+	err := c.readTemplateData()
+	if err != nil {
+		return nil, err
+	}
+	k := fmt.Sprint(key)
+	entry, ok := c.templateData[k]
+	if !ok {
+		return nil, fmt.Errorf("lookup: %q not found in %q", k, c.DataFile)
+	}
+	switch len(field) {
+	case 0:
+		return entry, nil
+	case 1:
+		v, ok := entry[field[0]]
+		if !ok {
+			return nil, fmt.Errorf("lookup: %q has no field %q in %q", k, field[0], c.DataFile)
+		}
+		return v, nil
+	}
+	return nil, errors.New("lookup: expects a key and an optional field")
+}
+
+// readTemplateData reads the --data-file once.
+func (c *Config) readTemplateData() error {
+	c.templateDataOnce.Do(func() {
+		if c.DataFile == "" {
+			c.templateDataErr = errors.New("lookup: --data-file is not set")
+			return
+		}
+		c.templateData, c.templateDataErr = readDataFile(c.DataFile)
+		if c.templateDataErr != nil {
+			c.templateDataErr = fmt.Errorf("lookup: %v", c.templateDataErr)
+		}
+	})
+	return c.templateDataErr
+}
+
+// readDataFile reads a yaml, json or csv data file, keyed by its first column in csv,
+// by its top level keys otherwise.
+func readDataFile(name string) (map[string]map[string]interface{}, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return parseDataCSV(b)
+	case ".json":
+		err = json.Unmarshal(b, &v)
+	default:
+		err = yaml.Unmarshal(b, &v)
+		v = convertYAML(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: unexpected data format, got a %T", name, v)
+	}
+	d := make(map[string]map[string]interface{}, len(m))
+	for k, e := range m {
+		switch e := e.(type) {
+		case map[string]interface{}:
+			d[k] = e
+		case nil:
+			d[k] = map[string]interface{}{}
+		default:
+			return nil, fmt.Errorf("%s: %q: unexpected data format, got a %T", name, k, e)
+		}
+	}
+	return d, nil
+}
+
+func parseDataCSV(b []byte) (map[string]map[string]interface{}, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+	d := make(map[string]map[string]interface{})
+	header, err := r.Read()
+	if err == io.EOF {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return d, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		e := make(map[string]interface{}, len(record))
+		for i, f := range record {
+			e[header[i]] = strings.TrimSpace(f)
+		}
+		d[strings.TrimSpace(record[0])] = e
+	}
+}
+
+// addrAdd returns addr + n, or an error if the result is outside of the address family.
+func addrAdd(addr netip.Addr, n *big.Int) (netip.Addr, error) {
+	v := new(big.Int).SetBytes(addr.AsSlice())
+	v.Add(v, n)
+	if v.Sign() < 0 || v.BitLen() > addr.BitLen() {
+		return netip.Addr{}, fmt.Errorf("%s + %s overflows", addr, n)
+	}
+	r, _ := netip.AddrFromSlice(v.FillBytes(make([]byte, addr.BitLen()/8)))
+	return r, nil
+}
+
+func toInt(v interface{}) (int, error) {
+	n, err := toBigInt(v)
+	if err != nil {
+		return 0, err
+	}
+	if !n.IsInt64() || int64(int(n.Int64())) != n.Int64() {
+		return 0, fmt.Errorf("%v is out of range", v)
+	}
+	return int(n.Int64()), nil
+}
+
+// toBigInt converts the integers and the decimal strings
+// passed to the template functions.
+func toBigInt(v interface{}) (*big.Int, error) {
+	switch v := v.(type) {
+	case int:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case int32:
+		return big.NewInt(int64(v)), nil
+	case uint:
+		return new(big.Int).SetUint64(uint64(v)), nil
+	case uint64:
+		return new(big.Int).SetUint64(v), nil
+	case uint32:
+		return new(big.Int).SetUint64(uint64(v)), nil
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("%v is not an integer", v)
+		}
+		return big.NewInt(int64(v)), nil
+	case string:
+		n, ok := new(big.Int).SetString(strings.TrimSpace(v), 10)
+		if !ok {
+			return nil, fmt.Errorf("%q is not an integer", v)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("%v is not an integer", v)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfig_createTemplate_funcs(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "ipAdd", text: `{{ ipAdd 10 "10.0.0.250" }}`, want: "10.0.1.4"},
+		{name: "ipAdd_negative", text: `{{ ipAdd -1 "10.0.1.0" }}`, want: "10.0.0.255"},
+		{name: "ipAdd_ipv6", text: `{{ "2001:db8::ffff" | ipAdd 1 }}`, want: "2001:db8::1:0"},
+		{name: "ipAdd_overflow", text: `{{ ipAdd 1 "255.255.255.255" }}`, wantErr: true},
+		{name: "cidrHost", text: `{{ cidrHost 5 "10.1.2.0/24" }}`, want: "10.1.2.5"},
+		{name: "cidrHost_last", text: `{{ cidrHost -1 "10.1.2.0/24" }}`, want: "10.1.2.255"},
+		{name: "cidrHost_out_of_range", text: `{{ cidrHost 256 "10.1.2.0/24" }}`, wantErr: true},
+		{name: "cidrSubnets", text: `{{ join (cidrSubnets 2 "10.0.0.0/24") "," }}`, want: "10.0.0.0/26,10.0.0.64/26,10.0.0.128/26,10.0.0.192/26"},
+		{name: "cidrSubnets_too_long", text: `{{ cidrSubnets 2 "10.0.0.0/31" }}`, wantErr: true},
+		{name: "prefixRange", text: `{{ range prefixRange 3 "10.0.255.0/24" }}{{ . }} {{ end }}`, want: "10.0.255.0/24 10.1.0.0/24 10.1.1.0/24 "},
+		{name: "prefixRange_unmasked", text: `{{ join (prefixRange 2 "10.0.0.1/31") "," }}`, want: "10.0.0.0/31,10.0.0.2/31"},
+		{name: "prefixRange_overflow", text: `{{ prefixRange 2 "255.255.255.0/24" }}`, wantErr: true},
+		{name: "macAdd", text: `{{ macAdd 256 "00:00:5e:00:53:ff" }}`, want: "00:00:5e:00:54:ff"},
+		{name: "macAdd_overflow", text: `{{ macAdd 1 "ff:ff:ff:ff:ff:ff" }}`, wantErr: true},
+		{name: "uint128_small", text: `{{ uint128 7 }}`, want: "0:7"},
+		{name: "uint128_large", text: `{{ uint128 "18446744073709551617" }}`, want: "1:1"},
+		{name: "uint128_negative", text: `{{ uint128 -1 }}`, wantErr: true},
+		{name: "seq", text: `{{ range seq 3 }}{{ ipAdd . "10.0.0.0" }} {{ end }}`, want: "10.0.0.1 10.0.0.2 10.0.0.3 "},
+		{name: "lookup_without_data_file", text: `{{ lookup "r1" "loopback" }}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderTestTemplate(New(), tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("render error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("render = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_lookup(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"facts.yaml": `
+r1:
+  loopback: 10.255.0.1
+  asn: 65001
+r2:
+  loopback: 10.255.0.2
+`,
+		"facts.json": `{"r1": {"loopback": "10.255.0.1", "asn": 65001}, "r2": {"loopback": "10.255.0.2"}}`,
+		"facts.csv": `name,loopback,asn
+r1, 10.255.0.1, 65001
+r2, 10.255.0.2,
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name := range files {
+		t.Run(name, func(t *testing.T) {
+			c := New()
+			c.DataFile = filepath.Join(dir, name)
+			got, err := renderTestTemplate(c, `{{ lookup "r1" "loopback" }} {{ lookup "r1" "asn" }} {{ (lookup "r2").loopback }} {{ lookup "r2" "loopback" | ipAdd 1 }}`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := "10.255.0.1 65001 10.255.0.2 10.255.0.3"
+			if got != want {
+				t.Errorf("render = %q, want %q", got, want)
+			}
+			if _, err = renderTestTemplate(c, `{{ lookup "r3" "loopback" }}`); err == nil {
+				t.Errorf("expected an error for an unknown key")
+			}
+			if _, err = renderTestTemplate(c, `{{ lookup "r1" "bogus" }}`); err == nil {
+				t.Errorf("expected an error for an unknown field")
+			}
+		})
+	}
+}
+
+func renderTestTemplate(c *Config, text string) (string, error) {
+	tpl, err := c.createTemplate("test", text)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	err = tpl.Execute(buf, templateInput{TargetName: "r1"})
+	return buf.String(), err
+}
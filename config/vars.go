@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// environment variables prefix of the template variables
+	varsEnvPrefix = "GRIBIC_VAR_"
+	// default vars directory name, next to the template file
+	defaultVarsDir = "vars"
+	// group vars files sub directory of the vars directory
+	varsGroupsDir = "groups"
+)
+
+var varsFileExtensions = []string{".yaml", ".yml", ".json"}
+
+// TargetVars returns the template variables of target tc, merged from, in increasing order of precedence:
+//   - fileVars, read from the template vars file,
+//   - the vars files of the target groups, in the order of the target groups,
+//   - the target vars file,
+//   - the GRIBIC_VAR_<KEY> environment variables,
+//   - the --var key=value flags.
+//
+// The groups and target vars files are read from the vars directory of templateFile, see varsDir.
+// Maps are merged key by key, any other value is replaced.
+func (c *Config) TargetVars(tc *TargetConfig, templateFile string, fileVars map[string]interface{}) (map[string]interface{}, error) {
+	vars := mergeVars(nil, fileVars)
+	dir := c.varsDir(templateFile)
+	for _, g := range tc.Groups {
+		gv, err := readVarsDirFile(filepath.Join(dir, varsGroupsDir, g))
+		if err != nil {
+			return nil, fmt.Errorf("group %q vars: %v", g, err)
+		}
+		vars = mergeVars(vars, gv)
+	}
+	tv, err := readVarsDirFile(filepath.Join(dir, tc.Name))
+	if err != nil {
+		return nil, fmt.Errorf("target %q vars: %v", tc.Name, err)
+	}
+	vars = mergeVars(vars, tv)
+	vars = mergeVars(vars, envVars(os.Environ()))
+	fv, err := parseVarFlags(c.Vars)
+	if err != nil {
+		return nil, err
+	}
+	return mergeVars(vars, fv), nil
+}
+
+// varsDir returns the directory of the groups and targets vars files:
+// the --vars-dir value if set, the "vars" directory next to templateFile otherwise.
+func (c *Config) varsDir(templateFile string) string {
+	if c.VarsDir != "" {
+		return c.VarsDir
+	}
+	if templateFile == "" {
+		return defaultVarsDir
+	}
+	return filepath.Join(filepath.Dir(templateFile), defaultVarsDir)
+}
+
+// ReadTemplateVarsFile reads the vars file of templateFile,
+// the file with the same name and a _vars suffix, if it exists.
+func (c *Config) ReadTemplateVarsFile(templateFile string) (map[string]interface{}, error) {
+	ext := filepath.Ext(templateFile)
+	name := fmt.Sprintf("%s%s%s", templateFile[0:len(templateFile)-len(ext)], varFileSuffix, ext)
+	_, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return readVarsFile(name)
+}
+
+// readVarsDirFile reads the vars file name with one of the vars files extensions,
+// it returns nil if none exists.
+func readVarsDirFile(name string) (map[string]interface{}, error) {
+	for _, ext := range varsFileExtensions {
+		_, err := os.Stat(name + ext)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return readVarsFile(name + ext)
+	}
+	return nil, nil
+}
+
+func readVarsFile(name string) (map[string]interface{}, error) {
+	b, err := readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	err = yaml.Unmarshal(b, &v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	switch v := convertYAML(v).(type) {
+	case map[string]interface{}:
+		return v, nil
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%s: unexpected variables file format", name)
+}
+
+// envVars returns the variables set by the GRIBIC_VAR_<KEY> environment variables,
+// the key is lower cased.
+func envVars(environ []string) map[string]interface{} {
+	vars := make(map[string]interface{})
+	for _, e := range environ {
+		k, v, ok := strings.Cut(e, "=")
+		if !ok || !strings.HasPrefix(k, varsEnvPrefix) || len(k) == len(varsEnvPrefix) {
+			continue
+		}
+		vars[strings.ToLower(strings.TrimPrefix(k, varsEnvPrefix))] = parseVarValue(v)
+	}
+	return vars
+}
+
+// parseVarFlags parses the --var key=value flags,
+// a dotted key sets a nested variable, e.g: vrf.name=blue.
+func parseVarFlags(flags []string) (map[string]interface{}, error) {
+	vars := make(map[string]interface{})
+	for _, f := range flags {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid --var %q, expected key=value", f)
+		}
+		keys := strings.Split(k, ".")
+		m := vars
+		for _, key := range keys[:len(keys)-1] {
+			if key == "" {
+				return nil, fmt.Errorf("invalid --var %q: empty key", f)
+			}
+			sub, ok := m[key].(map[string]interface{})
+			if !ok {
+				sub = make(map[string]interface{})
+				m[key] = sub
+			}
+			m = sub
+		}
+		if keys[len(keys)-1] == "" {
+			return nil, fmt.Errorf("invalid --var %q: empty key", f)
+		}
+		m[keys[len(keys)-1]] = parseVarValue(v)
+	}
+	return vars, nil
+}
+
+// parseVarValue parses a variable value set on the command line or in the environment
+// as a yaml scalar, so that numbers and booleans keep their type.
+func parseVarValue(s string) interface{} {
+	var v interface{}
+	err := yaml.Unmarshal([]byte(s), &v)
+	if err != nil {
+		return s
+	}
+	switch v := convertYAML(v).(type) {
+	case nil:
+		return s
+	case map[string]interface{}, []interface{}:
+		// only scalars are parsed
+		return s
+	default:
+		return v
+	}
+}
+
+// mergeVars returns a copy of dst with the variables of src,
+// the nested maps are merged, the other values of src replace the ones of dst.
+func mergeVars(dst, src map[string]interface{}) map[string]interface{} {
+	r := make(map[string]interface{}, len(dst)+len(src))
+	for k, v := range dst {
+		r[k] = v
+	}
+	for k, v := range src {
+		sm, ok := v.(map[string]interface{})
+		if !ok {
+			r[k] = v
+			continue
+		}
+		dm, _ := r[k].(map[string]interface{})
+		r[k] = mergeVars(dm, sm)
+	}
+	return r
+}
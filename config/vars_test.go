@@ -0,0 +1,194 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseVarFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   []string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:  "typed_values",
+			flags: []string{"count=3", "enabled=true", "name=r1", "prefix=10.0.0.0/24", "election=1:2", "empty="},
+			want: map[string]interface{}{
+				"count":    3,
+				"enabled":  true,
+				"name":     "r1",
+				"prefix":   "10.0.0.0/24",
+				"election": "1:2",
+				"empty":    "",
+			},
+		},
+		{
+			name:  "nested",
+			flags: []string{"vrf.name=blue", "vrf.id=10", "nh=1"},
+			want: map[string]interface{}{
+				"vrf": map[string]interface{}{"name": "blue", "id": 10},
+				"nh":  1,
+			},
+		},
+		{
+			name:  "value_with_equal_sign",
+			flags: []string{"match=a=b"},
+			want:  map[string]interface{}{"match": "a=b"},
+		},
+		{name: "missing_value", flags: []string{"name"}, wantErr: true},
+		{name: "empty_key", flags: []string{"=1"}, wantErr: true},
+		{name: "empty_nested_key", flags: []string{"vrf..name=blue"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVarFlags(tt.flags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVarFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseVarFlags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	got := envVars([]string{"GRIBIC_VAR_NH_INDEX=5", "GRIBIC_VAR_VRF=blue", "GRIBIC_VAR_=x", "GRIBIC_ADDRESS=r1", "HOME=/root"})
+	want := map[string]interface{}{"nh_index": 5, "vrf": "blue"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("envVars() = %v, want %v", got, want)
+	}
+}
+
+func TestMergeVars(t *testing.T) {
+	dst := map[string]interface{}{
+		"a":   1,
+		"vrf": map[string]interface{}{"name": "red", "id": 1},
+		"l":   []interface{}{1, 2},
+	}
+	src := map[string]interface{}{
+		"b":   2,
+		"vrf": map[string]interface{}{"name": "blue"},
+		"l":   []interface{}{3},
+	}
+	got := mergeVars(dst, src)
+	want := map[string]interface{}{
+		"a":   1,
+		"b":   2,
+		"vrf": map[string]interface{}{"name": "blue", "id": 1},
+		"l":   []interface{}{3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeVars() = %v, want %v", got, want)
+	}
+	if dst["vrf"].(map[string]interface{})["name"] != "red" {
+		t.Errorf("mergeVars() modified its input: %v", dst)
+	}
+}
+
+func TestConfig_TargetVars(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"vars/groups/spine.yaml": "layer: group-spine\nspine: true\nvrf:\n  name: spine\n  id: 1\n",
+		"vars/groups/dc1.yml":    "layer: group-dc1\ndc: 1\n",
+		"vars/r1.json":           `{"layer": "target", "vrf": {"name": "r1"}}`,
+		"vars/r2.yaml":           "",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	templateFile := filepath.Join(dir, "modify.yaml")
+	fileVars := map[string]interface{}{"layer": "file", "file": true}
+	tests := []struct {
+		name    string
+		tc      *TargetConfig
+		env     map[string]string
+		flags   []string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "file_only",
+			tc:   &TargetConfig{Name: "r3"},
+			want: map[string]interface{}{"layer": "file", "file": true},
+		},
+		{
+			name: "groups_in_order",
+			tc:   &TargetConfig{Name: "r3", Groups: []string{"spine", "dc1"}},
+			want: map[string]interface{}{
+				"layer": "group-dc1", "file": true, "spine": true, "dc": 1,
+				"vrf": map[string]interface{}{"name": "spine", "id": 1},
+			},
+		},
+		{
+			name: "target_over_groups",
+			tc:   &TargetConfig{Name: "r1", Groups: []string{"spine", "dc1"}},
+			want: map[string]interface{}{
+				"layer": "target", "file": true, "spine": true, "dc": 1,
+				"vrf": map[string]interface{}{"name": "r1", "id": 1},
+			},
+		},
+		{
+			name:  "env_and_flags",
+			tc:    &TargetConfig{Name: "r1"},
+			env:   map[string]string{"GRIBIC_VAR_LAYER": "env", "GRIBIC_VAR_DC": "2"},
+			flags: []string{"vrf.id=7"},
+			want: map[string]interface{}{
+				"layer": "env", "file": true, "dc": 2,
+				"vrf": map[string]interface{}{"name": "r1", "id": 7},
+			},
+		},
+		{
+			name:  "flags_over_env",
+			tc:    &TargetConfig{Name: "r2"},
+			env:   map[string]string{"GRIBIC_VAR_LAYER": "env"},
+			flags: []string{"layer=flag"},
+			want:  map[string]interface{}{"layer": "flag", "file": true},
+		},
+		{
+			name:    "invalid_flag",
+			tc:      &TargetConfig{Name: "r1"},
+			flags:   []string{"layer"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			c := New()
+			c.Vars = tt.flags
+			got, err := c.TargetVars(tt.tc, templateFile, fileVars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TargetVars() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TargetVars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if fileVars["layer"] != "file" {
+		t.Errorf("TargetVars() modified the file vars: %v", fileVars)
+	}
+
+	c := New()
+	c.VarsDir = filepath.Join(dir, "missing")
+	got, err := c.TargetVars(&TargetConfig{Name: "r1", Groups: []string{"spine"}}, templateFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("TargetVars() with --vars-dir = %v, want no vars", got)
+	}
+}
@@ -2,11 +2,15 @@ package config
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/karimra/gnmic/utils"
@@ -16,34 +20,449 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+const (
+	OnFailureContinue = "continue"
+	OnFailureAbort    = "abort"
+	OnFailureRollback = "rollback"
+
+	checkpointFileSuffix = "_checkpoint"
+)
+
 type Workflow struct {
-	Name  string  `yaml:"name,omitempty"`
-	Steps []*step `yaml:"steps,omitempty"`
+	Name string `yaml:"name,omitempty"`
+	// bounds the workflow execution duration, per target
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// default steps failure policy: continue, abort or rollback.
+	// defaults to abort.
+	OnFailure string `yaml:"on-failure,omitempty"`
+	// target groups, a group name can be used as a step target
+	Groups map[string][]string `yaml:"groups,omitempty"`
+	Steps  []*Step             `yaml:"steps,omitempty"`
 }
 
-type step struct {
+type Step struct {
 	Name      string        `yaml:"name,omitempty"`
 	Wait      time.Duration `yaml:"wait,omitempty"`
 	WaitAfter time.Duration `yaml:"wait-after,omitempty"`
+	// bounds the step RPC(s) execution duration
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// step failure policy: continue, abort or rollback.
+	// defaults to the workflow's on-failure value.
+	OnFailure string `yaml:"on-failure,omitempty"`
 	// determines the RPC type
 	RPC string `yaml:"rpc,omitempty"`
-	// network instance, applies if RPC is "get" or "flush"
+	// target name, address or group the step runs on,
+	// the step runs on all targets if empty.
+	Target string `yaml:"target,omitempty"`
+	// barrier name, all targets running the workflow
+	// wait for each other at a barrier step.
+	Barrier string `yaml:"barrier,omitempty"`
+	// network instance, applies if RPC is "get" or "flush",
+	// and to "verify" and "wait-for" steps
 	NetworkInstance string `yaml:"network-instance,omitempty"`
-	// AFT type, applies if RPC is "get" or "flush"
+	// AFT type, applies if RPC is "get" or "flush",
+	// and to "verify" and "wait-for" steps
 	Aft string `yaml:"aft,omitempty"`
 	// Override, applies if RPC is "flush"
 	Override bool `yaml:"override,omitempty"`
 	// Session Parameters for "modify RPC"
-	SessionParams *sessionParams `yaml:"session-params,omitempty"`
+	SessionParams *SessionParams `yaml:"session-params,omitempty"`
 	// ElectionID for "modify" with session parameters and "flush" RPCs
 	ElectionID string `yaml:"election-id,omitempty"`
 	// Operations for "modify" RPC
 	Operations []*OperationConfig `yaml:"operations,omitempty"`
+	// Include replaces the step with the steps of another workflow file
+	Include *include `yaml:"include,omitempty"`
+	// Print renders a template and prints the result,
+	// it uses [[ ]] delimiters and is rendered when the step runs.
+	Print string `yaml:"print,omitempty"`
+	// Exec runs a local command
+	Exec *execCommand `yaml:"exec,omitempty"`
+	// Verify runs a Get RPC and compares the result with a list of expected entries
+	Verify *verify `yaml:"verify,omitempty"`
+	// WaitFor runs a Get RPC periodically until the condition holds
+	WaitFor *waitFor `yaml:"wait-for,omitempty"`
+	// Tags are used to select the steps to run or skip
+	Tags []string `yaml:"tags,omitempty"`
+	// Capture stores the result of a "get" or "exec" step under this name,
+	// it can be referenced by "print" steps as .Captures.<name>
+	Capture string `yaml:"capture,omitempty"`
+
+	// variables the step was rendered with,
+	// the include vars for the steps of an included workflow.
+	vars map[string]interface{}
+}
+
+type execCommand struct {
+	Command string   `yaml:"command,omitempty"`
+	Args    []string `yaml:"args,omitempty"`
+}
+
+type verify struct {
+	// expected entries, the operation field is ignored.
+	// entries without a network instance use the step's network-instance
+	// or the target's default network instance.
+	Entries []*OperationConfig `yaml:"entries,omitempty"`
+	// fail if the Get response contains entries not listed in Entries
+	Exact bool `yaml:"exact,omitempty"`
+	// verify that the entries are absent instead of present
+	Absent bool `yaml:"absent,omitempty"`
+	// expected number of entries in the Get response
+	Count *int `yaml:"count,omitempty"`
+}
+
+type waitFor struct {
+	verify `yaml:",inline"`
+	// polling interval, defaults to 1s
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+type include struct {
+	// path to the included workflow file,
+	// relative paths are resolved from the including file directory.
+	File string `yaml:"file,omitempty"`
+	// variables passed to the included workflow template,
+	// they override the caller's variables with the same name.
+	Vars map[string]interface{} `yaml:"vars,omitempty"`
+}
+
+// SetDefaults sets the default step names
+// ${workflow-name}.${idx} for unnamed steps,
+// as well as the default failure policy.
+func (w *Workflow) SetDefaults() {
+	w.OnFailure = strings.ToLower(w.OnFailure)
+	if w.OnFailure == "" {
+		w.OnFailure = OnFailureAbort
+	}
+	for i, s := range w.Steps {
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("%s.%d", w.Name, i+1)
+		}
+		s.OnFailure = strings.ToLower(s.OnFailure)
+		if s.OnFailure == "" {
+			s.OnFailure = w.OnFailure
+		}
+	}
+}
+
+// Validate checks the workflow steps without building any connection,
+// it returns all the problems found.
+func (w *Workflow) Validate() []error {
+	errs := make([]error, 0)
+	if len(w.Steps) == 0 {
+		errs = append(errs, fmt.Errorf("workflow %q has no steps", w.Name))
+	}
+	if w.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("workflow %q has a negative timeout", w.Name))
+	}
+	if err := validateOnFailure(w.OnFailure); err != nil {
+		errs = append(errs, fmt.Errorf("workflow %q: %w", w.Name, err))
+	}
+	names := make(map[string]int, len(w.Steps))
+	for i, s := range w.Steps {
+		if j, ok := names[s.Name]; ok {
+			errs = append(errs, fmt.Errorf("step %d %q: duplicate step name, already used by step %d", i+1, s.Name, j+1))
+		} else {
+			names[s.Name] = i
+		}
+		for _, err := range s.validate() {
+			errs = append(errs, fmt.Errorf("step %d %q: %w", i+1, s.Name, err))
+		}
+	}
+	return errs
+}
+
+// Kind returns the step kind: barrier, print, exec, verify, wait-for or the RPC type.
+func (s *Step) Kind() string {
+	switch {
+	case s.Barrier != "":
+		return "barrier"
+	case s.Print != "":
+		return "print"
+	case s.Exec != nil:
+		return "exec"
+	case s.Verify != nil:
+		return "verify"
+	case s.WaitFor != nil:
+		return "wait-for"
+	}
+	return strings.ToLower(s.RPC)
+}
+
+// Condition returns the verify or wait-for condition of the step, if any.
+func (s *Step) Condition() *verify {
+	switch {
+	case s.Verify != nil:
+		return s.Verify
+	case s.WaitFor != nil:
+		return &s.WaitFor.verify
+	}
+	return nil
+}
+
+// ExpectedEntries returns the AFT entries described by the condition,
+// entries without a network instance are placed in defaultNI.
+func (v *verify) ExpectedEntries(defaultNI string) ([]*spb.AFTEntry, error) {
+	entries := make([]*spb.AFTEntry, 0, len(v.Entries))
+	for i, oc := range v.Entries {
+		e, err := oc.CreateAFTEntry()
+		if err != nil {
+			return nil, fmt.Errorf("entry index %d: %w", i+1, err)
+		}
+		if e.GetNetworkInstance() == "" {
+			e.NetworkInstance = defaultNI
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// RenderStepTemplate renders the "print" template of step s for target tc,
+// captures holds the values captured by the previous steps.
+func (c *Config) RenderStepTemplate(s *Step, tc *TargetConfig, captures map[string]interface{}) (string, error) {
+	tpl, err := NewStepTemplate(s.Name, s.Print)
+	if err != nil {
+		return "", err
+	}
+	vars := s.vars
+	if vars == nil {
+		vars, err = c.TargetVars(tc, c.WorkflowFile, c.workflowVars)
+		if err != nil {
+			return "", err
+		}
+	}
+	buf := new(bytes.Buffer)
+	err = tpl.Execute(buf,
+		templateInput{
+			TargetName: tc.Name,
+			Vars:       vars,
+			Captures:   captures,
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// NewStepTemplate parses the text of a "print" step.
+func NewStepTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).
+		Option("missingkey=zero").
+		Delims("[[", "]]").
+		Parse(text)
 }
 
-func (s *step) BuildRequests() ([]proto.Message, error) {
-	switch strings.ToLower(s.RPC) {
+// StepSelector selects the workflow steps to run,
+// using step names, include prefixes or tags.
+type StepSelector struct {
+	// the steps before the first step matching StartAt are not run
+	StartAt string
+	// if not empty, only the steps matching one of the values are run
+	Only []string
+	// the steps matching one of the values are not run
+	Skip []string
+}
+
+// Select returns, for each workflow step, true if the step is selected.
+// Barrier steps are always selected to keep the targets in sync.
+func (w *Workflow) Select(sel *StepSelector) ([]bool, error) {
+	selected := make([]bool, len(w.Steps))
+	started := sel.StartAt == ""
+	for i, s := range w.Steps {
+		if !started && s.matches(sel.StartAt) {
+			started = true
+		}
+		switch {
+		case s.Kind() == "barrier":
+			selected[i] = true
+		case !started:
+		case len(sel.Only) > 0 && !s.matchesAny(sel.Only):
+		case s.matchesAny(sel.Skip):
+		default:
+			selected[i] = true
+		}
+	}
+	if !started {
+		return nil, fmt.Errorf("workflow %q: start-at %q does not match any step", w.Name, sel.StartAt)
+	}
+	return selected, nil
+}
+
+// matches returns true if v is the step name, the name of an include
+// the step belongs to, or one of the step tags.
+func (s *Step) matches(v string) bool {
+	if s.Name == v || strings.HasPrefix(s.Name, v+"/") {
+		return true
+	}
+	for _, tag := range s.Tags {
+		if tag == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Step) matchesAny(vs []string) bool {
+	for _, v := range vs {
+		if s.matches(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Digest returns a hash of the rendered workflow,
+// it identifies the workflow when resuming a previous run.
+func (w *Workflow) Digest() (string, error) {
+	b, err := yaml.Marshal(w)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b)), nil
+}
+
+// RunsOn returns true if the step applies to the target tc,
+// based on the step's target name, address or group.
+func (w *Workflow) RunsOn(s *Step, tc *TargetConfig) bool {
+	if s.Target == "" {
+		return true
+	}
+	if matchTarget(s.Target, tc) {
+		return true
+	}
+	for _, name := range w.Groups[s.Target] {
+		if matchTarget(name, tc) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchTarget(name string, tc *TargetConfig) bool {
+	if name == tc.Name || name == tc.Address {
+		return true
+	}
+	host, _, err := net.SplitHostPort(tc.Address)
+	return err == nil && name == host
+}
+
+func (s *Step) validate() []error {
+	errs := make([]error, 0)
+	var numKinds int
+	for _, set := range []bool{s.RPC != "", s.Barrier != "", s.Print != "", s.Exec != nil, s.Verify != nil, s.WaitFor != nil} {
+		if set {
+			numKinds++
+		}
+	}
+	if numKinds > 1 {
+		errs = append(errs, errors.New("a step must set only one of: rpc, barrier, print, exec, verify or wait-for"))
+	}
+	if s.Capture != "" {
+		switch s.Kind() {
+		case "get", "exec":
+		default:
+			errs = append(errs, fmt.Errorf("capture is not supported by %q steps", s.Kind()))
+		}
+	}
+	switch s.Kind() {
+	case "barrier":
+		if s.Target != "" {
+			errs = append(errs, errors.New("a barrier step cannot set a target"))
+		}
+		return errs
+	case "print":
+		if _, err := NewStepTemplate(s.Name, s.Print); err != nil {
+			errs = append(errs, fmt.Errorf("invalid print template: %w", err))
+		}
+	case "exec":
+		if s.Exec.Command == "" {
+			errs = append(errs, errors.New("exec step has no command"))
+		}
+	case "verify", "wait-for":
+		if s.Aft != "" {
+			_, err := api.NewGetRequest(api.AFTType(s.Aft))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid aft %q: %w", s.Aft, err))
+			}
+		}
+		v := s.Condition()
+		if len(v.Entries) == 0 && v.Count == nil {
+			errs = append(errs, fmt.Errorf("%s step has neither entries nor count set", s.Kind()))
+		}
+		if v.Count != nil && *v.Count < 0 {
+			errs = append(errs, errors.New("negative count"))
+		}
+		for i, oc := range v.Entries {
+			if _, err := oc.CreateAFTEntry(); err != nil {
+				errs = append(errs, fmt.Errorf("entry index %d is invalid: %w", i+1, err))
+			}
+		}
+		if s.WaitFor != nil && s.WaitFor.Interval < 0 {
+			errs = append(errs, errors.New("negative wait-for interval"))
+		}
 	case "get":
+		if s.Aft != "" {
+			_, err := api.NewGetRequest(api.AFTType(s.Aft))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid aft %q: %w", s.Aft, err))
+			}
+		}
+	case "flush":
+		if !s.Override && s.ElectionID == "" {
+			errs = append(errs, errors.New("flush step has neither override nor election-id set"))
+		}
+	case "modify":
+		for i, op := range s.Operations {
+			err := op.validate()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("operation index %d is invalid: %w", i+1, err))
+			}
+			_, err = ParseUint128(op.ElectionID)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("operation index %d has an invalid election-id %q: %w", i+1, op.ElectionID, err))
+			}
+		}
+	case "":
+		return append(errs, errors.New("missing rpc"))
+	default:
+		return append(errs, fmt.Errorf("unknown rpc %q", s.RPC))
+	}
+	_, err := ParseUint128(s.ElectionID)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid election-id %q: %w", s.ElectionID, err))
+	}
+	if s.Timeout < 0 {
+		errs = append(errs, errors.New("negative timeout"))
+	}
+	err = validateOnFailure(s.OnFailure)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	// catch errors only detected when building the requests,
+	// e.g: invalid operation type or entry attribute.
+	_, err = s.BuildRequests()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func validateOnFailure(p string) error {
+	switch p {
+	case "", OnFailureContinue, OnFailureAbort, OnFailureRollback:
+		return nil
+	}
+	return fmt.Errorf("unknown on-failure policy %q, expected one of: %s, %s or %s",
+		p, OnFailureContinue, OnFailureAbort, OnFailureRollback)
+}
+
+func (s *Step) BuildRequests() ([]proto.Message, error) {
+	switch s.Kind() {
+	case "get", "verify", "wait-for":
 		return s.buildGetRequest()
 	case "flush":
 		return s.buildFlushRequest()
@@ -53,7 +472,7 @@ func (s *step) BuildRequests() ([]proto.Message, error) {
 	return nil, nil
 }
 
-func (s *step) buildGetRequest() ([]proto.Message, error) {
+func (s *Step) buildGetRequest() ([]proto.Message, error) {
 	opts := make([]api.GRIBIOption, 0)
 	if s.NetworkInstance == "" {
 		opts = append(opts, api.NSAll())
@@ -73,7 +492,7 @@ func (s *step) buildGetRequest() ([]proto.Message, error) {
 	return []proto.Message{req}, nil
 }
 
-func (s *step) buildFlushRequest() ([]proto.Message, error) {
+func (s *Step) buildFlushRequest() ([]proto.Message, error) {
 	opts := make([]api.GRIBIOption, 0, 2)
 	if s.NetworkInstance == "" {
 		opts = append(opts, api.NSAll())
@@ -96,7 +515,7 @@ func (s *step) buildFlushRequest() ([]proto.Message, error) {
 	return []proto.Message{req}, nil
 }
 
-func (s *step) buildModifyRequest() ([]proto.Message, error) {
+func (s *Step) buildModifyRequest() ([]proto.Message, error) {
 	reqs := make([]proto.Message, 0, 2)
 	opts := make([]api.GRIBIOption, 0, 4)
 	if s.SessionParams != nil {
@@ -161,13 +580,23 @@ func (c *Config) ReadWorkflowFile() error {
 		return err
 	}
 
-	c.workflowTemplate, err = utils.CreateTemplate("workflow-template", string(b))
+	c.workflowTemplate, err = c.createTemplate("workflow-template", string(b))
 	if err != nil {
 		return err
 	}
 	return c.readWorkflowTemplateVarsFile()
 }
 
+// WorkflowCheckpointFile returns the path of the workflow checkpoint file,
+// it defaults to the workflow file path with a "_checkpoint.json" suffix.
+func (c *Config) WorkflowCheckpointFile() string {
+	if c.WorkflowCheckpoint != "" {
+		return c.WorkflowCheckpoint
+	}
+	ext := filepath.Ext(c.WorkflowFile)
+	return fmt.Sprintf("%s%s.json", c.WorkflowFile[0:len(c.WorkflowFile)-len(ext)], checkpointFileSuffix)
+}
+
 func (c *Config) readWorkflowTemplateVarsFile() error {
 	if c.WorkflowInputVarsFile == "" {
 		ext := filepath.Ext(c.WorkflowFile)
@@ -206,12 +635,148 @@ func (c *Config) readWorkflowTemplateVarsFile() error {
 	return nil
 }
 
-func (c *Config) GenerateWorkflow(targetName string) (*Workflow, error) {
+func (c *Config) GenerateWorkflow(tc *TargetConfig) (*Workflow, error) {
+	vars, err := c.TargetVars(tc, c.WorkflowFile, c.workflowVars)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	err = c.workflowTemplate.Execute(buf,
+		templateInput{
+			TargetName: tc.Name,
+			Vars:       vars,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	wf := new(Workflow)
+	err = yaml.Unmarshal(buf.Bytes(), wf)
+	if err != nil {
+		return nil, err
+	}
+	absPath, err := filepath.Abs(c.WorkflowFile)
+	if err != nil {
+		return nil, err
+	}
+	err = c.expandIncludes(wf, tc.Name, vars, []string{absPath})
+	if err != nil {
+		return nil, err
+	}
+	return wf, nil
+}
+
+// expandIncludes replaces the include steps of the workflow with the steps
+// of the included workflow files, rendered using the caller's vars
+// merged with the include vars.
+// The included step names are prefixed with the include step name,
+// or the included workflow name.
+// chain is the list of files being included, it's used to detect cyclic includes.
+func (c *Config) expandIncludes(wf *Workflow, targetName string, vars map[string]interface{}, chain []string) error {
+	steps := make([]*Step, 0, len(wf.Steps))
+	for i, s := range wf.Steps {
+		if s.Include == nil {
+			// name the step before the include expansion changes its index
+			if s.Name == "" {
+				s.Name = fmt.Sprintf("%s.%d", wf.Name, i+1)
+			}
+			s.vars = vars
+			steps = append(steps, s)
+			continue
+		}
+		if s.Include.File == "" {
+			return fmt.Errorf("workflow %q: step %d: include is missing a file", wf.Name, i+1)
+		}
+		if s.RPC != "" {
+			return fmt.Errorf("workflow %q: step %d: an include step cannot set an rpc", wf.Name, i+1)
+		}
+		file := s.Include.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(chain[len(chain)-1]), file)
+		}
+		for _, f := range chain {
+			if f == file {
+				return fmt.Errorf("cyclic workflow include: %s -> %s", strings.Join(chain, " -> "), file)
+			}
+		}
+		c.logger.Debugf("workflow %q: step %d: including file %q", wf.Name, i+1, file)
+		ivars := make(map[string]interface{}, len(vars)+len(s.Include.Vars))
+		for k, v := range vars {
+			ivars[k] = v
+		}
+		for k, v := range s.Include.Vars {
+			ivars[k] = utils.Convert(v)
+		}
+		iwf, err := c.renderIncludedWorkflow(file, targetName, ivars)
+		if err != nil {
+			return fmt.Errorf("workflow %q: step %d: failed to include %q: %w", wf.Name, i+1, file, err)
+		}
+		if iwf.Name == "" {
+			iwf.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+		if iwf.Timeout > 0 {
+			return fmt.Errorf("workflow %q: step %d: included workflow %q cannot set a timeout, set it on its steps or on the including workflow", wf.Name, i+1, iwf.Name)
+		}
+		// extend the include chain with a copy to not alter the sibling includes chains
+		ichain := make([]string, len(chain), len(chain)+1)
+		copy(ichain, chain)
+		err = c.expandIncludes(iwf, targetName, ivars, append(ichain, file))
+		if err != nil {
+			return err
+		}
+		err = mergeGroups(wf, iwf)
+		if err != nil {
+			return fmt.Errorf("workflow %q: step %d: %v", wf.Name, i+1, err)
+		}
+		prefix := s.Name
+		if prefix == "" {
+			prefix = iwf.Name
+		}
+		for _, is := range iwf.Steps {
+			is.Name = prefix + "/" + is.Name
+			is.Tags = append(is.Tags, s.Tags...)
+			if is.OnFailure == "" {
+				is.OnFailure = iwf.OnFailure
+			}
+			steps = append(steps, is)
+		}
+	}
+	wf.Steps = steps
+	return nil
+}
+
+// mergeGroups adds the target groups of the included workflow iwf to wf,
+// a group defined in both with different members is an error.
+func mergeGroups(wf, iwf *Workflow) error {
+	for name, members := range iwf.Groups {
+		if wfMembers, ok := wf.Groups[name]; ok {
+			if !reflect.DeepEqual(wfMembers, members) {
+				return fmt.Errorf("included workflow %q redefines group %q", iwf.Name, name)
+			}
+			continue
+		}
+		if wf.Groups == nil {
+			wf.Groups = make(map[string][]string)
+		}
+		wf.Groups[name] = members
+	}
+	return nil
+}
+
+func (c *Config) renderIncludedWorkflow(file, targetName string, vars map[string]interface{}) (*Workflow, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := c.createTemplate(file, string(b))
+	if err != nil {
+		return nil, err
+	}
 	buf := new(bytes.Buffer)
-	err := c.workflowTemplate.Execute(buf,
+	err = tpl.Execute(buf,
 		templateInput{
 			TargetName: targetName,
-			Vars:       c.workflowVars,
+			Vars:       vars,
 		},
 	)
 	if err != nil {
@@ -219,6 +784,8 @@ func (c *Config) GenerateWorkflow(targetName string) (*Workflow, error) {
 	}
 	wf := new(Workflow)
 	err = yaml.Unmarshal(buf.Bytes(), wf)
-	// fmt.Printf("workflow for target=%q: %+v\n", targetName, wf)
-	return wf, err
+	if err != nil {
+		return nil, err
+	}
+	return wf, nil
 }
@@ -0,0 +1,517 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWorkflow_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		wf      *Workflow
+		numErrs int
+	}{
+		{
+			name:    "no_steps",
+			wf:      &Workflow{Name: "wf"},
+			numErrs: 1,
+		},
+		{
+			name: "valid",
+			wf: &Workflow{
+				Name: "wf",
+				Steps: []*Step{
+					{
+						RPC: "get",
+						Aft: "ipv4",
+					},
+					{
+						RPC:      "flush",
+						Override: true,
+					},
+					{
+						RPC:        "modify",
+						ElectionID: "1:2",
+						Operations: []*OperationConfig{
+							{
+								Operation:       "add",
+								NetworkInstance: "default",
+								NH:              &nhEntry{Index: 1},
+							},
+						},
+					},
+				},
+			},
+			numErrs: 0,
+		},
+		{
+			name: "unknown_rpc",
+			wf: &Workflow{
+				Name: "wf",
+				Steps: []*Step{
+					{RPC: "modfy"},
+					{},
+				},
+			},
+			numErrs: 2,
+		},
+		{
+			name: "invalid_aft",
+			wf: &Workflow{
+				Name: "wf",
+				Steps: []*Step{
+					{
+						RPC: "get",
+						Aft: "ipv5",
+					},
+				},
+			},
+			numErrs: 1,
+		},
+		{
+			name: "flush_without_election",
+			wf: &Workflow{
+				Name: "wf",
+				Steps: []*Step{
+					{RPC: "flush"},
+				},
+			},
+			numErrs: 1,
+		},
+		{
+			name: "bad_election_id",
+			wf: &Workflow{
+				Name: "wf",
+				Steps: []*Step{
+					{
+						RPC:        "flush",
+						ElectionID: "1:x",
+					},
+				},
+			},
+			numErrs: 1,
+		},
+		{
+			name: "invalid_operations",
+			wf: &Workflow{
+				Name: "wf",
+				Steps: []*Step{
+					{
+						RPC: "modify",
+						Operations: []*OperationConfig{
+							{
+								Operation: "add",
+							},
+							{
+								Operation:  "add",
+								NH:         &nhEntry{Index: 1},
+								NHG:        &nhgEntry{ID: 1},
+								ElectionID: "-1",
+							},
+						},
+					},
+				},
+			},
+			numErrs: 3,
+		},
+		{
+			name: "invalid_operation_type",
+			wf: &Workflow{
+				Name: "wf",
+				Steps: []*Step{
+					{
+						RPC: "modify",
+						Operations: []*OperationConfig{
+							{
+								Operation: "insert",
+								NH:        &nhEntry{Index: 1},
+							},
+						},
+					},
+				},
+			},
+			numErrs: 1,
+		},
+		{
+			name: "valid_step_kinds",
+			wf: &Workflow{
+				Name: "wf",
+				Steps: []*Step{
+					{
+						Exec:    &execCommand{Command: "echo", Args: []string{"hi"}},
+						Capture: "greeting",
+					},
+					{Print: "[[ .TargetName ]]: [[ .Captures.greeting ]]"},
+					{
+						Verify: &verify{
+							Entries: []*OperationConfig{
+								{NH: &nhEntry{Index: 1}},
+							},
+							Exact: true,
+						},
+					},
+					{
+						Aft:     "nhg",
+						WaitFor: &waitFor{verify: verify{Count: new(int)}},
+					},
+				},
+			},
+			numErrs: 0,
+		},
+		{
+			name: "invalid_step_kinds",
+			wf: &Workflow{
+				Name: "wf",
+				Steps: []*Step{
+					{RPC: "get", Print: "x"},
+					{Print: "[[ .TargetName "},
+					{Print: "x", Capture: "x"},
+					{Exec: &execCommand{}},
+					{Verify: &verify{}},
+					{
+						WaitFor: &waitFor{
+							verify: verify{
+								Entries: []*OperationConfig{
+									{NH: &nhEntry{Index: 1}, NHG: &nhgEntry{ID: 1}},
+								},
+							},
+							Interval: -1,
+						},
+					},
+				},
+			},
+			numErrs: 7,
+		},
+		{
+			name: "duplicate_names",
+			wf: &Workflow{
+				Name: "wf",
+				Steps: []*Step{
+					{Name: "get", RPC: "get"},
+					{Name: "get", RPC: "get"},
+				},
+			},
+			numErrs: 1,
+		},
+		{
+			name: "unknown_on_failure",
+			wf: &Workflow{
+				Name:      "wf",
+				OnFailure: "retry",
+				Steps: []*Step{
+					{
+						RPC:       "get",
+						OnFailure: "ignore",
+					},
+					{
+						RPC:     "get",
+						Timeout: -1,
+					},
+				},
+			},
+			numErrs: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.wf.SetDefaults()
+			if errs := tt.wf.Validate(); len(errs) != tt.numErrs {
+				t.Errorf("Validate() = %v, want %d error(s)", errs, tt.numErrs)
+			}
+		})
+	}
+}
+
+func TestConfig_GenerateWorkflow_include(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"wf.yaml": `
+name: wf
+steps:
+  - name: vrf1
+    include:
+      file: lib/vrf.yaml
+      vars:
+        ni: vrf1
+  - include:
+      file: lib/vrf.yaml
+      vars:
+        ni: vrf2
+  - rpc: get
+`,
+		"lib/vrf.yaml": `
+name: vrf-baseline
+on-failure: rollback
+steps:
+  - rpc: flush
+    override: true
+    network-instance: {{ .Vars.ni }}
+  - name: nh
+    include:
+      file: nh.yaml
+`,
+		"lib/nh.yaml": `
+steps:
+  - rpc: modify
+    operations:
+      - op: add
+        network-instance: {{ .Vars.ni }}
+        nh:
+          index: {{ .Vars.index }}
+`,
+		"cyclic.yaml": `
+steps:
+  - include:
+      file: lib/cyclic.yaml
+`,
+		"lib/cyclic.yaml": `
+steps:
+  - include:
+      file: ../cyclic.yaml
+`,
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := New()
+	c.SetLogger()
+	c.WorkflowFile = filepath.Join(dir, "wf.yaml")
+	c.WorkflowInputVarsFile = ""
+	if err := c.ReadWorkflowFile(); err != nil {
+		t.Fatal(err)
+	}
+	c.workflowVars = map[string]interface{}{"index": 42}
+	wf, err := c.GenerateWorkflow(&TargetConfig{Name: "target1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantNames := []string{
+		"vrf1/vrf-baseline.1",
+		"vrf1/nh/nh.1",
+		"vrf-baseline/vrf-baseline.1",
+		"vrf-baseline/nh/nh.1",
+		"wf.3",
+	}
+	wf.SetDefaults()
+	if len(wf.Steps) != len(wantNames) {
+		t.Fatalf("got %d steps, want %d", len(wf.Steps), len(wantNames))
+	}
+	for i, s := range wf.Steps {
+		if s.Name != wantNames[i] {
+			t.Errorf("step %d: name = %q, want %q", i, s.Name, wantNames[i])
+		}
+	}
+	if wf.Steps[2].NetworkInstance != "vrf2" {
+		t.Errorf("step 2: network-instance = %q, want %q", wf.Steps[2].NetworkInstance, "vrf2")
+	}
+	if op := wf.Steps[1].Operations[0]; op.NetworkInstance != "vrf1" || op.NH.Index != 42 {
+		t.Errorf("step 1: unexpected operation: %v", op)
+	}
+	if wf.Steps[1].OnFailure != OnFailureRollback || wf.Steps[4].OnFailure != OnFailureAbort {
+		t.Errorf("unexpected on-failure values: %q, %q", wf.Steps[1].OnFailure, wf.Steps[4].OnFailure)
+	}
+
+	c.WorkflowFile = filepath.Join(dir, "cyclic.yaml")
+	if err := c.ReadWorkflowFile(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.GenerateWorkflow(&TargetConfig{Name: "target1"}); err == nil {
+		t.Errorf("expected a cyclic include error")
+	}
+}
+
+func TestConfig_GenerateWorkflow_include_groups_vars(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"wf.yaml": `
+name: wf
+groups:
+  spines: [router1]
+steps:
+  - name: vrf1
+    include:
+      file: lib/vrf.yaml
+      vars:
+        ni: vrf1
+  - print: "top [[ .Vars.ni ]]"
+`,
+		"lib/vrf.yaml": `
+groups:
+  spines: [router1]
+  leaves: [router2]
+steps:
+  - print: "included [[ .Vars.ni ]]"
+    target: leaves
+`,
+		"conflict.yaml": `
+groups:
+  leaves: [router3]
+steps:
+  - include:
+      file: lib/vrf.yaml
+`,
+		"timeout.yaml": `
+steps:
+  - include:
+      file: lib/timeout.yaml
+`,
+		"lib/timeout.yaml": `
+timeout: 10s
+steps:
+  - rpc: get
+`,
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := New()
+	c.SetLogger()
+	c.WorkflowFile = filepath.Join(dir, "wf.yaml")
+	if err := c.ReadWorkflowFile(); err != nil {
+		t.Fatal(err)
+	}
+	c.workflowVars = map[string]interface{}{"ni": "default"}
+	tc := &TargetConfig{Name: "router2"}
+	wf, err := c.GenerateWorkflow(tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantGroups := map[string][]string{"spines": {"router1"}, "leaves": {"router2"}}
+	if !reflect.DeepEqual(wf.Groups, wantGroups) {
+		t.Errorf("groups = %v, want %v", wf.Groups, wantGroups)
+	}
+	if len(wf.Steps) != 2 {
+		t.Fatalf("got %d steps, want 2", len(wf.Steps))
+	}
+	if !wf.RunsOn(wf.Steps[0], tc) {
+		t.Errorf("included step does not run on the included group member")
+	}
+	for i, want := range []string{"included vrf1", "top default"} {
+		got, err := c.RenderStepTemplate(wf.Steps[i], tc, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("step %d: print = %q, want %q", i, got, want)
+		}
+	}
+
+	for _, name := range []string{"conflict.yaml", "timeout.yaml"} {
+		c.WorkflowFile = filepath.Join(dir, name)
+		if err := c.ReadWorkflowFile(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = c.GenerateWorkflow(tc); err == nil {
+			t.Errorf("%s: expected an include error", name)
+		}
+	}
+}
+
+func TestWorkflow_RunsOn(t *testing.T) {
+	wf := &Workflow{
+		Groups: map[string][]string{
+			"group-b": {"router2"},
+		},
+	}
+	tc1 := &TargetConfig{Name: "router1", Address: "10.0.0.1:57400"}
+	tc2 := &TargetConfig{Name: "router2", Address: "10.0.0.2:57400"}
+	tests := []struct {
+		name   string
+		target string
+		want   []bool
+	}{
+		{name: "all", target: "", want: []bool{true, true}},
+		{name: "name", target: "router1", want: []bool{true, false}},
+		{name: "address", target: "10.0.0.2:57400", want: []bool{false, true}},
+		{name: "host", target: "10.0.0.1", want: []bool{true, false}},
+		{name: "group", target: "group-b", want: []bool{false, true}},
+		{name: "unknown", target: "router3", want: []bool{false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Step{Target: tt.target}
+			for i, tc := range []*TargetConfig{tc1, tc2} {
+				if got := wf.RunsOn(s, tc); got != tt.want[i] {
+					t.Errorf("RunsOn(%q) = %v, want %v", tc.Name, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWorkflow_Select(t *testing.T) {
+	wf := &Workflow{
+		Name: "wf",
+		Steps: []*Step{
+			{Name: "setup", RPC: "modify", Tags: []string{"session"}},
+			{Name: "vrf1/flush", RPC: "flush", Override: true},
+			{Name: "vrf1/nh", RPC: "modify", Tags: []string{"program"}},
+			{Name: "sync", Barrier: "sync"},
+			{Name: "check", RPC: "get", Tags: []string{"check"}},
+		},
+	}
+	tests := []struct {
+		name    string
+		sel     *StepSelector
+		want    []bool
+		wantErr bool
+	}{
+		{
+			name: "all",
+			sel:  &StepSelector{},
+			want: []bool{true, true, true, true, true},
+		},
+		{
+			name: "start_at_include",
+			sel:  &StepSelector{StartAt: "vrf1"},
+			want: []bool{false, true, true, true, true},
+		},
+		{
+			name: "start_at_tag",
+			sel:  &StepSelector{StartAt: "check"},
+			want: []bool{false, false, false, true, true},
+		},
+		{
+			name: "only",
+			sel:  &StepSelector{Only: []string{"session", "check"}},
+			want: []bool{true, false, false, true, true},
+		},
+		{
+			name: "skip",
+			sel:  &StepSelector{Skip: []string{"vrf1/flush", "sync"}},
+			want: []bool{true, false, true, true, true},
+		},
+		{
+			name:    "unknown_start_at",
+			sel:     &StepSelector{StartAt: "vrf2"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := wf.Select(tt.sel)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Select() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) && !tt.wantErr {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
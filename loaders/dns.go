@@ -0,0 +1,67 @@
+package loaders
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/karimra/gribic/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// dnsLoader resolves the targets from DNS SRV records,
+// each record gives the host and port of a target.
+type dnsLoader struct {
+	cfg      *config.LoaderConfig
+	resolver *net.Resolver
+	logger   *log.Entry
+}
+
+func newDNSLoader(lc *config.LoaderConfig, logger *log.Entry) *dnsLoader {
+	l := &dnsLoader{
+		cfg:      lc,
+		resolver: net.DefaultResolver,
+		logger:   logger,
+	}
+	if lc.Server != "" {
+		server := lc.Server
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		l.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{Timeout: lc.Timeout}
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return l
+}
+
+func (l *dnsLoader) Load(ctx context.Context) ([]*config.TargetConfig, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.cfg.Timeout)
+	defer cancel()
+	_, srvs, err := l.resolver.LookupSRV(ctx, "", "", l.cfg.Service)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make(map[string]int, len(srvs))
+	for _, srv := range srvs {
+		hosts[srv.Target]++
+	}
+	tcs := make([]*config.TargetConfig, 0, len(srvs))
+	for _, srv := range srvs {
+		host := strings.TrimSuffix(srv.Target, ".")
+		tc := &config.TargetConfig{
+			Address: net.JoinHostPort(host, strconv.Itoa(int(srv.Port))),
+		}
+		// a host with multiple records is named after its address
+		if hosts[srv.Target] == 1 {
+			tc.Name = host
+		}
+		tcs = append(tcs, tc)
+	}
+	return tcs, nil
+}
@@ -0,0 +1,80 @@
+package loaders
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/karimra/gribic/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// events received within fileDebounce are coalesced,
+// editors write a file in multiple steps.
+const fileDebounce = 200 * time.Millisecond
+
+// fileLoader reads the targets from a yaml, json or csv file.
+type fileLoader struct {
+	cfg    *config.LoaderConfig
+	logger *log.Entry
+}
+
+func (l *fileLoader) Load(ctx context.Context) ([]*config.TargetConfig, error) {
+	b, err := os.ReadFile(l.cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	return config.ParseTargets(b, l.cfg.Format)
+}
+
+// notify watches the file's directory rather than the file,
+// so that a file replaced by a rename is still watched.
+func (l *fileLoader) notify(ctx context.Context) (<-chan struct{}, error) {
+	path, err := filepath.Abs(l.cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	err = w.Add(filepath.Dir(path))
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+		defer w.Close()
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) != path {
+					continue
+				}
+				l.logger.Debugf("loader: %s: %s", ev.Name, ev.Op)
+				debounce = time.After(fileDebounce)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				l.logger.Errorf("loader: watch %s: %v", path, err)
+			case <-debounce:
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,57 @@
+package loaders
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/karimra/gribic/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// httpLoader gets the targets in JSON from an HTTP endpoint.
+type httpLoader struct {
+	cfg    *config.LoaderConfig
+	client *http.Client
+	logger *log.Entry
+}
+
+func newHTTPLoader(lc *config.LoaderConfig, logger *log.Entry) *httpLoader {
+	return &httpLoader{
+		cfg: lc,
+		client: &http.Client{
+			Timeout: lc.Timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: lc.SkipVerify},
+			},
+		},
+		logger: logger,
+	}
+}
+
+func (l *httpLoader) Load(ctx context.Context) ([]*config.TargetConfig, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.cfg.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range l.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	rsp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %s", l.cfg.URL, rsp.Status)
+	}
+	return config.ParseTargets(b, config.TargetsFormatJSON)
+}
@@ -0,0 +1,114 @@
+// Package loaders loads gRIBI targets from an inventory:
+// a targets file, an HTTP endpoint or DNS SRV records.
+package loaders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/karimra/gribic/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Loader returns the targets of an inventory.
+type Loader interface {
+	// Load returns the targets currently in the inventory,
+	// their defaults are not set.
+	Load(ctx context.Context) ([]*config.TargetConfig, error)
+}
+
+// notifier is implemented by the loaders that can detect
+// changes to their inventory instead of polling it.
+type notifier interface {
+	// notify returns a channel signaled when the inventory may have changed.
+	notify(ctx context.Context) (<-chan struct{}, error)
+}
+
+// New returns the loader configured by lc.
+func New(lc *config.LoaderConfig, logger *log.Entry) (Loader, error) {
+	switch lc.Type {
+	case config.LoaderTypeFile:
+		return &fileLoader{cfg: lc, logger: logger}, nil
+	case config.LoaderTypeHTTP:
+		return newHTTPLoader(lc, logger), nil
+	case config.LoaderTypeDNS:
+		return newDNSLoader(lc, logger), nil
+	}
+	return nil, fmt.Errorf("unknown loader type %q", lc.Type)
+}
+
+// Watch loads the targets of l, then every time its inventory changes, or every interval
+// if it cannot detect changes, and sends the targets list on the returned channel when it differs
+// from the previous one.
+// A failed load is logged and the previous targets are kept.
+// The channel is closed when ctx is done.
+func Watch(ctx context.Context, l Loader, interval time.Duration, logger *log.Entry) <-chan []*config.TargetConfig {
+	ch := make(chan []*config.TargetConfig)
+	go func() {
+		defer close(ch)
+		var trigger <-chan struct{}
+		if n, ok := l.(notifier); ok {
+			var err error
+			trigger, err = n.notify(ctx)
+			if err != nil {
+				logger.Warnf("loader: cannot watch for changes, polling every %s: %v", interval, err)
+				trigger = nil
+			}
+		}
+		var tick <-chan time.Time
+		if trigger == nil {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		var last string
+		load := func() {
+			tcs, err := l.Load(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Errorf("loader: failed to load targets: %v", err)
+				}
+				return
+			}
+			fp := fingerprint(tcs)
+			if fp == last {
+				return
+			}
+			last = fp
+			select {
+			case ch <- tcs:
+			case <-ctx.Done():
+			}
+		}
+		load()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-trigger:
+				if !ok {
+					return
+				}
+				load()
+			case <-tick:
+				load()
+			}
+		}
+	}()
+	return ch
+}
+
+// fingerprint returns a string identifying a list of targets regardless of their order.
+func fingerprint(tcs []*config.TargetConfig) string {
+	s := make([]string, 0, len(tcs))
+	for _, tc := range tcs {
+		b, _ := json.Marshal(tc)
+		s = append(s, string(b))
+	}
+	sort.Strings(s)
+	b, _ := json.Marshal(s)
+	return string(b)
+}